@@ -0,0 +1,164 @@
+package serializer
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+// ADSerializer is a Serializer that can bind StringTokens to associated data,
+// such as the name of the service that accepts them, a TLS channel-binding
+// value or a tenant ID. The associated data is covered by the Signature (or
+// authenticated along with the sealed Payload), but is never written to the
+// StringToken: a StringToken generated by SerializeAD deserializes only with
+// DeserializeAD and the same associated data, and fails with ErrBadSign
+// anywhere else.
+//
+// Empty associated data binds nothing, i.e. SerializeAD(token, nil) is the
+// same as Serialize(token). KeyRings, and the Serializers returned by
+// NewSealer, NewVerifier, NewDerived and New (except for SignNone, whose
+// StringTokens are not signed) implement ADSerializer.
+type ADSerializer interface {
+	Serializer
+	SerializeAD(token interface{}, ad []byte) (string, error)
+	DeserializeAD(s string, token interface{}, ad []byte) error
+}
+
+// SerializeAD makes hmacSerializer implement the ADSerializer interface.
+func (sr *hmacSerializer) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+	return genericSerialize(&sr.opts, algName(sr.algorithm()), token,
+		adWriteSign(ad, sr.writeSign))
+}
+
+// DeserializeAD makes hmacSerializer implement the ADSerializer interface.
+func (sr *hmacSerializer) DeserializeAD(
+	s string, token interface{}, ad []byte) (err error) {
+	return genericDeserialize(s, &sr.opts, algName(sr.algorithm()), token,
+		adCompareSign(ad, sr.compareSign))
+}
+
+// SerializeAD makes cryptoSerializer implement the ADSerializer interface.
+func (sr *cryptoSerializer) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+	return genericSerialize(&sr.opts, algName(sr.algorithm()), token,
+		adWriteSign(ad, sr.writeSign))
+}
+
+// DeserializeAD makes cryptoSerializer implement the ADSerializer interface.
+func (sr *cryptoSerializer) DeserializeAD(
+	s string, token interface{}, ad []byte) (err error) {
+	return genericDeserialize(s, &sr.opts, algName(sr.algorithm()), token,
+		adCompareSign(ad, sr.compareSign))
+}
+
+// SerializeAD makes verifierSerializer implement the ADSerializer interface.
+// It always returns ErrVerifyOnly.
+func (sr *verifierSerializer) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+	return "", ErrVerifyOnly
+}
+
+// SerializeAD makes sealSerializer implement the ADSerializer interface.
+func (sr *sealSerializer) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+	return sr.seal(formatHeader(sr.params()...), ad, token)
+}
+
+// DeserializeAD makes sealSerializer implement the ADSerializer interface.
+func (sr *sealSerializer) DeserializeAD(
+	s string, token interface{}, ad []byte) (err error) {
+	return sr.deserialize(s, ad, token)
+}
+
+// SerializeAD makes KeyRing implement the ADSerializer interface.
+func (kr *KeyRing) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+	return kr.serialize(context.Background(), ad, token)
+}
+
+// DeserializeAD makes KeyRing implement the ADSerializer interface.
+func (kr *KeyRing) DeserializeAD(
+	s string, token interface{}, ad []byte) (err error) {
+	return kr.deserialize(s, ad, token)
+}
+
+// adLenSize is the size of the length of the associated data (see appendAD).
+const adLenSize = 8
+
+// noAD is the length of empty associated data, which follows the binary
+// Payload of a StringToken that is signed without associated data, unless
+// the Payload is signed on its own (see signedBytes).
+var noAD [adLenSize]byte
+
+// appendAD appends the associated data ad to b, followed by the length of ad
+// as a 64-bit big-endian integer, so that b and ad cannot be split
+// differently by another StringToken. b is returned as-is if ad is empty.
+//
+// The Signature of a StringToken covers the associated data this way, after
+// the signPrefix and the binary Payload (see appendBound); if there is none,
+// the binary Payload is followed by its zero length (noAD, see headerAppend),
+// or signed on its own, so that the bytes signed with and without associated
+// data can never be the same. The Header that a sealed StringToken
+// authenticates instead contains no zero bytes, and so needs no length if ad
+// is empty.
+func appendAD(b, ad []byte) []byte {
+	if 0 == len(ad) {
+		return b
+	}
+
+	var n [adLenSize]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(ad)))
+
+	return append(append(b, ad...), n[:]...)
+}
+
+// appendBound appends the bytes b, as passed to writeSign (see signedBytes),
+// bound to the non-empty associated data ad to dst: the signPrefix, the binary
+// Payload, ad and its length. b is a bare Msgpack Payload unless it starts
+// with signTag; otherwise it ends with noAD, which is replaced by ad.
+func appendBound(dst, b, ad []byte) []byte {
+	if 0 == len(b) || signTag != b[0] {
+		dst = append(append(dst, msgpackPrefix...), b...)
+	} else {
+		dst = append(dst, b[:len(b)-adLenSize]...)
+	}
+
+	return appendAD(dst, ad)
+}
+
+// adWriteSign returns a writeSign function that signs the binary Payload bound
+// to the associated data ad (see appendBound).
+func adWriteSign(ad []byte,
+	writeSign func([]byte, io.Writer) error) func([]byte, io.Writer) error {
+
+	if 0 == len(ad) {
+		return writeSign
+	}
+
+	return func(b []byte, w io.Writer) error {
+		var p = slicePool.Get().(*[]byte)
+		defer slicePool.Put(p)
+
+		*p = appendBound((*p)[:0], b, ad)
+		return writeSign(*p, w)
+	}
+}
+
+// adCompareSign returns a compareSign function that verifies the Signature of
+// the binary Payload bound to the associated data ad, like adWriteSign.
+func adCompareSign(ad []byte,
+	compareSign func([]byte, []byte) error) func([]byte, []byte) error {
+
+	if 0 == len(ad) {
+		return compareSign
+	}
+
+	return func(b, sig []byte) error {
+		var p = slicePool.Get().(*[]byte)
+		defer slicePool.Put(p)
+
+		*p = appendBound((*p)[:0], b, ad)
+		return compareSign(*p, sig)
+	}
+}
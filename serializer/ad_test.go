@@ -0,0 +1,101 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestADSerializer(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var edVer, _ = NewVerifier(SignEdDSA, tEdDSAKey.Public(), 0)
+	var sealer, _ = NewSealer(SealAESGCM, tRandBuf[:32])
+	var kr, sealKR = NewKeyRing(), NewKeyRing()
+	var derived, _ = NewDerived(tRandBuf[:256], crypto.SHA256, "tenant-1")
+
+	kr.Add("k0", edSer)
+	kr.Activate("k0")
+	sealKR.Add("k0", sealer)
+	sealKR.Activate("k0")
+
+	var base = func(ser, ver Serializer) func(*testing.T) {
+		return func(t *testing.T) {
+			var as, av = ser.(ADSerializer), ver.(ADSerializer)
+			var ad = []byte("tenant-1")
+			var s string
+			var p tPayloadT
+			var err error
+
+			if s, err = as.SerializeAD(tPayload, ad); nil != err {
+				t.Fatal(err)
+			} else if err = av.DeserializeAD(s, &p, ad); nil != err {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			// the StringToken is bound to ad and nothing else, including a
+			// different split of the same bytes between Payload and ad
+			for _, ad := range [][]byte{
+				nil, []byte("tenant-2"), []byte("tenant-"), []byte("1"),
+				append([]byte("tenant-1"), 0),
+			} {
+				if err = av.DeserializeAD(s, &p,
+					ad); !errors.Is(err, ErrBadSign) {
+					t.Errorf("expect ErrBadSign for %q, got %v", ad, err)
+				}
+			}
+
+			if err = ver.Deserialize(s, &p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign, got %v", err)
+			}
+
+			// nor can ad be moved to the end of the Payload, which would
+			// otherwise be signed the same without associated data
+			if i, j := strings.IndexByte(s, '.'),
+				strings.LastIndexByte(s, '.'); i < j {
+				var b, _ = tokenEncoding.DecodeString(s[i+1 : j])
+				var fs = s[:i+1] + tokenEncoding.EncodeToString(
+					appendAD(b, ad)) + s[j:]
+
+				if err = ver.Deserialize(fs,
+					&p); !errors.Is(err, ErrBadSign) {
+					t.Errorf("expect ErrBadSign for %.32s, got %v", fs, err)
+				}
+			}
+
+			// empty associated data binds nothing
+			if s, err = as.SerializeAD(tPayload, nil); nil != err {
+				t.Fatal(err)
+			} else if err = ver.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if err = av.DeserializeAD(s, &p,
+				ad); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign, got %v", err)
+			}
+		}
+	}
+
+	t.Run("HMAC", base(hmacSer, hmacSer))
+	t.Run("EdDSA", base(edSer, edVer))
+	t.Run("Sealer", base(sealer, sealer))
+	t.Run("KeyRing", base(kr, kr))
+	t.Run("KeyRing/Seal", base(sealKR, sealKR))
+	t.Run("Derived", base(derived, derived))
+
+	t.Run("Error", func(t *testing.T) {
+		var noneSer, _ = New(SignNone, nil, 0)
+
+		if _, ok := noneSer.(ADSerializer); ok {
+			t.Error("expect SignNone not to implement ADSerializer")
+		}
+
+		if _, err := edVer.(ADSerializer).SerializeAD(tPayload,
+			[]byte("foo")); ErrVerifyOnly != err {
+			t.Errorf("expect ErrVerifyOnly, got %v", err)
+		}
+	})
+}
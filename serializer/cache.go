@@ -0,0 +1,293 @@
+package serializer
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the longest time that a Cache keeps a verified
+// StringToken whose payload does not implement Expirer, if the ttl passed to
+// NewCache is zero.
+const DefaultCacheTTL = 5 * time.Minute
+
+// Expirer is implemented by payloads that expire, such as token.Token. A Cache
+// keeps the StringTokens of such payloads no longer than until ExpiresAt; the
+// zero time.Time means that the payload never expires.
+type Expirer interface {
+	ExpiresAt() time.Time
+}
+
+// Cache is a Serializer that remembers the StringTokens verified by another
+// Serializer, keyed by their SHA-256 hash, so that a StringToken presented
+// again is unpacked without verifying its Signature again. It is meant to be
+// put in front of Serializers whose verification is expensive, such as those
+// for SignRSA and SignPSS.
+//
+// Only signed StringTokens are cached; sealed StringTokens, those of a
+// MacaroonSerializer, whose Caveats must be checked every time, those of
+// Serializers of other packages, and other tokens (see NewJWT) are always
+// passed to the underlying Serializer. Once the
+// Cache is full, the least recently used StringToken is evicted. If the
+// underlying Serializer is a KeyRing, the Cache is purged whenever its keys
+// change or the grace period of a retired key elapses.
+//
+// Note that the Cache does not check the payload of a StringToken (e.g. its
+// expiry) any more than the underlying Serializer does. Cached Payloads are
+// unpacked within the limits of the Options of the underlying Serializer, as
+// they would be by that Serializer (see Options.Strict).
+type Cache struct {
+	ser  Serializer
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	epoch   uint64
+	gen     uint64
+	next    time.Time
+	stats   CacheStats
+}
+
+// CacheStats holds the statistics of a Cache (see Cache.Stats). Hits and
+// Misses count the StringTokens that were and were not found in the Cache,
+// and Evictions those that were dropped to make room for others.
+type CacheStats struct {
+	Hits, Misses, Evictions uint64
+
+	// Len is the number of StringTokens in the Cache.
+	Len int
+}
+
+// cacheEntry is a StringToken stored in a Cache, along with its binary
+// (decompressed) Payload and the Codec that decodes it, within the limits of
+// the underlying Serializer (see payloadLimits).
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	payload []byte
+	codec   Codec
+	expires time.Time
+}
+
+// NewCache returns a Cache that holds up to size StringTokens verified by
+// ser, each for no longer than ttl, or DefaultCacheTTL if ttl is zero. An
+// error is returned if size is not positive.
+func NewCache(ser Serializer, size int, ttl time.Duration) (*Cache, error) {
+	if nil == ser {
+		return nil, errorf(efBadSerlr, "", ser, &Cache{})
+	} else if size <= 0 {
+		return nil, errorf(efCacheSize, "", size)
+	}
+
+	if 0 == ttl {
+		ttl = DefaultCacheTTL
+	}
+
+	return &Cache{
+		ser:     ser,
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}, nil
+}
+
+// Serialize makes Cache implement the Serializer interface. It calls
+// Serialize of the underlying Serializer.
+func (c *Cache) Serialize(token interface{}) (s string, err error) {
+	return c.ser.Serialize(token)
+}
+
+// Deserialize makes Cache implement the Serializer interface. If s is in the
+// Cache, its Payload is unpacked to token right away; otherwise s is passed to
+// the underlying Serializer, and stored in the Cache once verified.
+func (c *Cache) Deserialize(s string, token interface{}) (err error) {
+	var key = sha256.Sum256([]byte(s))
+	var e *cacheEntry
+	var epoch uint64
+
+	if e, epoch = c.get(key, time.Now()); nil != e {
+		return decodeError(StageCodec,
+			e.codec.Decode(bytes.NewReader(e.payload), token))
+	}
+
+	if err = c.ser.Deserialize(s, token); nil != err {
+		return
+	}
+
+	c.add(key, epoch, s, token)
+	return
+}
+
+// Stats returns the statistics of c.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var st = c.stats
+	st.Len = c.lru.Len()
+
+	return st
+}
+
+// Purge removes all StringTokens from c. The statistics are kept.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+}
+
+// get returns the entry of the StringToken hashed to key, or nil if there is
+// none or it expired at the time now, along with the number of times that c
+// was purged. It counts a hit or a miss.
+func (c *Cache) get(key [sha256.Size]byte,
+	now time.Time) (e *cacheEntry, epoch uint64) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checkRotation(now)
+
+	if el, ok := c.entries[key]; ok {
+		if e = el.Value.(*cacheEntry); now.Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			return e, c.epoch
+		}
+
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+
+	c.stats.Misses++
+	return nil, c.epoch
+}
+
+// add stores the StringToken s, hashed to key, which was verified and
+// unpacked to token after the Cache was purged epoch times (see get). s is not
+// stored if it is not a signed StringToken, if token already expired, or if
+// the Cache was purged since s was verified.
+func (c *Cache) add(key [sha256.Size]byte,
+	epoch uint64, s string, token interface{}) {
+
+	var now = time.Now()
+	var expires = now.Add(c.ttl)
+	var t *UnverifiedToken
+	var l limits
+	var ok bool
+	var err error
+
+	if t, err = Inspect(s); nil != err || t.Sealed || nil == t.Signature {
+		return
+	} else if l, ok = payloadLimits(c.ser, t, now); !ok {
+		return
+	}
+
+	if exp := expiry(token); !exp.IsZero() && exp.Before(expires) {
+		if expires = exp; !now.Before(expires) {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checkRotation(now); epoch != c.epoch {
+		return
+	} else if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	for c.lru.Len() >= c.size {
+		var el = c.lru.Back()
+
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		payload: t.Payload,
+		codec:   limitCodec{t.Codec, l},
+		expires: expires,
+	})
+}
+
+// payloadLimits returns the limits within which ser unpacks the Payload of
+// the StringToken t at the time now, and false if ser is not a Serializer of
+// this package whose StringTokens are cached.
+func payloadLimits(ser Serializer,
+	t *UnverifiedToken, now time.Time) (l limits, ok bool) {
+
+	switch sr := ser.(type) {
+	case *KeyRing:
+		if k := sr.keyAt(t.KeyID, now); nil != k {
+			return k.options().limits(), true
+		}
+	case *multiVerifier:
+		if sg := sr.sgs[t.HeaderAlg]; nil != sg {
+			return sg.options().limits(), true
+		}
+	case *derivedSerializer:
+		return sr.opts.limits(), true
+	case signer:
+		return sr.options().limits(), true
+	}
+
+	return
+}
+
+// checkRotation purges c if the underlying Serializer is a KeyRing whose keys
+// changed since the last call, or if the grace period of one of its retired
+// keys elapsed (see KeyRing.rotation). c.mu must be held.
+func (c *Cache) checkRotation(now time.Time) {
+	var kr, ok = c.ser.(*KeyRing)
+	if !ok {
+		return
+	}
+
+	var gen, next = kr.rotation(now)
+
+	if gen != c.gen || (!c.next.IsZero() && !now.Before(c.next)) {
+		c.purge()
+	}
+
+	c.gen, c.next = gen, next
+}
+
+// purge removes all StringTokens from c. c.mu must be held.
+func (c *Cache) purge() {
+	c.epoch++
+	c.lru.Init()
+	c.entries = make(map[[sha256.Size]byte]*list.Element)
+}
+
+// expiry returns the expiry of token, or the zero time.Time if neither token
+// nor any value it points to implements Expirer.
+func expiry(token interface{}) time.Time {
+	var v = reflect.ValueOf(token)
+
+	for v.IsValid() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			break
+		}
+
+		if e, ok := v.Interface().(Expirer); ok {
+			return e.ExpiresAt()
+		}
+
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			break
+		}
+
+		v = v.Elem()
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,225 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tExpPayload is a test payload that implements Expirer.
+type tExpPayload struct {
+	URI string
+	Exp int64
+}
+
+func (p *tExpPayload) ExpiresAt() time.Time {
+	if 0 == p.Exp {
+		return time.Time{}
+	}
+
+	return time.Unix(p.Exp, 0)
+}
+
+func TestCache(t *testing.T) {
+	var rsaSer, _ = New(SignRSA, tRSAKey, crypto.SHA256)
+
+	t.Run("Deserialize", func(t *testing.T) {
+		var c, _ = NewCache(rsaSer, 2, 0)
+		var s0, _ = c.Serialize(tPayload)
+		var s1, _ = c.Serialize(tPayloadT{URI: "foo"})
+		var s2, _ = c.Serialize(tPayloadT{URI: "bar"})
+
+		for i, s := range []string{s0, s0, s1, s0, s2, s1, s0} {
+			var p tPayloadT
+			var exp tPayloadT
+
+			_ = rsaSer.Deserialize(s, &exp)
+
+			if err := c.Deserialize(s, &p); nil != err {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(exp, p) {
+				t.Errorf("%d: deserialized payload does not match "+
+					"expectation", i)
+			}
+		}
+
+		// s1 evicts nothing, s2 evicts s1, s1 evicts s0 and s0 evicts s2
+		var exp = CacheStats{Hits: 2, Misses: 5, Evictions: 3, Len: 2}
+		if st := c.Stats(); exp != st {
+			t.Errorf("expect %+v, got %+v", exp, st)
+		}
+
+		if c.Purge(); 0 != c.Stats().Len {
+			t.Error("expect empty Cache after Purge")
+		}
+	})
+
+	t.Run("Bad", func(t *testing.T) {
+		var c, _ = NewCache(rsaSer, 8, 0)
+		var jwt, _ = NewJWT(rsaSer)
+		var jc, _ = NewCache(jwt, 8, 0)
+		var s, _ = c.Serialize(tPayload)
+		var bad = s[:strings.LastIndexByte(s, '.')+1] + "AAAA"
+		var p tPayloadT
+
+		// tokens that fail to verify are never cached
+		for i := 0; i < 2; i++ {
+			if err := c.Deserialize(bad, &p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign, got %v", err)
+			}
+		}
+
+		// tokens other than StringTokens pass through
+		if s, _ = jc.Serialize(tPayload); nil != jc.Deserialize(s, &p) ||
+			nil != jc.Deserialize(s, &p) {
+			t.Error("expect JWT to deserialize")
+		}
+
+		if st := c.Stats(); 0 != st.Len || 0 != st.Hits {
+			t.Errorf("unexpected stats %+v", st)
+		} else if st = jc.Stats(); 0 != st.Len || 0 != st.Hits {
+			t.Errorf("unexpected stats %+v", st)
+		}
+
+		if c, err := NewCache(rsaSer, 0, 0); nil == err || nil != c {
+			t.Error("expect error for zero size")
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		var c, _ = NewCache(rsaSer, 8, time.Hour)
+		var now = time.Now()
+
+		for _, exp := range []time.Time{now.Add(-time.Second),
+			now.Add(time.Second)} {
+			var s, _ = c.Serialize(tExpPayload{URI: "foo", Exp: exp.Unix()})
+			var p *tExpPayload
+
+			for i := 0; i < 2; i++ {
+				if err := c.Deserialize(s, &p); nil != err {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		// the expired payload is never cached, the other is cached once
+		if st := c.Stats(); 1 != st.Hits || 1 != st.Len {
+			t.Errorf("unexpected stats %+v", st)
+		}
+
+		if e := expiry(&tPayload); !e.IsZero() {
+			t.Errorf("unexpected expiry %v", e)
+		}
+	})
+
+	t.Run("KeyRing", func(t *testing.T) {
+		var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+		var kr = NewKeyRing()
+		var c, _ = NewCache(kr, 8, 0)
+		var s string
+		var p tPayloadT
+
+		kr.Add("k0", rsaSer)
+		kr.Add("k1", hmacSer)
+		kr.Activate("k0")
+
+		if s, _ = c.Serialize(tPayload); nil != c.Deserialize(s, &p) {
+			t.Fatal("expect StringToken to deserialize")
+		} else if 1 != c.Stats().Len {
+			t.Fatal("expect StringToken to be cached")
+		}
+
+		// rotating the keys purges the Cache
+		kr.Activate("k1")
+		kr.Retire("k0", time.Now().Add(50*time.Millisecond))
+
+		if err := c.Deserialize(s, &p); nil != err {
+			t.Fatal(err)
+		} else if st := c.Stats(); 0 != st.Hits || 1 != st.Len {
+			t.Errorf("unexpected stats %+v", st)
+		}
+
+		// as does the end of the grace period of the retired key
+		time.Sleep(60 * time.Millisecond)
+
+		if err := c.Deserialize(s, &p); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expect ErrUnknownKey, got %v", err)
+		} else if st := c.Stats(); 0 != st.Hits || 0 != st.Len {
+			t.Errorf("unexpected stats %+v", st)
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		var strict, _ = WithOptions(rsaSer, Options{Strict: true})
+		var c, _ = NewCache(strict, 8, 0)
+		var s, _ = c.Serialize(map[string]interface{}{"uri": "foo", "ts9": 1})
+		var m map[string]interface{}
+		var p tPayloadT
+
+		if err := c.Deserialize(s, &m); nil != err {
+			t.Fatal(err)
+		} else if 1 != c.Stats().Len {
+			t.Fatal("expect StringToken to be cached")
+		}
+
+		// the cached Payload is unpacked in strict mode, like the first
+		if err := c.Deserialize(s, &p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat, got %v", err)
+		} else if st := c.Stats(); 1 != st.Hits {
+			t.Errorf("unexpected stats %+v", st)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var c, _ = NewCache(rsaSer, 4, 0)
+		var ss [8]string
+		var wg sync.WaitGroup
+
+		for i := range ss {
+			ss[i], _ = c.Serialize(tPayloadT{URI: strings.Repeat("x", i)})
+		}
+
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 64; i++ {
+					var s = ss[(g+i)%len(ss)]
+					var p tPayloadT
+
+					if err := c.Deserialize(s, &p); nil != err {
+						t.Error(err)
+					} else if len(p.URI) != (g+i)%len(ss) {
+						t.Errorf("unexpected payload %+v", p)
+					}
+				}
+			}(g)
+		}
+
+		wg.Wait()
+	})
+}
+
+func BenchmarkCache(b *testing.B) {
+	var ser, _ = New(SignRSA, tRSAKey, crypto.SHA256)
+	var c, _ = NewCache(ser, 1024, 0)
+	var s, _ = ser.Serialize(tPayload)
+
+	for _, ser := range []Serializer{ser, c} {
+		b.Run(strings.TrimPrefix(reflect.TypeOf(ser).String(),
+			"*serializer."), func(b *testing.B) {
+			var p tPayloadT
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ser.Deserialize(s, &p); nil != err {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack"
+)
+
+// paramCodec is the name of the Header parameter that carries the name of the
+// Codec that encoded the Payload of a StringToken. It is omitted for Msgpack.
+const paramCodec = "codec"
+
+// Codecs available for the Payload part of StringTokens (see Options).
+var (
+	Msgpack Codec = msgpackCodec{} // Msgpack, the default
+	JSON    Codec = jsonCodec{}    // JSON (see encoding/json)
+	CBOR    Codec = cborCodec{}    // CBOR, RFC 8949 (see fxamacker/cbor)
+)
+
+// Codec is a type that converts the Payload part of a StringToken to and from
+// its binary form. Name identifies the Codec in the Header of StringTokens and
+// may only contain the characters allowed in a key ID (see KeyRing.Add).
+type Codec interface {
+	Name() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Options are additional options for the Serializers returned by New,
+// NewVerifier and NewSealer (see WithOptions).
+type Options struct {
+	// Codec is used to encode the Payload of generated StringTokens. If nil,
+	// Msgpack is used.
+	Codec Codec
+
+	// Accept lists the Codecs, besides Codec, whose StringTokens are accepted
+	// during deserialization. StringTokens encoded with any other Codec fail
+	// to deserialize with ErrBadCodec.
+	Accept []Codec
+
+	// Alg records the SignMethod and hash of the Serializer in the Header of
+	// generated StringTokens ("auth;alg=ecdsa+sha-256"), so that they can be
+	// verified by a Serializer returned by NewMultiVerifier. It has no effect
+	// on the Serializer returned by NewSealer.
+	Alg bool
+
+	// Compress compresses the binary Payload of generated StringTokens, but
+	// only where that makes them shorter; compressed StringTokens are marked
+	// in the Header ("auth;zip=def"). StringTokens compressed with any of the
+	// Compressions are accepted during deserialization. It has no effect on
+	// the Serializer returned by NewSealer, as the length of compressed
+	// Payloads gives away information about their content.
+	Compress Compression
+
+	// MaxDecompressed limits the size of decompressed Payloads, so that
+	// small StringTokens cannot expand to large amounts of memory;
+	// StringTokens that exceed it fail to deserialize with ErrTooLarge. If
+	// zero, DefaultMaxDecompressed is used.
+	MaxDecompressed int
+
+	// MaxLength limits the length of StringTokens, which is checked before
+	// any of their parts are decoded; longer StringTokens fail to deserialize
+	// with ErrTooLarge. If zero, DefaultMaxLength is used. The tokens of other
+	// formats (see NewJWT) are checked against the largest limit of the keys
+	// that may verify them before they are decoded, and against that of their
+	// key once it is known; those of NewCWT in their binary form.
+	MaxLength int
+
+	// MaxDepth limits how deeply the arrays and maps of Payloads may be
+	// nested, and MaxItems the number of elements (or key-value pairs) of each
+	// of them. Payloads are checked before they are decoded, and fail to
+	// deserialize with ErrTooDeep or ErrTooLarge. If zero, DefaultMaxDepth and
+	// DefaultMaxItems are used. The limits apply to Msgpack, JSON and CBOR,
+	// except that CBOR allows at least 4 levels and 16 elements, but not to
+	// other Codecs.
+	MaxDepth, MaxItems int
+
+	// Strict rejects Payloads followed by trailing data (ErrTrailingData),
+	// and Payloads with fields that the struct they are unpacked to does not
+	// have (ErrBadFormat). Like MaxDepth, it only applies to Msgpack, JSON and
+	// CBOR; values that decode themselves, e.g. by implementing
+	// msgpack.CustomDecoder, are not checked for unknown fields.
+	Strict bool
+}
+
+// WithOptions returns a copy of ser that uses opts. ser must be a Serializer
+// returned by New, NewVerifier, NewSealer, NewDerived or NewMacaroon; keys
+// added to a KeyRing keep the Options of the Serializer they were added from.
+// The copy of a MacaroonSerializer shares its CaveatCheckers.
+//
+// Every Codec other than Msgpack is recorded in the Header of StringTokens
+// ("auth;codec=json"), and is bound to the Signature, so that a StringToken
+// cannot be decoded with a Codec other than the one that encoded it.
+func WithOptions(ser Serializer, opts Options) (Serializer, error) {
+	for _, c := range append([]Codec{opts.codec()}, opts.Accept...) {
+		if nil == c || !validParam(c.Name()) {
+			return nil, errorf(efBadCodec, "", c)
+		}
+	}
+
+	if opts.Compress >= maxCompression {
+		return nil, errorf(efUndefZip, "", opts.Compress)
+	}
+
+	opts.Accept = append([]Codec(nil), opts.Accept...)
+
+	switch sr := ser.(type) {
+	case *genericSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *hmacSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *cryptoSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *verifierSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *derivedSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *MacaroonSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+
+	case *sealSerializer:
+		var c = *sr
+		c.opts = opts
+		return &c, nil
+	}
+
+	return nil, errorf(efBadSerlr, "", ser, opts)
+}
+
+// codec returns the Codec used to encode Payloads.
+func (o *Options) codec() Codec {
+	if nil == o.Codec {
+		return Msgpack
+	}
+
+	return o.Codec
+}
+
+// params returns the Header parameters that identify the algorithm alg of the
+// Serializer (see algName), if o.Alg is set, and the Codec used to encode
+// Payloads; there is none for Msgpack. alg is empty for Serializers that do
+// not sign StringTokens.
+func (o *Options) params(alg string) (ps []param) {
+	if o.Alg && 0 != len(alg) {
+		ps = append(ps, param{paramAlg, alg})
+	}
+
+	if n := o.codec().Name(); n != Msgpack.Name() {
+		ps = append(ps, param{paramCodec, n})
+	}
+
+	return
+}
+
+// decoder returns the accepted Codec identified by the Header parameters ps,
+// which must be left over after the caller has consumed its own parameters.
+// ps may start with the algorithm of the Serializer, alg, regardless of o.Alg,
+// and may end with the Compression of the Payload, which the returned Codec
+// then decompresses. The returned Codec also enforces the limits of o on the
+// binary Payload (see limitCodec). alg is empty for sealing Serializers,
+// which accept neither.
+//
+// ErrBadFormat is returned for unexpected parameters, ErrBadAlg if the
+// algorithm does not match alg, and ErrBadCodec if the Codec is not accepted.
+func (o *Options) decoder(alg string, ps []param) (c Codec, err error) {
+	var n = Msgpack.Name()
+	var z Compression
+
+	if 0 != len(ps) && ps[0].name == paramAlg && 0 != len(alg) {
+		if ps[0].value != alg {
+			return nil, ErrBadAlg
+		}
+
+		ps = ps[1:]
+	}
+
+	if 0 != len(ps) && ps[0].name == paramCodec {
+		n, ps = ps[0].value, ps[1:]
+	}
+
+	if 0 != len(ps) && ps[0].name == paramZip && 0 != len(alg) {
+		if z = parseCompression(ps[0].value); CompressNone == z {
+			return nil, ErrBadFormat
+		}
+
+		ps = ps[1:]
+	}
+
+	if 0 != len(ps) {
+		return nil, ErrBadFormat
+	}
+
+	if c = o.codec(); c.Name() != n {
+		c = nil
+	}
+
+	for _, a := range o.Accept {
+		if nil == c && a.Name() == n {
+			c = a
+		}
+	}
+
+	switch c.(type) {
+	case nil:
+		return nil, ErrBadCodec
+	case msgpackCodec, jsonCodec, cborCodec:
+		c = limitCodec{c, o.limits()}
+	}
+
+	if CompressNone != z {
+		return zipCodec{c, z, o.maxDecompressed()}, nil
+	}
+
+	return c, nil
+}
+
+// maxDecompressed returns the size limit of decompressed Payloads.
+func (o *Options) maxDecompressed() int {
+	if o.MaxDecompressed <= 0 {
+		return DefaultMaxDecompressed
+	}
+
+	return o.MaxDecompressed
+}
+
+// signTag is the first byte of every signPrefix. Msgpack never encodes
+// anything with it, so no Msgpack Payload starts with it either.
+const signTag = 0xc1
+
+// msgpackPrefix is the signPrefix of Payloads encoded by the default Codec
+// without Compression, which are signed without it (see signedBytes).
+var msgpackPrefix = signPrefix(Msgpack)
+
+// signPrefix returns the bytes that precede the binary Payload encoded by c,
+// when computing the Signature: signTag and the name of c, followed by a zero
+// byte and the Compression of the Payload, if c is a zipCodec (CompressNone
+// otherwise). These bind the Codec and the Compression to the Signature; as
+// Codec names are valid Header parameters, which never contain a zero byte,
+// no two Codecs share a prefix.
+func signPrefix(c Codec) []byte {
+	var buf bytes.Buffer
+
+	writeSignPrefix(&buf, c)
+	return buf.Bytes()
+}
+
+// writeSignPrefix writes the signPrefix of c to buf, without allocating it.
+func writeSignPrefix(buf *bytes.Buffer, c Codec) {
+	var z = CompressNone
+
+	if zc, ok := c.(zipCodec); ok {
+		z = zc.z
+	}
+
+	buf.WriteByte(signTag)
+	buf.WriteString(c.Name())
+	buf.WriteByte(0)
+	buf.WriteByte(byte(z))
+}
+
+// signedBytes returns the bytes that the Signature of a StringToken covers,
+// given b, which holds the signPrefix, the binary Payload and the length of
+// the (empty) associated data, as written by headerAppend: b itself, unless
+// it starts with msgpackPrefix, in which case only the binary Payload is
+// signed, as by earlier versions of this package. Either way, Payloads of
+// other Codecs, or compressed, cannot be passed off as Msgpack: a bare
+// Payload signed in their stead would start with signTag, and so fail to
+// decode.
+func signedBytes(b []byte) []byte {
+	if bytes.HasPrefix(b, msgpackPrefix) {
+		return b[len(msgpackPrefix) : len(b)-adLenSize]
+	}
+
+	return b
+}
+
+// msgpackCodec implements the Msgpack Codec.
+type msgpackCodec struct{}
+
+// Name makes msgpackCodec implement the Codec interface.
+func (msgpackCodec) Name() string { return "msgpack" }
+
+// Encode makes msgpackCodec implement the Codec interface.
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return msgpack.NewEncoder(w).Encode(v)
+}
+
+// Decode makes msgpackCodec implement the Codec interface.
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	return msgpack.NewDecoder(r).Decode(v)
+}
+
+// jsonCodec implements the JSON Codec.
+type jsonCodec struct{}
+
+// Name makes jsonCodec implement the Codec interface.
+func (jsonCodec) Name() string { return "json" }
+
+// Encode makes jsonCodec implement the Codec interface. Unlike json.Encoder,
+// it does not write a trailing newline.
+func (jsonCodec) Encode(w io.Writer, v interface{}) (err error) {
+	var b []byte
+
+	if b, err = json.Marshal(v); nil != err {
+		return
+	}
+
+	_, err = w.Write(b)
+	return
+}
+
+// Decode makes jsonCodec implement the Codec interface.
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// cborCodec implements the CBOR Codec.
+type cborCodec struct{}
+
+// Name makes cborCodec implement the Codec interface.
+func (cborCodec) Name() string { return "cbor" }
+
+// Encode makes cborCodec implement the Codec interface.
+func (cborCodec) Encode(w io.Writer, v interface{}) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+// Decode makes cborCodec implement the Codec interface.
+func (cborCodec) Decode(r io.Reader, v interface{}) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
@@ -0,0 +1,165 @@
+package serializer
+
+import (
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// tBadCodec is a Codec whose name cannot be written to a Header.
+type tBadCodec struct{ jsonCodec }
+
+// Name makes tBadCodec implement the Codec interface.
+func (tBadCodec) Name() string { return "bad.name" }
+
+func TestWithOptions(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var jwt, _ = NewJWT(hmacSer)
+
+	if ser, err := WithOptions(hmacSer,
+		Options{Codec: tBadCodec{}}); nil == err || nil != ser {
+		t.Error("expect error for invalid Codec name")
+	}
+
+	if ser, err := WithOptions(hmacSer,
+		Options{Accept: []Codec{nil}}); nil == err || nil != ser {
+		t.Error("expect error for nil Codec")
+	}
+
+	for _, ser := range []Serializer{jwt, NewKeyRing()} {
+		if ser, err := WithOptions(ser,
+			Options{Codec: JSON}); nil == err || nil != ser {
+			t.Errorf("expect error for %T", ser)
+		}
+	}
+
+	// the original Serializer is not modified
+	if ser, err := WithOptions(hmacSer, Options{Codec: JSON}); nil != err {
+		t.Fatal(err)
+	} else if reflect.DeepEqual(ser, hmacSer) {
+		t.Error("WithOptions does not return a copy with Options")
+	} else if !reflect.DeepEqual(hmacSer, &hmacSerializer{
+		key: tRandBuf[:256], hash: crypto.SHA256}) {
+		t.Error("WithOptions modifies the original Serializer")
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var noneSer, _ = New(SignNone, nil, 0)
+	var sealSer, _ = NewSealer(SealXChaCha20, tRandBuf[:32])
+
+	var base = func(ser Serializer, c Codec, hdr string) func(*testing.T) {
+		return func(t *testing.T) {
+			var p tPayloadT
+			var s string
+			var err error
+
+			if ser, err = WithOptions(ser, Options{Codec: c}); nil != err {
+				t.Fatal(err)
+			} else if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			}
+
+			if !strings.HasPrefix(s, hdr) {
+				t.Errorf("expect Header %q, got %q", hdr, s)
+			}
+
+			if err = ser.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+		}
+	}
+
+	t.Run("HMAC/Msgpack", base(hmacSer, Msgpack, "auth."))
+	t.Run("HMAC/JSON", base(hmacSer, JSON, "auth;codec=json."))
+	t.Run("HMAC/CBOR", base(hmacSer, CBOR, "auth;codec=cbor."))
+	t.Run("None/JSON", base(noneSer, JSON, "auth;codec=json."))
+	t.Run("Seal/CBOR", base(sealSer, CBOR, "auth;enc=XC20P;codec=cbor."))
+
+	t.Run("Accept", func(t *testing.T) {
+		var jsonSer, _ = WithOptions(hmacSer, Options{Codec: JSON})
+		var bothSer, _ = WithOptions(hmacSer,
+			Options{Codec: CBOR, Accept: []Codec{Msgpack, JSON}})
+		var p tPayloadT
+
+		var s0, _ = hmacSer.Serialize(tPayload)
+		var s1, _ = jsonSer.Serialize(tPayload)
+
+		if err := hmacSer.Deserialize(s1, &p); !errors.Is(err, ErrBadCodec) {
+			t.Errorf("expect ErrBadCodec for JSON, got (%v)", err)
+		} else if err = jsonSer.Deserialize(s0,
+			&p); !errors.Is(err, ErrBadCodec) {
+			t.Errorf("expect ErrBadCodec for Msgpack, got (%v)", err)
+		}
+
+		for _, s := range []string{s0, s1} {
+			if err := bothSer.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+		}
+	})
+
+	// the Codec is bound to the Signature, even though the Header is not
+	t.Run("Binding", func(t *testing.T) {
+		var ser, _ = WithOptions(hmacSer,
+			Options{Codec: JSON, Accept: []Codec{CBOR}})
+		var p tPayloadT
+		var s, _ = ser.Serialize(tPayload)
+
+		var j = strings.IndexByte(s, '.')
+		var k = strings.LastIndexByte(s, '.')
+		var b, _ = base64.RawURLEncoding.DecodeString(s[j+1 : k])
+
+		if err := ser.Deserialize("auth;codec=cbor"+s[j:],
+			&p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign for swapped Codec, got (%v)", err)
+		}
+
+		// a Msgpack Payload that begins with the prefix of another Codec
+		// must not verify with the Signature of that Codec
+		b = append(signPrefix(JSON), b...)
+		s = header + base64.RawURLEncoding.EncodeToString(b) + s[k:]
+
+		var v interface{}
+		if err := hmacSer.Deserialize(s, &v); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign for relabelled Payload, got (%v)", err)
+		}
+
+		// nor can a bare Msgpack Payload, signed without prefix, start with
+		// one: Msgpack never decodes its first byte
+		if err := msgpack.Unmarshal(b, &v); nil == err {
+			t.Error("expect Msgpack to reject a prefixed Payload")
+		}
+	})
+
+	t.Run("KeyRing", func(t *testing.T) {
+		var kr = NewKeyRing()
+		var jsonSer, _ = WithOptions(hmacSer, Options{Codec: JSON})
+		var p tPayloadT
+		var s string
+		var err error
+
+		kr.Add("k0", jsonSer)
+		kr.Activate("k0")
+
+		if s, err = kr.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(s, "auth;kid=k0;codec=json.") {
+			t.Errorf("unexpected Header: %q", s)
+		} else if err = kr.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("deserialized payload does not match expectation")
+		}
+	})
+}
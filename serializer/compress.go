@@ -0,0 +1,193 @@
+package serializer
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// paramZip is the name of the Header parameter that marks StringTokens whose
+// Payload is compressed, and carries the name of the Compression.
+const paramZip = "zip"
+
+// DefaultMaxDecompressed is the size limit of decompressed Payloads, if
+// Options.MaxDecompressed is zero.
+const DefaultMaxDecompressed = 64 << 10
+
+// zstdMaxWindow is the largest window that a zstd.Decoder allocates for the
+// Payload of a StringToken.
+const zstdMaxWindow = 8 << 20
+
+// Different compression methods available for the Payload part of
+// StringTokens (see Options).
+const (
+	CompressNone    Compression = iota
+	CompressDeflate             // DEFLATE, RFC 1951 (see compress/flate)
+	CompressZstd                // Zstandard, RFC 8878 (see klauspost/compress)
+	maxCompression
+)
+
+// Compression is an enum type used for the methods that compress the Payload
+// part of StringTokens.
+type Compression uint
+
+// compressionNames are the names of the Compressions in the Header of
+// StringTokens ("auth;zip=def").
+var compressionNames = [maxCompression]string{"", "def", "zstd"}
+
+// flateWriters and flateReaders are pools of DEFLATE compressors and
+// decompressors, which are expensive to allocate.
+var (
+	flateWriters = sync.Pool{
+		New: func() interface{} {
+			var w, _ = flate.NewWriter(nil, flate.BestCompression)
+			return w
+		},
+	}
+
+	flateReaders = sync.Pool{
+		New: func() interface{} {
+			return flate.NewReader(nil)
+		},
+	}
+)
+
+// zstdEncoder is the Zstandard compressor, created on first use. Its
+// EncodeAll method is safe for concurrent use.
+var zstdEncoder struct {
+	once sync.Once
+	*zstd.Encoder
+}
+
+// zstdReaders is a pool of Zstandard decompressors. They decode
+// synchronously, so that they do not hold on to goroutines while pooled.
+var zstdReaders = sync.Pool{
+	New: func() interface{} {
+		var d, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderLowmem(true),
+			zstd.WithDecoderMaxMemory(zstdMaxWindow))
+		return d
+	},
+}
+
+// zipCodec is a Codec that decompresses the Payload before it is decoded by
+// the Codec it wraps. It is only ever used for decoding (see
+// Options.decoder).
+type zipCodec struct {
+	Codec
+	z   Compression
+	max int
+}
+
+// compress compresses the bytes of buf following the first off bytes with z,
+// and replaces them with the compressed bytes if that saves space. It returns
+// true if the bytes were replaced.
+func (z Compression) compress(buf *bytes.Buffer,
+	off int) (ok bool, err error) {
+
+	var b = buf.Bytes()[off:]
+	var zb = bufferPool.Get().(*bytes.Buffer)
+	defer func() { zb.Reset(); bufferPool.Put(zb) }()
+
+	switch z {
+	case CompressDeflate:
+		var w = flateWriters.Get().(*flate.Writer)
+
+		w.Reset(zb)
+		if _, err = w.Write(b); nil == err {
+			err = w.Close()
+		}
+
+		w.Reset(nil)
+		flateWriters.Put(w)
+
+	case CompressZstd:
+		zstdEncoder.once.Do(func() {
+			zstdEncoder.Encoder, _ = zstd.NewWriter(nil,
+				zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		})
+
+		// EncodeAll appends to the unused capacity of zb, so the Write
+		// merely extends zb over the compressed bytes
+		zb.Write(zstdEncoder.EncodeAll(b, zb.Bytes()))
+
+	default:
+		return false, nil
+	}
+
+	if nil != err || zb.Len() >= len(b) {
+		return false, err
+	}
+
+	buf.Truncate(off)
+	buf.Write(zb.Bytes())
+
+	return true, nil
+}
+
+// parseCompression returns the Compression named s, or CompressNone if there
+// is none.
+func parseCompression(s string) Compression {
+	for z := CompressDeflate; z < maxCompression; z++ {
+		if compressionNames[z] == s {
+			return z
+		}
+	}
+
+	return CompressNone
+}
+
+// Decode makes zipCodec implement the Codec interface. ErrTooLarge is
+// returned if the decompressed Payload exceeds c.max bytes, and ErrBadFormat
+// if it cannot be decompressed, both as a *DecodeError at StagePayload.
+func (c zipCodec) Decode(r io.Reader, v interface{}) (err error) {
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	if err = c.decompress(r, buf); nil != err {
+		return
+	}
+
+	return c.Codec.Decode(buf, v)
+}
+
+// decompress reads the compressed Payload from r and writes the decompressed
+// Payload to buf, with the same errors as Decode.
+func (c zipCodec) decompress(r io.Reader, buf *bytes.Buffer) (err error) {
+	var n int64
+
+	switch c.z {
+	case CompressDeflate:
+		var fr = flateReaders.Get().(io.ReadCloser)
+
+		if err = fr.(flate.Resetter).Reset(r, nil); nil == err {
+			n, err = buf.ReadFrom(io.LimitReader(fr, int64(c.max)+1))
+		}
+
+		flateReaders.Put(fr)
+
+	case CompressZstd:
+		var zr = zstdReaders.Get().(*zstd.Decoder)
+
+		// hide the Bytes method of a *bytes.Buffer, so that the input is
+		// streamed through the limit, instead of being decoded in one go
+		if err = zr.Reset(struct{ io.Reader }{r}); nil == err {
+			n, err = buf.ReadFrom(io.LimitReader(zr, int64(c.max)+1))
+		}
+
+		zr.Reset(nil)
+		zstdReaders.Put(zr)
+	}
+
+	switch {
+	case nil != err:
+		return formatError(StagePayload, err)
+	case n > int64(c.max):
+		return decodeError(StagePayload, ErrTooLarge)
+	}
+
+	return
+}
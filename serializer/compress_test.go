@@ -0,0 +1,141 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var noneSer, _ = New(SignNone, nil, 0)
+
+	// large is a payload that compresses well, like a long audience list
+	var large = tPayload
+	for i := 0; i < 64; i++ {
+		large.URIS = append(large.URIS, tPayload.URIS...)
+	}
+
+	var base = func(z Compression, hdr string) func(*testing.T) {
+		return func(t *testing.T) {
+			var ser, _ = WithOptions(hmacSer, Options{Compress: z, Codec: JSON})
+			var plain, _ = WithOptions(hmacSer, Options{Codec: JSON})
+			var kr = NewKeyRing()
+			var s0, s1 string
+			var p tPayloadT
+			var err error
+
+			if s0, err = ser.Serialize(large); nil != err {
+				t.Fatal(err)
+			} else if !strings.HasPrefix(s0, hdr) {
+				t.Errorf("expect Header %s, got %s", hdr, s0[:len(hdr)])
+			} else if s1, _ = plain.Serialize(large); len(s0) >= len(s1) {
+				t.Errorf("compressed length %d, uncompressed %d", len(s0),
+					len(s1))
+			}
+
+			// compressed StringTokens are accepted without Options.Compress
+			for _, ver := range []Serializer{ser, plain} {
+				if err = ver.Deserialize(s0, &p); nil != err {
+					t.Error(err)
+				} else if !reflect.DeepEqual(large, p) {
+					t.Error("deserialized payload does not match expectation")
+				}
+			}
+
+			if err = ser.(BytesSerializer).DeserializeBytes([]byte(s0),
+				&p); nil != err {
+				t.Error(err)
+			}
+
+			// the Payload is only compressed where that saves space
+			if s0, _ = ser.Serialize("x"); strings.Contains(s0, paramZip) {
+				t.Errorf("unexpected compression of %s", s0)
+			}
+
+			// the Compression is bound to the Signature, even though the
+			// Header is not; it can neither be removed nor added
+			s0, _ = ser.Serialize(large)
+			s1, _ = plain.Serialize(large)
+
+			for _, s := range []string{
+				"auth;codec=json" + s0[strings.IndexByte(s0, '.'):],
+				hdr[:len(hdr)-1] + s1[strings.IndexByte(s1, '.'):],
+			} {
+				if err = plain.Deserialize(s, &p); !errors.Is(err, ErrBadSign) {
+					t.Errorf("expect ErrBadSign for %.32s, got (%v)", s, err)
+				}
+			}
+
+			kr.Add("k0", ser)
+			kr.Activate("k0")
+
+			if s0, err = kr.Serialize(large); nil != err {
+				t.Fatal(err)
+			} else if !strings.HasPrefix(s0, "auth;kid=k0;"+hdr[5:]) {
+				t.Errorf("unexpected Header in %s", s0)
+			} else if err = kr.Deserialize(s0, &p); nil != err {
+				t.Error(err)
+			}
+		}
+	}
+
+	t.Run("Deflate", base(CompressDeflate, "auth;codec=json;zip=def."))
+	t.Run("Zstd", base(CompressZstd, "auth;codec=json;zip=zstd."))
+
+	t.Run("Limit", func(t *testing.T) {
+		var ser, _ = WithOptions(noneSer, Options{Compress: CompressDeflate})
+		var ver, _ = WithOptions(noneSer, Options{MaxDecompressed: 16 << 10})
+		var bomb = tPayloadT{URI: strings.Repeat("0", 1<<20)}
+		var s, _ = ser.Serialize(bomb)
+		var p tPayloadT
+
+		if len(s) > 4096 {
+			t.Fatalf("unexpected length %d of compressed StringToken", len(s))
+		}
+
+		for _, ver := range []Serializer{noneSer, ver} {
+			if err := ver.Deserialize(s, &p); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("expect ErrTooLarge, got %v", err)
+			}
+		}
+
+		if s, _ = ser.Serialize(large); nil != ver.Deserialize(s, &p) {
+			t.Error("expect payload within limit to deserialize")
+		}
+	})
+
+	t.Run("Bad", func(t *testing.T) {
+		var sealer, _ = NewSealer(SealXChaCha20, tRandBuf[:32])
+		var ser, _ = WithOptions(noneSer, Options{Compress: CompressZstd})
+		var s, _ = ser.Serialize(large)
+		var body = s[strings.IndexByte(s, '.'):]
+		var p tPayloadT
+
+		if s, err := WithOptions(sealer, Options{
+			Compress: CompressDeflate}); nil != err {
+			t.Fatal(err)
+		} else if s, _ := s.Serialize(large); strings.Contains(
+			s[:strings.IndexByte(s, '.')], paramZip) {
+			t.Error("expect sealed StringTokens not to be compressed")
+		}
+
+		for _, s := range []string{
+			"auth;zip=foo" + body,
+			"auth;zip=def" + body,
+			"auth;zip=zstd;codec=msgpack" + body,
+			"auth;zip=zstd" + body[:len(body)/2],
+		} {
+			if err := ser.Deserialize(s, &p); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat for %s, got %v", s[:16], err)
+			}
+		}
+
+		if ser, err := WithOptions(noneSer,
+			Options{Compress: maxCompression}); nil == err || nil != ser {
+			t.Error("expect error for undefined Compression")
+		}
+	})
+}
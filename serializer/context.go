@@ -0,0 +1,182 @@
+package serializer
+
+import (
+	"context"
+	"crypto"
+	"io"
+)
+
+// ContextSerializer is a Serializer whose methods also accept a
+// context.Context, so that a slow signer, such as an HSM or a remote signing
+// service, can be given a deadline or cancelled when the request that needs
+// the StringToken is abandoned.
+//
+// KeyRings, MacaroonSerializers, and the Serializers returned by New and
+// NewVerifier for SignRSA, SignPSS, SignECDSA and SignEdDSA implement
+// ContextSerializer; use WithContext for any other Serializer.
+type ContextSerializer interface {
+	Serializer
+
+	// SerializeContext is as Serialize, but returns ctx.Err() if ctx is done
+	// before the StringToken is signed.
+	SerializeContext(ctx context.Context, token interface{}) (string, error)
+
+	// DeserializeContext is as Deserialize, but returns ctx.Err() if ctx is
+	// done before the StringToken is verified.
+	DeserializeContext(ctx context.Context, s string, token interface{}) error
+}
+
+// ContextSigner is a crypto.Signer that can be given a deadline or cancelled,
+// for example one backed by a remote signing service. When the key passed to
+// New is a ContextSigner, SerializeContext signs with SignContext instead of
+// Sign.
+type ContextSigner interface {
+	crypto.Signer
+
+	// SignContext is as Sign, but should return ctx.Err() once ctx is done.
+	SignContext(ctx context.Context, rand io.Reader, digest []byte,
+		opts crypto.SignerOpts) ([]byte, error)
+}
+
+// contextSerializer wraps a Serializer that does not implement
+// ContextSerializer (see WithContext).
+type contextSerializer struct {
+	Serializer
+}
+
+// contextSigner binds a ContextSigner to ctx, so that it can be used where a
+// crypto.Signer is expected.
+type contextSigner struct {
+	ContextSigner
+	ctx context.Context
+}
+
+// WithContext returns ser as a ContextSerializer. If ser does not implement
+// ContextSerializer already, the returned ContextSerializer checks ctx
+// before calling Serialize or Deserialize of ser, but cannot interrupt them.
+func WithContext(ser Serializer) ContextSerializer {
+	if cs, ok := ser.(ContextSerializer); ok {
+		return cs
+	}
+
+	return contextSerializer{ser}
+}
+
+// SerializeContext makes contextSerializer implement the ContextSerializer
+// interface.
+func (sr contextSerializer) SerializeContext(
+	ctx context.Context, token interface{}) (string, error) {
+
+	if err := ctx.Err(); nil != err {
+		return "", err
+	}
+
+	return sr.Serialize(token)
+}
+
+// DeserializeContext makes contextSerializer implement the ContextSerializer
+// interface.
+func (sr contextSerializer) DeserializeContext(
+	ctx context.Context, s string, token interface{}) error {
+
+	if err := ctx.Err(); nil != err {
+		return err
+	}
+
+	return sr.Deserialize(s, token)
+}
+
+// Sign makes contextSigner implement the crypto.Signer interface.
+func (k contextSigner) Sign(rand io.Reader, digest []byte,
+	opts crypto.SignerOpts) ([]byte, error) {
+	return k.SignContext(k.ctx, rand, digest, opts)
+}
+
+// SerializeContext makes cryptoSerializer implement the ContextSerializer
+// interface.
+func (sr *cryptoSerializer) SerializeContext(
+	ctx context.Context, token interface{}) (s string, err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	var sg = bindContext(ctx, sr)
+	return genericSerialize(&sr.opts, algName(sr.algorithm()), token,
+		sg.writeSign)
+}
+
+// DeserializeContext makes cryptoSerializer implement the ContextSerializer
+// interface. Verification never leaves the process, so ctx is only checked
+// before it starts.
+func (sr *cryptoSerializer) DeserializeContext(
+	ctx context.Context, s string, token interface{}) (err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	return sr.Deserialize(s, token)
+}
+
+// SerializeContext makes verifierSerializer implement the ContextSerializer
+// interface. It always returns ErrVerifyOnly.
+func (sr *verifierSerializer) SerializeContext(
+	ctx context.Context, token interface{}) (s string, err error) {
+	return "", ErrVerifyOnly
+}
+
+// DeserializeContext makes verifierSerializer implement the
+// ContextSerializer interface.
+func (sr *verifierSerializer) DeserializeContext(
+	ctx context.Context, s string, token interface{}) (err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	return sr.Deserialize(s, token)
+}
+
+// SerializeContext makes KeyRing implement the ContextSerializer interface.
+// The context is passed on to the active key, if it is held by a
+// ContextSigner.
+func (kr *KeyRing) SerializeContext(
+	ctx context.Context, token interface{}) (s string, err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	return kr.serialize(ctx, nil, token)
+}
+
+// DeserializeContext makes KeyRing implement the ContextSerializer
+// interface.
+func (kr *KeyRing) DeserializeContext(
+	ctx context.Context, s string, token interface{}) (err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	return kr.Deserialize(s, token)
+}
+
+// bindContext returns sg with its key bound to ctx, if sg is a
+// cryptoSerializer whose key is a ContextSigner; otherwise sg is returned
+// as-is. sg itself is never modified, as it may be shared by goroutines.
+func bindContext(ctx context.Context, sg signer) signer {
+	var sr, ok = sg.(*cryptoSerializer)
+	if !ok {
+		return sg
+	}
+
+	if k, ok := sr.key.(ContextSigner); ok {
+		var c = *sr
+		c.key = contextSigner{k, ctx}
+		return &c
+	}
+
+	return sr
+}
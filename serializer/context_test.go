@@ -0,0 +1,121 @@
+package serializer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// tContextSigner is a ContextSigner that blocks until its context is done if
+// wait is set, like a remote signing service that does not respond.
+type tContextSigner struct {
+	ed25519.PrivateKey
+	wait bool
+}
+
+func (k tContextSigner) SignContext(ctx context.Context, rand io.Reader,
+	digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+
+	if k.wait {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return k.Sign(rand, digest, opts)
+}
+
+func TestContextSerializer(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var edVer, _ = NewVerifier(SignEdDSA, tEdDSAKey.Public(), 0)
+	var done, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	var base = func(ser Serializer) func(*testing.T) {
+		return func(t *testing.T) {
+			var cs = WithContext(ser)
+			var ctx = context.Background()
+			var s string
+			var p tPayloadT
+			var err error
+
+			if s, err = cs.SerializeContext(ctx, tPayload); nil != err {
+				t.Fatal(err)
+			} else if err = cs.DeserializeContext(ctx, s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			// the StringTokens are interchangeable with those of Serialize
+			if err = ser.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if s, _ = ser.Serialize(tPayload); nil != cs.
+				DeserializeContext(ctx, s, &p) {
+				t.Error("expect StringToken of Serialize to deserialize")
+			}
+
+			if _, err = cs.SerializeContext(done, tPayload); err !=
+				context.Canceled {
+				t.Errorf("expect context.Canceled, got %v", err)
+			} else if err = cs.DeserializeContext(done, s,
+				&p); err != context.Canceled {
+				t.Errorf("expect context.Canceled, got %v", err)
+			}
+		}
+	}
+
+	var key = tContextSigner{PrivateKey: tEdDSAKey}
+	var edSer, _ = New(SignEdDSA, key, 0)
+	var kr = NewKeyRing()
+
+	kr.Add("k0", edSer)
+	kr.Activate("k0")
+
+	t.Run("HMAC", base(hmacSer))
+	t.Run("EdDSA", base(edSer))
+	t.Run("KeyRing", base(kr))
+
+	t.Run("Adapter", func(t *testing.T) {
+		if _, ok := WithContext(hmacSer).(contextSerializer); !ok {
+			t.Error("expect hmacSerializer to be wrapped")
+		}
+
+		for _, ser := range []Serializer{edSer, edVer, kr} {
+			if WithContext(ser) != ser {
+				t.Errorf("expect %T not to be wrapped", ser)
+			}
+		}
+
+		var cs = WithContext(edVer)
+		if _, err := cs.SerializeContext(context.Background(),
+			tPayload); ErrVerifyOnly != err {
+			t.Errorf("expect ErrVerifyOnly, got %v", err)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		var key = tContextSigner{PrivateKey: tEdDSAKey, wait: true}
+		var ser, _ = New(SignEdDSA, key, 0)
+		var kr = NewKeyRing()
+
+		kr.Add("k0", ser)
+		kr.Activate("k0")
+
+		for _, ser := range []Serializer{ser, kr} {
+			var ctx, cancel = context.WithTimeout(context.Background(),
+				10*time.Millisecond)
+
+			if _, err := WithContext(ser).SerializeContext(ctx,
+				tPayload); context.DeadlineExceeded != err {
+				t.Errorf("%T: expect context.DeadlineExceeded, got %v",
+					ser, err)
+			}
+
+			cancel()
+		}
+	})
+}
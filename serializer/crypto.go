@@ -3,12 +3,13 @@ package serializer
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/asn1"
 	"fmt"
 	"io"
 	"math/big"
-	"runtime"
 )
 
 const rsaKeyMinLen = 1024
@@ -19,66 +20,194 @@ const rsaKeyMinLen = 1024
 //     - sr.SignMethod == SignRSA   (RSASSA-PKCS1-v1.5)
 //     - sr.SignMethod == SignPSS   (RSASSA-PSS)
 //     - sr.SignMethod == SignECDSA (ECDSA)
+//     - sr.SignMethod == SignEdDSA (Ed25519)
 type cryptoSerializer struct {
 	SignMethod
 
 	key  crypto.Signer
 	hash crypto.Hash
+	opts Options
+
+	// the public key of key, as resolved by setPublic; only the field that
+	// matches the SignMethod is set
+	rsaKey *rsa.PublicKey
+	ecKey  *ecdsa.PublicKey
+	edKey  ed25519.PublicKey
 }
 
 // Serialize makes cryptoSerializer implement the Serializer interface.
 func (sr *cryptoSerializer) Serialize(
 	token interface{}) (s string, err error) {
-	return genericSerialize(token, sr.writeSign)
+	return genericSerialize(&sr.opts, algName(sr.algorithm()), token,
+		sr.writeSign)
 }
 
 // Deserialize makes cryptoSerializer implement the Serializer interface.
 func (sr *cryptoSerializer) Deserialize(
 	s string, token interface{}) (err error) {
-	return genericDeserialize(s, token, sr.compareSign)
+	return genericDeserialize(s, &sr.opts, algName(sr.algorithm()), token,
+		sr.compareSign)
+}
+
+// AppendSerialize makes cryptoSerializer implement the BytesSerializer
+// interface.
+func (sr *cryptoSerializer) AppendSerialize(
+	dst []byte, token interface{}) ([]byte, error) {
+	return genericAppend(dst, &sr.opts, algName(sr.algorithm()), token,
+		sr.writeSign)
+}
+
+// DeserializeBytes makes cryptoSerializer implement the BytesSerializer
+// interface.
+func (sr *cryptoSerializer) DeserializeBytes(
+	b []byte, token interface{}) (err error) {
+	return genericDeserializeBytes(b, &sr.opts, algName(sr.algorithm()),
+		token, sr.compareSign)
 }
 
 // newCryptoSerializer returns a new cryptoSerializer struct with the given key
 // and hash. The key argument is interface{} so the method can be plug-n-play
-// with the newSerializer function, but an error is returned if key is not one
-// of *rsa.PrivateKey and *ecdsa.PrivateKey.
+// with the newSerializer function, but an error is returned if key is not a
+// crypto.Signer whose public key is one of *rsa.PublicKey, *ecdsa.PublicKey
+// and ed25519.PublicKey, as expected by the sign-method sm.
+//
+// The private keys of crypto/rsa, crypto/ecdsa and crypto/ed25519 are checked
+// further; any other crypto.Signer (e.g. one backed by an HSM) is trusted to
+// hold the private half of its public key.
 func newCryptoSerializer(sm SignMethod,
 	key interface{}, hash crypto.Hash) (*cryptoSerializer, error) {
 
+	var k crypto.Signer
 	var ok bool
 
-	if !hash.Available() {
-		return nil, errorf(efBadHash, "", hash)
+	if err := checkHash(sm, hash); nil != err {
+		return nil, err
 	}
 
-	switch sm {
-	case SignRSA, SignPSS:
-		var k *rsa.PrivateKey
-		if k, ok = key.(*rsa.PrivateKey); !ok {
-			return nil, errorf(efBadKeyType, "", k)
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if nil == key {
+			return nil, errorf(efNoSigner, "", key)
 		}
 
-		if err := k.Validate(); nil != err {
+		if err := key.Validate(); nil != err {
 			return nil, errorf("invalid key (%v)", err)
 		}
 
-		if k.N.BitLen() < rsaKeyMinLen {
+	case *ecdsa.PrivateKey:
+		if nil == key {
+			return nil, errorf(efNoSigner, "", key)
+		}
+
+	case ed25519.PrivateKey:
+		if len(key) != ed25519.PrivateKeySize {
 			return nil, errorf(efBadKeyLen,
-				"", fmt.Sprintf("%d bits", rsaKeyMinLen))
+				"", fmt.Sprintf("%d bytes", ed25519.PrivateKeySize))
 		}
+	}
+
+	if k, ok = key.(crypto.Signer); !ok {
+		return nil, errorf(efNoSigner, "", key)
+	}
 
-		return &cryptoSerializer{sm, k, hash}, nil
+	var pub = k.Public()
+
+	if err := checkPublicKey(sm, pub); nil != err {
+		return nil, err
+	}
+
+	return (&cryptoSerializer{SignMethod: sm, key: k,
+		hash: hash}).setPublic(pub), nil
+}
+
+// setPublic stores the public key pub, which checkPublicKey accepted for
+// sr.SignMethod, in the field of its type, so that it is neither resolved
+// nor type-asserted again for every Signature. sr is returned.
+func (sr *cryptoSerializer) setPublic(
+	pub crypto.PublicKey) *cryptoSerializer {
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		sr.rsaKey = pub
+	case *ecdsa.PublicKey:
+		sr.ecKey = pub
+	case ed25519.PublicKey:
+		sr.edKey = pub
+	}
+
+	return sr
+}
+
+// checkPublicKey returns an error if key is not one of *rsa.PublicKey,
+// *ecdsa.PublicKey and ed25519.PublicKey, as expected by the sign-method sm,
+// or is too short.
+func checkPublicKey(sm SignMethod, key crypto.PublicKey) error {
+	var ok bool
+
+	switch sm {
+	case SignRSA, SignPSS:
+		var k *rsa.PublicKey
+		if k, ok = key.(*rsa.PublicKey); !ok || nil == k {
+			return errorf(efBadKeyType, "", k)
+		}
+
+		if k.N.BitLen() < rsaKeyMinLen {
+			return errorf(efBadKeyLen,
+				"", fmt.Sprintf("%d bits", rsaKeyMinLen))
+		}
 
 	case SignECDSA:
-		var k *ecdsa.PrivateKey
-		if k, ok = key.(*ecdsa.PrivateKey); !ok {
-			return nil, errorf(efBadKeyType, "", k)
+		var k *ecdsa.PublicKey
+		if k, ok = key.(*ecdsa.PublicKey); !ok || nil == k {
+			return errorf(efBadKeyType, "", k)
+		}
+
+	case SignEdDSA:
+		var k ed25519.PublicKey
+		if k, ok = key.(ed25519.PublicKey); !ok {
+			return errorf(efBadKeyType, "", k)
+		}
+
+		if len(k) != ed25519.PublicKeySize {
+			return errorf(efBadKeyLen,
+				"", fmt.Sprintf("%d bytes", ed25519.PublicKeySize))
 		}
 
-		return &cryptoSerializer{sm, k, hash}, nil
+	default:
+		return errorf(efBadMethod, evInternal, sm, &cryptoSerializer{})
+	}
+
+	return nil
+}
+
+// algorithm makes cryptoSerializer implement the signer interface.
+func (sr *cryptoSerializer) algorithm() (SignMethod, crypto.Hash) {
+	return sr.SignMethod, sr.hash
+}
+
+// options makes cryptoSerializer implement the signer interface.
+func (sr *cryptoSerializer) options() *Options {
+	return &sr.opts
+}
+
+// public returns the public key of the key used by cryptoSerializer.
+func (sr *cryptoSerializer) public() crypto.PublicKey {
+	return sr.key.Public()
+}
+
+// checkHash returns an error if hash cannot be used with the sign-method sm.
+func checkHash(sm SignMethod, hash crypto.Hash) error {
+	// Ed25519 hashes the Payload internally (see RFC 8032), so a separate
+	// digest is never computed for SignEdDSA
+	if sm == SignEdDSA {
+		if 0 != hash {
+			return errorf(efNoHash, "", hash, sm)
+		}
+	} else if !hash.Available() {
+		return errorf(efBadHash, "", hash)
 	}
 
-	return nil, errorf(efBadMethod, evInternal, sm, &cryptoSerializer{})
+	return nil
 }
 
 // writeSign computes the Signature part of StringToken from the binary
@@ -98,6 +227,8 @@ func (sr *cryptoSerializer) writeSign(b []byte, w io.Writer) (err error) {
 		sig, err = sr.rsaSign(dig)
 	case SignECDSA:
 		sig, err = sr.ecdsaSign(dig)
+	case SignEdDSA:
+		sig, err = sr.eddsaSign(dig)
 
 	default:
 		// should never happen if sr was set up properly
@@ -128,6 +259,8 @@ func (sr *cryptoSerializer) compareSign(b, sig []byte) (err error) {
 		return sr.rsaCompare(dig, sig)
 	case SignECDSA:
 		return sr.ecdsaCompare(dig, sig)
+	case SignEdDSA:
+		return sr.eddsaCompare(dig, sig)
 	}
 
 	// should never happen if sr was set up properly
@@ -135,8 +268,13 @@ func (sr *cryptoSerializer) compareSign(b, sig []byte) (err error) {
 }
 
 // hashDigest computes the hash digest of the binary (msgpack encoded) Payload
-// using sr.hash.
+// using sr.hash. The Payload is returned as-is if sr.hash is zero, which is
+// the case for SignEdDSA.
 func (sr *cryptoSerializer) hashDigest(b []byte) (s []byte, err error) {
+	if 0 == sr.hash {
+		return b, nil
+	}
+
 	var h = sr.hash.New()
 	if _, err = h.Write(b); nil != err {
 		return
@@ -147,20 +285,16 @@ func (sr *cryptoSerializer) hashDigest(b []byte) (s []byte, err error) {
 
 // rsaSign computes and returns the bianry Signature sig from the hash digest
 // of Payload h using RSASSA-PSS if sr.SignMethod == SignPSS and
-// RSASSA-PKCS-v1.5 otherwise.
-//
-// This method panics if sr.key was not configured properly.
+// RSASSA-PKCS-v1.5 otherwise. The Signature is computed by sr.key, which only
+// needs to implement crypto.Signer.
 func (sr *cryptoSerializer) rsaSign(h []byte) (sig []byte, err error) {
-	defer keyTypeAssertion(&rsa.PrivateKey{})
-	var key = sr.key.(*rsa.PrivateKey)
-
 	if sr.SignMethod == SignPSS {
-		return rsa.SignPSS(rand.Reader, key, sr.hash, h, &rsa.PSSOptions{
+		return sr.key.Sign(rand.Reader, h, &rsa.PSSOptions{
 			SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: sr.hash,
 		})
 	}
 
-	return rsa.SignPKCS1v15(rand.Reader, key, sr.hash, h)
+	return sr.key.Sign(rand.Reader, h, sr.hash)
 }
 
 // rsaCompare verifies the binary Signature sig against the hash digest of
@@ -168,10 +302,9 @@ func (sr *cryptoSerializer) rsaSign(h []byte) (sig []byte, err error) {
 // otherwise. nil is returned if Signature matches h, ErrBadSign is returned
 // otherwise.
 //
-// This method panics if sr.key was not configured properly.
+// This method panics if sr.rsaKey was not configured properly.
 func (sr *cryptoSerializer) rsaCompare(h, sig []byte) (err error) {
-	defer keyTypeAssertion(&rsa.PrivateKey{})
-	var key = &sr.key.(*rsa.PrivateKey).PublicKey
+	var key = sr.rsaKey
 
 	defer func() {
 		if nil != err {
@@ -189,27 +322,36 @@ func (sr *cryptoSerializer) rsaCompare(h, sig []byte) (err error) {
 }
 
 // ecdsaSign computes and returns Signature sig from the hash digest of Payload
-// h using ECDSA.
-//
-// This method panics if sr.key was not configured properly.
+// h using ECDSA. sr.key, which only needs to implement crypto.Signer, returns
+// an ASN.1 encoded signature; it is converted to the fixed-size form used by
+// StringTokens (and JWS).
 func (sr *cryptoSerializer) ecdsaSign(h []byte) (sig []byte, err error) {
-	defer keyTypeAssertion(&ecdsa.PrivateKey{})
-	var key = sr.key.(*ecdsa.PrivateKey)
-	var r, s *big.Int
+	var key = sr.ecKey
+	var der []byte
+	var rs struct{ R, S *big.Int }
 	var rb, sb []byte
 
 	// n is the size of signature and is 2x byte-length of the key i.e.
-	//     2 * (bit-length of key / 8)
-	// k marks the middle of the sig buffer
-	var n = key.Params().P.BitLen() / 4
-	var k = n / 2
+	//     2 * ceil(bit-length of key / 8)
+	// k marks the middle of the sig buffer; the bit-length is rounded up so
+	// that curves like P-521 fit (see RFC 7518, sec. 3.4)
+	var k = (key.Params().P.BitLen() + 7) / 8
+	var n = 2 * k
 
-	if r, s, err = ecdsa.Sign(rand.Reader, key, h); nil != err {
+	if der, err = sr.key.Sign(rand.Reader, h, sr.hash); nil != err {
 		return
 	}
 
-	rb = r.Bytes()
-	sb = s.Bytes()
+	if rest, err := asn1.Unmarshal(der, &rs); nil != err || 0 != len(rest) {
+		return nil, errorf(efBadSigner, "", sr.key)
+	}
+
+	rb = rs.R.Bytes()
+	sb = rs.S.Bytes()
+
+	if len(rb) > k || len(sb) > k {
+		return nil, errorf(efBadSigner, "", sr.key)
+	}
 
 	// copy rb and sb to sig, such that sig looks like:
 	//     [0...][rb]^[0...][sb]
@@ -222,18 +364,21 @@ func (sr *cryptoSerializer) ecdsaSign(h []byte) (sig []byte, err error) {
 	return
 }
 
-// rsaCompare verifies the binary Signature sig against the hash digest of
+// ecdsaCompare verifies the binary Signature sig against the hash digest of
 // Payload h using ECDSA. nil is returned if Signature matches h, ErrBadSign is
 // returned otherwise.
 //
-// This method panics if sr.key was not configured properly.
+// sig must be twice the byte-length of the key, rounded up (see RFC 7518, sec.
+// 3.4). The 130 byte Signatures that earlier versions of this package wrote
+// for P-521, rounding the byte-length down, are rejected.
+//
+// This method panics if sr.ecKey was not configured properly.
 func (sr *cryptoSerializer) ecdsaCompare(h, sig []byte) (err error) {
-	defer keyTypeAssertion(&ecdsa.PrivateKey{})
-	var key = &sr.key.(*ecdsa.PrivateKey).PublicKey
+	var key = sr.ecKey
 	var r, s = &big.Int{}, &big.Int{}
 
 	// k is byte-length of the key, as well as 1/2 of expected size of sig
-	var k = key.Curve.Params().P.BitLen() / 8
+	var k = (key.Curve.Params().P.BitLen() + 7) / 8
 
 	if len(sig) != 2*k {
 		return ErrBadSign
@@ -247,19 +392,33 @@ func (sr *cryptoSerializer) ecdsaCompare(h, sig []byte) (err error) {
 	return
 }
 
-// keyTypeAssertion is a recovery function that recovers from any
-// TypeAssertionError panics inside one of the ^(rsa|ecdsa)(Sign|Compare)$
-// methods, and itself panics, but with a more informative error message
-// instead of a generic TypeAssertionError.
+// eddsaSign computes and returns the binary Signature sig from the Payload b
+// using Ed25519. Ed25519 Signatures are deterministic and always
+// ed25519.SignatureSize bytes long. The Payload is passed to sr.key unhashed,
+// as required by crypto.Signer for Ed25519.
+func (sr *cryptoSerializer) eddsaSign(b []byte) (sig []byte, err error) {
+	if sig, err = sr.key.Sign(rand.Reader, b, crypto.Hash(0)); nil != err {
+		return
+	}
+
+	if len(sig) != ed25519.SignatureSize {
+		return nil, errorf(efBadSigner, "", sr.key)
+	}
+
+	return
+}
+
+// eddsaCompare verifies the binary Signature sig against the Payload b using
+// Ed25519. nil is returned if Signature matches b, ErrBadSign is returned
+// otherwise.
 //
-// This function should not be needed if newCryptoSerializer does its job
-// properly but still serves as a sanity-check.
-func keyTypeAssertion(key crypto.Signer) {
-	if r := recover(); nil != r {
-		if _, ok := r.(*runtime.TypeAssertionError); ok {
-			r = errorf(efBadKeyType, evInternal, key)
-		}
+// This method panics if sr.edKey was not configured properly.
+func (sr *cryptoSerializer) eddsaCompare(b, sig []byte) (err error) {
+	var key = sr.edKey
 
-		panic(r)
+	if !ed25519.Verify(key, b, sig) {
+		return ErrBadSign
 	}
+
+	return
 }
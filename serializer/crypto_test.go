@@ -4,35 +4,123 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
 	"testing"
 )
 
+// tSigner is a crypto.Signer that stands in for a key held by an HSM; it hides
+// the concrete type of the wrapped private key, records the SignerOpts of the
+// last call to Sign and counts the calls to Public.
+type tSigner struct {
+	key  crypto.Signer
+	opts crypto.SignerOpts
+	pubs int
+}
+
+// Public makes tSigner implement the crypto.Signer interface.
+func (s *tSigner) Public() crypto.PublicKey { s.pubs++; return s.key.Public() }
+
+// Sign makes tSigner implement the crypto.Signer interface.
+func (s *tSigner) Sign(r io.Reader,
+	dig []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.opts = opts
+	return s.key.Sign(r, dig, opts)
+}
+
 func TestRSASign(t *testing.T) {
-	testCryptoSign(t, &cryptoSerializer{
+	testCryptoSign(t, (&cryptoSerializer{
 		SignMethod: SignRSA,
 		hash:       crypto.SHA256,
 		key:        tRSAKey,
-	})
+	}).setPublic(tRSAKey.Public()))
 
 	return
 }
 
 func TestPSSSign(t *testing.T) {
-	testCryptoSign(t, &cryptoSerializer{
+	testCryptoSign(t, (&cryptoSerializer{
 		SignMethod: SignPSS,
 		hash:       crypto.SHA256,
 		key:        tRSAKey,
-	})
+	}).setPublic(tRSAKey.Public()))
 
 	return
 }
 
 func TestECDSASign(t *testing.T) {
-	testCryptoSign(t, &cryptoSerializer{
+	testCryptoSign(t, (&cryptoSerializer{
 		SignMethod: SignECDSA,
 		hash:       crypto.SHA256,
 		key:        tECDSAKey,
+	}).setPublic(tECDSAKey.Public()))
+
+	return
+}
+
+// P-521 Signatures written by earlier versions were 130 bytes long instead of
+// the 132 bytes of RFC 7518; they are rejected.
+func TestECDSALegacyP521(t *testing.T) {
+	var key, _ = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	var ser = (&cryptoSerializer{
+		SignMethod: SignECDSA,
+		hash:       crypto.SHA512,
+		key:        key,
+	}).setPublic(key.Public())
+	var dat = tRandBuf[:256]
+	var dig, _ = ser.hashDigest(dat)
+	var r, s *big.Int
+	var err error
+
+	testCryptoSign(t, ser)
+
+	// the legacy encoding can only hold r and s up to 65 bytes long
+	for {
+		if r, s, err = ecdsa.Sign(rand.Reader, key, dig); nil != err {
+			t.Fatal(err)
+		} else if len(r.Bytes()) <= 65 && len(s.Bytes()) <= 65 {
+			break
+		}
+	}
+
+	var sig = make([]byte, 130)
+	copy(sig[65-len(r.Bytes()):], r.Bytes())
+	copy(sig[130-len(s.Bytes()):], s.Bytes())
+
+	if err = ser.compareSign(dat, sig); !errors.Is(err, ErrBadSign) {
+		t.Errorf("expect ErrBadSign for legacy Signature, got (%v)", err)
+	}
+}
+
+func TestEdDSASign(t *testing.T) {
+	var ser = (&cryptoSerializer{
+		SignMethod: SignEdDSA,
+		key:        tEdDSAKey,
+	}).setPublic(tEdDSAKey.Public())
+
+	testCryptoSign(t, ser)
+
+	// Ed25519 Signatures are deterministic; signing the same Payload twice
+	// yields the same Signature
+	t.Run("Deterministic", func(t *testing.T) {
+		var b0, b1 bytes.Buffer
+		var dat = tRandBuf[:256]
+
+		if err := ser.writeSign(dat, &b0); nil != err {
+			t.Fatal(err)
+		} else if err = ser.writeSign(dat, &b1); nil != err {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b0.Bytes(), b1.Bytes()) {
+			t.Error("Ed25519 signatures of the same Payload do not match")
+		}
 	})
 
 	return
@@ -51,7 +139,10 @@ func testCryptoSign(t *testing.T, ser *cryptoSerializer) {
 	case *rsa.PrivateKey:
 		sN = ser.key.(*rsa.PrivateKey).N.BitLen() / 8
 	case *ecdsa.PrivateKey:
-		sN = ser.key.(*ecdsa.PrivateKey).Curve.Params().P.BitLen() / 4
+		sN = (ser.key.(*ecdsa.PrivateKey).Curve.Params().P.BitLen() + 7) /
+			8 * 2
+	case ed25519.PrivateKey:
+		sN = ed25519.SignatureSize
 	}
 
 	if n := buf.Len(); n != sN {
@@ -62,4 +153,74 @@ func testCryptoSign(t *testing.T, ser *cryptoSerializer) {
 	if err := ser.compareSign(dat, buf.Bytes()); nil != err {
 		t.Errorf("bad signature written by writeSign (%v)", err)
 	}
+
+	if err := ser.compareSign(dat[1:],
+		buf.Bytes()); !errors.Is(err, ErrBadSign) {
+		t.Errorf("expect ErrBadSign for mismatched Payload, got (%v)", err)
+	}
+}
+
+func TestCryptoSigner(t *testing.T) {
+	var base = func(m SignMethod, k crypto.Signer,
+		h crypto.Hash, opts crypto.SignerOpts) func(*testing.T) {
+		return func(t *testing.T) {
+			var sg = &tSigner{key: k}
+			var ser, native Serializer
+			var p tPayloadT
+			var s string
+			var err error
+
+			if ser, err = New(m, sg, h); nil != err {
+				t.Fatal(err)
+			} else if native, err = New(m, k, h); nil != err {
+				t.Fatal(err)
+			}
+
+			if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(sg.opts, opts) {
+				t.Errorf("unexpected SignerOpts passed to Sign"+
+					"\nexp: %#v"+
+					"\nret: %#v", opts, sg.opts)
+			}
+
+			// StringTokens are interchangeable with those of the native key
+			if err = native.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			if s, err = native.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			} else if err = ser.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			}
+
+			// the public key is only resolved by New
+			if 1 != sg.pubs {
+				t.Errorf("expect 1 call to Public, got %d", sg.pubs)
+			}
+		}
+	}
+
+	t.Run("SignRSA", base(SignRSA, tRSAKey, crypto.SHA256, crypto.SHA256))
+	t.Run("SignPSS", base(SignPSS, tRSAKey, crypto.SHA256, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256,
+	}))
+	t.Run("SignECDSA", base(SignECDSA, tECDSAKey, crypto.SHA256, crypto.SHA256))
+	t.Run("SignEdDSA", base(SignEdDSA, tEdDSAKey, 0, crypto.Hash(0)))
+
+	// the public key of the Signer must match the sign-method
+	t.Run("Mismatch", func(t *testing.T) {
+		if ser, err := New(SignRSA, &tSigner{key: tECDSAKey},
+			crypto.SHA256); nil == err || nil != ser {
+			t.Error("expect error for ECDSA Signer with SignRSA")
+		}
+
+		if ser, err := New(SignEdDSA, &tSigner{key: tRSAKey},
+			0); nil == err || nil != ser {
+			t.Error("expect error for RSA Signer with SignEdDSA")
+		}
+	})
 }
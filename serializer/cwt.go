@@ -0,0 +1,296 @@
+package serializer
+
+import (
+	"bytes"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// the CBOR tags of a CBOR Web Token (see RFC 8392, sec. 6) and of the COSE
+// structures that carry it (see RFC 8152, sec. 2)
+const (
+	tagCWT   = 61
+	tagMac0  = 17
+	tagSign1 = 18
+)
+
+// coseAlgs are the COSE algorithm identifiers (see RFC 8152, sec. 8 and 9,
+// RFC 8230, sec. 2 and RFC 8812, sec. 2) of the JWS algorithms (see jwsAlg)
+// that a cwtSerializer supports.
+var coseAlgs = map[string]int64{
+	"HS256": 5, "HS384": 6, "HS512": 7,
+	"RS256": -257, "RS384": -258, "RS512": -259,
+	"PS256": -37, "PS384": -38, "PS512": -39,
+	"ES256": -7, "ES384": -35, "ES512": -36,
+	"EdDSA": -8,
+}
+
+// coseEncMode encodes the COSE structures and their protected Headers in the
+// Core Deterministic Encoding (see RFC 8949, sec. 4.2.1), so that the bytes
+// that are signed do not depend on the order of map keys.
+var coseEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// BinarySerializer is a Serializer whose tokens are binary, and that can
+// generate and parse them as raw byte-slices, without the Base64 encoding of
+// the string form. It is implemented by the Serializer returned by NewCWT.
+type BinarySerializer interface {
+	Serializer
+
+	// SerializeBinary is as Serialize, but returns the raw bytes of the token.
+	SerializeBinary(token interface{}) (b []byte, err error)
+
+	// DeserializeBinary is as Deserialize, but takes the raw bytes of the
+	// token; b is neither modified nor retained.
+	DeserializeBinary(b []byte, token interface{}) (err error)
+}
+
+// cwtSerializer is an internal implementation of Serializer returned by the
+// NewCWT function. It generates and parses CBOR Web Tokens (RFC 8392) in a
+// COSE_Mac0 or COSE_Sign1 structure (RFC 8152), i.e. the tagged CBOR array
+//
+//     [Protected, Unprotected, Payload, Signature]
+//
+// where Protected is the CBOR encoded Header map with the algorithm,
+// Unprotected a Header map with the key ID (if any), Payload the CBOR encoded
+// claims, and the Signature is computed from the MAC_structure or
+// Sig_structure of the first three.
+type cwtSerializer struct {
+	keys keySource
+}
+
+// coseMessage is a COSE_Mac0 or COSE_Sign1 structure, without its tag.
+type coseMessage struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected coseHeader
+	Payload     []byte
+	Signature   []byte
+}
+
+// coseHeader is a COSE Header map (see RFC 8152, sec. 3.1).
+type coseHeader struct {
+	Alg  int64         `cbor:"1,keyasint,omitempty"`
+	Crit []interface{} `cbor:"2,keyasint,omitempty"`
+	Kid  []byte        `cbor:"4,keyasint,omitempty"`
+}
+
+// NewCWT returns a Serializer that generates and parses CBOR Web Tokens (RFC
+// 8392), for clients that cannot afford the size of text tokens. Tokens with
+// a SignHMAC key are COSE_Mac0 structures, those with any other key are
+// COSE_Sign1 structures (RFC 8152), and the returned Serializer also
+// implements BinarySerializer for their raw bytes. The string form is the
+// Base64 (url-safe, unpadded) encoding of the raw bytes.
+//
+// ser provides the keys as for NewJWT, and the COSE algorithm is derived from
+// the method and hash of each key in the same way, for example SignHMAC with
+// crypto.SHA256 is "HMAC 256/256" and SignEdDSA is "EdDSA". With a KeyRing
+// the key ID of the active key is written to the unprotected Header, and read
+// back to pick the verifying key.
+//
+// The token passed to Serialize and Deserialize is CBOR encoded (see
+// fxamacker/cbor) to get the claims of the CWT; token.Token maps its fields
+// to the registered claims "cti", "sub", "iss", "aud", "iat", "nbf" and "exp".
+// Deserialize does not validate the claims themselves. The outer CWT tag is
+// accepted, but not generated.
+func NewCWT(ser Serializer) (Serializer, error) {
+	var sr = &cwtSerializer{}
+	var err error
+
+	if sr.keys, err = newKeySource(ser, sr); nil != err {
+		return nil, err
+	}
+
+	if k, ok := sr.keys.(singleKey); ok {
+		if _, _, err = coseAlg(k.signer); nil != err {
+			return nil, err
+		}
+	}
+
+	return sr, nil
+}
+
+// Serialize makes cwtSerializer implement the Serializer interface.
+func (sr *cwtSerializer) Serialize(token interface{}) (s string, err error) {
+	var b []byte
+
+	if b, err = sr.SerializeBinary(token); nil != err {
+		return
+	}
+
+	return jwtEncoding.EncodeToString(b), nil
+}
+
+// Deserialize makes cwtSerializer implement the Serializer interface.
+func (sr *cwtSerializer) Deserialize(
+	s string, token interface{}) (err error) {
+
+	var b []byte
+
+	// the length limit applies to the binary CWT, see DeserializeBinary
+	if len(s) > jwtEncoding.EncodedLen(sr.keys.maxLength()) {
+		return decodeError(StageHeader, ErrTooLarge)
+	}
+
+	if b, err = jwtEncoding.DecodeString(s); nil != err {
+		return formatError(StageHeader, err)
+	}
+
+	return sr.DeserializeBinary(b, token)
+}
+
+// SerializeBinary makes cwtSerializer implement the BinarySerializer
+// interface.
+func (sr *cwtSerializer) SerializeBinary(
+	token interface{}) (b []byte, err error) {
+
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var m coseMessage
+	var h coseHeader
+	var sg signer
+	var kid string
+	var tag uint64
+	var tbs []byte
+
+	if kid, sg, err = sr.keys.signKey(); nil != err {
+		return
+	}
+
+	if h.Alg, tag, err = coseAlg(sg); nil != err {
+		return
+	}
+
+	if m.Protected, err = coseEncMode.Marshal(&h); nil != err {
+		return
+	}
+
+	if m.Payload, err = cbor.Marshal(token); nil != err {
+		return
+	}
+
+	if tbs, err = coseToBeSigned(tag, &m); nil != err {
+		return
+	}
+
+	if err = sg.writeSign(tbs, buf); nil != err {
+		return
+	}
+
+	if 0 != len(kid) {
+		m.Unprotected.Kid = []byte(kid)
+	}
+
+	m.Signature = buf.Bytes()
+	return coseEncMode.Marshal(cbor.Tag{Number: tag, Content: &m})
+}
+
+// DeserializeBinary makes cwtSerializer implement the BinarySerializer
+// interface. The CWT is verified, and its claims unpacked, within the limits
+// of the Options of the verifying key; the length limit applies to the binary
+// CWT (see Options.MaxLength).
+func (sr *cwtSerializer) DeserializeBinary(
+	b []byte, token interface{}) (err error) {
+
+	var raw cbor.RawTag
+	var m coseMessage
+	var h coseHeader
+	var sg signer
+	var o *Options
+	var alg string
+	var tbs []byte
+
+	// no key accepts a CWT longer than the longest limit, which is checked
+	// before anything is decoded; the limit of the key follows below
+	if len(b) > sr.keys.maxLength() {
+		return decodeError(StageHeader, ErrTooLarge)
+	}
+
+	if err = cbor.Unmarshal(b, &raw); nil != err {
+		return formatError(StageHeader, err)
+	}
+
+	// the CWT tag is optional, and may only wrap a COSE tag
+	if tagCWT == raw.Number {
+		var content = raw.Content
+
+		raw = cbor.RawTag{}
+		if err = cbor.Unmarshal(content, &raw); nil != err {
+			return formatError(StageHeader, err)
+		}
+	}
+
+	if tagMac0 != raw.Number && tagSign1 != raw.Number {
+		return decodeError(StageHeader, ErrBadFormat)
+	} else if err = cbor.Unmarshal(raw.Content, &m); nil != err {
+		return formatError(StageHeader, err)
+	} else if err = cbor.Unmarshal(m.Protected, &h); nil != err {
+		return formatError(StageHeader, err)
+	}
+
+	// no COSE extensions are understood, so any "crit" parameter is
+	// rejected, and the algorithm must be protected (see RFC 8152, sec. 3.1)
+	if nil != h.Crit || nil != m.Unprotected.Crit ||
+		0 != m.Unprotected.Alg {
+		return decodeError(StageHeader, ErrBadFormat)
+	}
+
+	if sg, err = sr.keys.verifyKey(string(m.Unprotected.Kid)); nil != err {
+		return decodeError(StageHeader, err)
+	}
+
+	// the algorithm is bound to the key, not to the token, and so is the
+	// structure, as a COSE_Mac0 cannot be verified with a public key
+	if alg, err = jwsAlg(sg); nil != err {
+		return decodeError(StageHeader, ErrBadAlg)
+	} else if a, t, _ := coseAlg(sg); a != h.Alg || t != raw.Number {
+		return withAlg(decodeError(StageHeader, ErrBadAlg), alg)
+	}
+
+	if o = sg.options(); nil != o.checkLength(len(b)) {
+		return withAlg(decodeError(StageHeader, ErrTooLarge), alg)
+	}
+
+	if tbs, err = coseToBeSigned(raw.Number, &m); nil != err {
+		return withAlg(formatError(StageHeader, err), alg)
+	}
+
+	if err = sg.compareSign(tbs, m.Signature); nil != err {
+		return withAlg(decodeError(StageSignature, err), alg)
+	}
+
+	return withAlg(decodeError(StageCodec,
+		o.limits().unmarshal(CBOR, m.Payload, token)), alg)
+}
+
+// coseAlg returns the COSE algorithm identifier of the signer sg, and the
+// tag of the COSE structure it signs, or an error if the method and hash of
+// sg do not match any COSE algorithm.
+func coseAlg(sg signer) (alg int64, tag uint64, err error) {
+	var name string
+
+	if name, err = jwsAlg(sg); nil != err {
+		return
+	}
+
+	if m, _ := sg.algorithm(); SignHMAC == m {
+		return coseAlgs[name], tagMac0, nil
+	}
+
+	return coseAlgs[name], tagSign1, nil
+}
+
+// coseToBeSigned returns the bytes that the Signature of the COSE structure
+// m is computed from, i.e. the MAC_structure (see RFC 8152, sec. 6.3) or the
+// Sig_structure (see RFC 8152, sec. 4.4), without external data.
+func coseToBeSigned(tag uint64, m *coseMessage) ([]byte, error) {
+	var context = "Signature1"
+
+	if tagMac0 == tag {
+		context = "MAC0"
+	}
+
+	return coseEncMode.Marshal([]interface{}{
+		context, m.Protected, []byte{}, m.Payload,
+	})
+}
@@ -0,0 +1,203 @@
+package serializer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// tCWTClaimsT is a test token with the integer keys of CWT claims.
+type tCWTClaimsT struct {
+	Iss string `cbor:"1,keyasint,omitempty"`
+	Sub string `cbor:"2,keyasint,omitempty"`
+	Aud string `cbor:"3,keyasint,omitempty"`
+	Exp int64  `cbor:"4,keyasint,omitempty"`
+	Nbf int64  `cbor:"5,keyasint,omitempty"`
+	Iat int64  `cbor:"6,keyasint,omitempty"`
+	Cti []byte `cbor:"7,keyasint,omitempty"`
+}
+
+func TestNewCWT(t *testing.T) {
+	var noneSer, _ = New(SignNone, nil, 0)
+
+	if ser, err := NewCWT(noneSer); nil == err || nil != ser {
+		t.Error("expect error for SignNone Serializer")
+	}
+
+	if ser, _ := New(SignHMAC, tRandBuf[:256], crypto.SHA224); nil == ser {
+		t.Fatal("cannot construct HMAC-SHA224 Serializer")
+	} else if ser, err := NewCWT(ser); nil == err || nil != ser {
+		t.Error("expect error for HMAC-SHA224 (no COSE algorithm)")
+	}
+}
+
+func TestCWT(t *testing.T) {
+	var claims = tCWTClaimsT{Iss: "coap://as.example.com", Sub: "erikw",
+		Exp: 1444064944, Iat: 1443944944, Cti: []byte{0x0b, 0x71}}
+
+	var base = func(m SignMethod, k interface{},
+		h crypto.Hash, tag uint64, alg int64) func(*testing.T) {
+		return func(t *testing.T) {
+			var ser, _ = New(m, k, h)
+			var cwt, err = NewCWT(ser)
+			var raw cbor.RawTag
+			var msg coseMessage
+			var hdr coseHeader
+			var p tCWTClaimsT
+			var b []byte
+			var s string
+
+			if nil != err {
+				t.Fatal(err)
+			}
+
+			if b, err = cwt.(BinarySerializer).SerializeBinary(
+				&claims); nil != err {
+				t.Fatal(err)
+			}
+
+			if err = cbor.Unmarshal(b, &raw); nil != err {
+				t.Fatal(err)
+			} else if tag != raw.Number {
+				t.Errorf("expect tag %d, got %d", tag, raw.Number)
+			} else if err = cbor.Unmarshal(raw.Content, &msg); nil != err {
+				t.Fatal(err)
+			} else if cbor.Unmarshal(msg.Protected, &hdr); alg != hdr.Alg {
+				t.Errorf("expect alg %d, got %d", alg, hdr.Alg)
+			}
+
+			if err = cwt.(BinarySerializer).DeserializeBinary(b,
+				&p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(claims, p) {
+				t.Errorf("expect %+v, got %+v", claims, p)
+			}
+
+			// the string form is the Base64 encoding of the binary form
+			if s, err = cwt.Serialize(&claims); nil != err {
+				t.Fatal(err)
+			} else if p = (tCWTClaimsT{}); nil != cwt.Deserialize(s, &p) {
+				t.Error(cwt.Deserialize(s, &p))
+			} else if !reflect.DeepEqual(claims, p) {
+				t.Errorf("expect %+v, got %+v", claims, p)
+			}
+
+			// any modification of the token invalidates it
+			for i := range b {
+				b[i] ^= 0x40
+
+				if err = cwt.(BinarySerializer).DeserializeBinary(b,
+					&p); nil == err {
+					t.Errorf("byte %d: expect error for modified token", i)
+				}
+
+				b[i] ^= 0x40
+			}
+		}
+	}
+
+	t.Run("HMAC256", base(SignHMAC, tRandBuf[:256], crypto.SHA256,
+		tagMac0, 5))
+	t.Run("HMAC512", base(SignHMAC, tRandBuf[:256], crypto.SHA512,
+		tagMac0, 7))
+	t.Run("ES256", base(SignECDSA, tECDSAKey, crypto.SHA256, tagSign1, -7))
+	t.Run("PS256", base(SignPSS, tRSAKey, crypto.SHA256, tagSign1, -37))
+	t.Run("EdDSA", base(SignEdDSA, tEdDSAKey, 0, tagSign1, -8))
+
+	// the signed CWT of RFC 8392, appendix A.3, with the key of A.2.3
+	t.Run("RFC8392", func(t *testing.T) {
+		var b, _ = hex.DecodeString("d28443a10126a104524173796d6d657472" +
+			"696345434453413235365850a70175636f61703a2f2f61732e657861" +
+			"6d706c652e636f6d02656572696b77037818636f61703a2f2f6c6967" +
+			"68742e6578616d706c652e636f6d041a5612aeb0051a5610d9f0061a" +
+			"5610d9f007420b7158405427c1ff28d23fbad1f29c4c7c6a555e601d" +
+			"6fa29f9179bc3d7438bacaca5acd08c8d4d4f96131680c429a01f859" +
+			"51ecee743a52b9b63632c57209120e1c9e30")
+		var x, _ = new(big.Int).SetString("143329cce7868e416927599cf65a3"+
+			"4f3ce2ffda55a7eca69ed8919a394d42f0f", 16)
+		var y, _ = new(big.Int).SetString("60f7f1a780d8a783bfb7a2dd6b279"+
+			"6e8128dbbcef9d3d168db9529971a36e7b9", 16)
+		var pub = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		var ver, _ = NewVerifier(SignECDSA, pub, crypto.SHA256)
+		var cwt, err = NewCWT(ver)
+		var p tCWTClaimsT
+
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		if err = cwt.(BinarySerializer).DeserializeBinary(b,
+			&p); nil != err {
+			t.Fatal(err)
+		}
+
+		claims.Aud = "coap://light.example.com"
+		claims.Nbf = claims.Iat
+
+		if !reflect.DeepEqual(claims, p) {
+			t.Errorf("expect %+v, got %+v", claims, p)
+		}
+
+		// the CWT tag may wrap the COSE_Sign1
+		if err = cwt.(BinarySerializer).DeserializeBinary(append(
+			[]byte{0xd8, tagCWT}, b...), &p); nil != err {
+			t.Error(err)
+		}
+
+		if _, err = cwt.Serialize(&p); !errors.Is(err, ErrVerifyOnly) {
+			t.Errorf("expect ErrVerifyOnly, got %v", err)
+		}
+	})
+
+	t.Run("KeyRing", func(t *testing.T) {
+		var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+		var ecdsaSer, _ = New(SignECDSA, tECDSAKey, crypto.SHA256)
+		var kr = NewKeyRing()
+		var cwt, _ = NewCWT(kr)
+		var p tCWTClaimsT
+		var s, mac string
+		var err error
+
+		kr.Add("k0", hmacSer)
+		kr.Add("k1", ecdsaSer)
+		kr.Activate("k0")
+
+		if mac, err = cwt.Serialize(&claims); nil != err {
+			t.Fatal(err)
+		}
+
+		kr.Activate("k1")
+
+		if s, err = cwt.Serialize(&claims); nil != err {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{mac, s} {
+			if err = cwt.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			}
+		}
+
+		// a COSE_Mac0 cannot be verified with the public key of k1
+		var b, _ = jwtEncoding.DecodeString(mac)
+		var raw cbor.RawTag
+		var msg coseMessage
+
+		cbor.Unmarshal(b, &raw)
+		cbor.Unmarshal(raw.Content, &msg)
+		msg.Unprotected.Kid = []byte("k1")
+		b, _ = cbor.Marshal(cbor.Tag{Number: tagMac0, Content: &msg})
+
+		if err = cwt.(BinarySerializer).DeserializeBinary(b,
+			&p); !errors.Is(err, ErrBadAlg) {
+			t.Errorf("expect ErrBadAlg, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package serializer
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// paramLabel is the name of the Header parameter that carries the label that
+// the signing key of a StringToken was derived for (see NewDerived).
+const paramLabel = "ctx"
+
+// derivedSerializer is an internal implementation of the Serializer interface
+// that is returned by NewDerived. It signs StringTokens like hmacSerializer,
+// with a key derived from a master key for the label in their Header.
+type derivedSerializer struct {
+	master []byte
+	hash   crypto.Hash
+	label  string
+	hs     *hmacSerializer // derived for label, nil if label is empty
+	opts   Options
+}
+
+// NewDerived returns a Serializer that signs StringTokens with SignHMAC and
+// hash, using a key derived from master for label with HKDF (RFC 5869), so
+// that a single master key serves any number of tenants or audiences, each
+// with a key of its own. master is subject to the same minimum length as the
+// keys of SignHMAC (256 bytes), and label may only contain the characters
+// allowed in a key ID (see KeyRing.Add).
+//
+// label is recorded in the Header of generated StringTokens
+// ("auth;ctx=tenant-1"), and the key is derived again from the label in the
+// Header during deserialization. StringTokens of any label other than label
+// fail to deserialize with ErrUnknownKey, and so never verify for another
+// tenant. If label is empty, the Serializer accepts StringTokens of every
+// label, which can be read with Inspect afterwards, but cannot generate any
+// (ErrVerifyOnly).
+func NewDerived(master []byte,
+	hash crypto.Hash, label string) (Serializer, error) {
+
+	var sr = &derivedSerializer{master: master, hash: hash, label: label}
+	var err error
+
+	if !hash.Available() {
+		return nil, errorf(efBadHash, "", hash)
+	}
+
+	if len(master) < hmacKeyMinLen {
+		return nil, errorf(efBadKeyLen,
+			"", fmt.Sprintf("%d bytes", hmacKeyMinLen))
+	}
+
+	if 0 == len(label) {
+		return sr, nil
+	} else if !validParam(label) {
+		return nil, errorf(efBadLabel, "", label)
+	}
+
+	if sr.hs, err = sr.derive(label); nil != err {
+		return nil, err
+	}
+
+	return sr, nil
+}
+
+// Serialize makes derivedSerializer implement the Serializer interface.
+func (sr *derivedSerializer) Serialize(
+	token interface{}) (s string, err error) {
+	return sr.SerializeAD(token, nil)
+}
+
+// Deserialize makes derivedSerializer implement the Serializer interface.
+func (sr *derivedSerializer) Deserialize(
+	s string, token interface{}) (err error) {
+	return sr.DeserializeAD(s, token, nil)
+}
+
+// SerializeAD makes derivedSerializer implement the ADSerializer interface.
+func (sr *derivedSerializer) SerializeAD(
+	token interface{}, ad []byte) (s string, err error) {
+
+	if nil == sr.hs {
+		return "", ErrVerifyOnly
+	}
+
+	var alg = algName(SignHMAC, sr.hash)
+	var ps = append([]param{{paramLabel, sr.label}}, sr.opts.params(alg)...)
+
+	return headerSerialize(formatHeader(ps...), &sr.opts, token,
+		adWriteSign(ad, sr.hs.writeSign))
+}
+
+// DeserializeAD makes derivedSerializer implement the ADSerializer interface.
+func (sr *derivedSerializer) DeserializeAD(
+	s string, token interface{}, ad []byte) (err error) {
+
+	var alg = algName(SignHMAC, sr.hash)
+	var ps []param
+	var body string
+	var hs = sr.hs
+	var c Codec
+
+	if err = sr.opts.checkLength(len(s)); nil != err {
+		return withAlg(err, alg)
+	} else if ps, body, err = parseHeader(s); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	// the label must be the first parameter, and is followed by those of
+	// the Options
+	if 0 == len(ps) || ps[0].name != paramLabel {
+		return withAlg(decodeError(StageHeader, ErrBadFormat), alg)
+	} else if 0 != len(sr.label) && ps[0].value != sr.label {
+		return withAlg(decodeError(StageHeader, ErrUnknownKey), alg)
+	}
+
+	if c, err = sr.opts.decoder(alg, ps[1:]); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	if nil == hs {
+		if hs, err = sr.derive(ps[0].value); nil != err {
+			return withAlg(decodeError(StageHeader, err), alg)
+		}
+	}
+
+	return withAlg(bodyDeserialize(body, c, token,
+		adCompareSign(ad, hs.compareSign)), alg)
+}
+
+// derive returns an hmacSerializer with the key derived from sr.master for
+// label. The key is as long as the minimum length of the keys of SignHMAC.
+func (sr *derivedSerializer) derive(label string) (*hmacSerializer, error) {
+	var key = make([]byte, hmacKeyMinLen)
+	var r = hkdf.New(sr.hash.New, sr.master, nil, []byte(label))
+
+	if _, err := io.ReadFull(r, key); nil != err {
+		return nil, err
+	}
+
+	return &hmacSerializer{key: key, hash: sr.hash}, nil
+}
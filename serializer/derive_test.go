@@ -0,0 +1,102 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewDerived(t *testing.T) {
+	var master = tRandBuf[:256]
+	var t1, _ = NewDerived(master, crypto.SHA256, "tenant-1")
+	var t2, _ = NewDerived(master, crypto.SHA256, "tenant-2")
+	var all, _ = NewDerived(master, crypto.SHA256, "")
+	var other, _ = NewDerived(tRandBuf[256:], crypto.SHA256, "tenant-1")
+	var hmacSer, _ = New(SignHMAC, master, crypto.SHA256)
+	var s1, s2 string
+	var p tPayloadT
+	var err error
+
+	if s1, err = t1.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(s1, "auth;ctx=tenant-1.") {
+		t.Errorf("unexpected Header %.24s", s1)
+	} else if s2, err = t2.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	}
+
+	// the key is derived again, by any Serializer with the same master key
+	var again, _ = NewDerived(master, crypto.SHA256, "tenant-1")
+
+	for _, c := range []struct {
+		ser Serializer
+		s   string
+	}{{t1, s1}, {again, s1}, {all, s1}, {all, s2}} {
+		if err = c.ser.Deserialize(c.s, &p); nil != err {
+			t.Errorf("%.24s: %v", c.s, err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("deserialized payload does not match expectation")
+		}
+	}
+
+	if u, err := Inspect(s2); nil != err {
+		t.Error(err)
+	} else if "tenant-2" != u.Label {
+		t.Errorf("expect label tenant-2, got %q", u.Label)
+	}
+
+	// one tenant's StringTokens never verify for another
+	for _, c := range []struct {
+		ser Serializer
+		s   string
+		err error
+	}{
+		{t1, s2, ErrUnknownKey},
+		{t2, s1, ErrUnknownKey},
+		{all, strings.Replace(s1, "tenant-1", "tenant-2", 1), ErrBadSign},
+		{other, s1, ErrBadSign},
+		{hmacSer, s1, ErrBadFormat},
+		{t1, "auth." + s1[len("auth;ctx=tenant-1."):], ErrBadFormat},
+	} {
+		if err = c.ser.Deserialize(c.s, &p); !errors.Is(err, c.err) {
+			t.Errorf("%.24s: expect %v, got %v", c.s, c.err, err)
+		}
+	}
+
+	if _, err = all.Serialize(tPayload); ErrVerifyOnly != err {
+		t.Errorf("expect ErrVerifyOnly, got %v", err)
+	}
+
+	t.Run("Options", func(t *testing.T) {
+		var ser, _ = WithOptions(t1, Options{Alg: true, Codec: JSON})
+		var ver, _ = WithOptions(all, Options{Accept: []Codec{JSON}})
+		var s, _ = ser.Serialize(tPayload)
+		var exp = "auth;ctx=tenant-1;alg=hmac+sha-256;codec=json."
+
+		if !strings.HasPrefix(s, exp) {
+			t.Errorf("expect Header %s, got %.48s", exp, s)
+		} else if err := ver.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		for _, c := range []struct {
+			master []byte
+			hash   crypto.Hash
+			label  string
+		}{
+			{master[:255], crypto.SHA256, "tenant-1"},
+			{master, crypto.SHA256, "tenant.1"},
+			{master, crypto.Hash(0), "tenant-1"},
+		} {
+			if ser, err := NewDerived(c.master, c.hash,
+				c.label); nil == err || nil != ser {
+				t.Errorf("expect error for %d bytes, hash #%d, label %q",
+					len(c.master), c.hash, c.label)
+			}
+		}
+	})
+}
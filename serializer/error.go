@@ -10,11 +10,36 @@ const (
 
 	// efXxx are common error formats used to construct Errors using errorf.
 	evInternal    = "(internal) "
+	efAlgAllow    = "%salgorithm %q cannot be allowed"
+	efAlgExists   = "%salgorithm %q already in use"
+	efBadCaveat   = "%sbad caveat name %q"
+	efBadCodec    = "%sbad codec (%T)"
 	efBadHash     = "%shash #%d not available"
+	efBadKeyAlg   = "%sJWS algorithm %q cannot be used with key (%T)"
+	efBadKeyData  = "%scannot parse key (%s)"
+	efBadKeyID    = "%sbad key id %q"
 	efBadKeyType  = "%swrong key type; expect (%T)"
 	efBadKeyLen   = "%skey length too short; expect min. %s"
+	efBadKeySize  = "%swrong key length; expect %s"
+	efBadLabel    = "%sbad key label %q"
+	efNoHash      = "%shash #%d not applicable to sign-method #%d"
+	efNoKeyMethod = "%sno sign-method for key (%T)"
 	efBadMethod   = "%ssign-method #%d not available for %T"
+	efBadSerlr    = "%sserializer (%T) cannot be used with %T"
+	efBadSigner   = "%ssigner (%T) returned a malformed signature"
+	efCacheSize   = "%sbad cache size %d"
+	efJWSAlg      = "%ssign-method #%d with hash #%d has no JWS algorithm"
+	efJWSAllow    = "%sJWS algorithm %q cannot be allowed"
+	efKeyActive   = "%skey id %q is the active key"
+	efKeyExists   = "%skey id %q already in use"
+	efKeyMissing  = "%skey id %q not found"
+	efKeyRetired  = "%skey id %q is retired"
+	efNoSigner    = "%skey (%T) does not implement crypto.Signer"
+	efSDClaim     = "%sbad selective claim %q"
+	efSealedData  = "%scannot decode sealed payload"
 	efUndefMethod = "%ssign-method #%d not defined"
+	efUndefSeal   = "%sseal-method #%d not defined"
+	efUndefZip    = "%scompression #%d not defined"
 
 	// ErrBadFormat is returned during deserialization of a StringToken, if the
 	// StringToken does not match the specified format.
@@ -23,8 +48,155 @@ const (
 	// ErrBadSign is returned if the Signature part of a StringToken does not
 	// match the Payload part.
 	ErrBadSign = Error("invalid signature")
+
+	// ErrVerifyOnly is returned by a Serializer that holds only a public key
+	// (see NewVerifier) if asked to generate a StringToken.
+	ErrVerifyOnly = Error("cannot serialize, verify-only serializer")
+
+	// ErrBadAlg is returned during deserialization of a token, if the token
+	// claims a signing algorithm that is not allowed, or that does not match
+	// the key that verifies it.
+	ErrBadAlg = Error("signing algorithm not allowed")
+
+	// ErrBadCodec is returned during deserialization of a StringToken, if its
+	// Payload was encoded with a Codec that the Serializer does not accept.
+	ErrBadCodec = Error("cannot deserialize, codec not accepted")
+
+	// ErrTooLarge is returned during deserialization of a StringToken, if it
+	// exceeds the length limit of the Serializer, or if its Payload or any of
+	// the arrays and maps therein exceed their size limits (see Options).
+	ErrTooLarge = Error("cannot deserialize, payload too large")
+
+	// ErrTooDeep is returned during deserialization of a StringToken, if its
+	// Payload nests arrays and maps deeper than the Serializer allows.
+	ErrTooDeep = Error("cannot deserialize, payload nested too deeply")
+
+	// ErrTrailingData is returned during deserialization of a StringToken by a
+	// Serializer in strict mode, if its binary Payload is followed by
+	// anything but the Signature.
+	ErrTrailingData = Error("cannot deserialize, trailing data in payload")
+
+	// ErrNoActiveKey is returned by a KeyRing if asked to generate a
+	// StringToken before one of its keys was activated.
+	ErrNoActiveKey = Error("cannot serialize, no active key")
+
+	// ErrCaveat is returned during deserialization of a StringToken of a
+	// MacaroonSerializer, if one of its Caveats is unknown or not satisfied.
+	ErrCaveat = Error("caveat not satisfied")
+
+	// ErrUnknownKey is returned during deserialization of a StringToken, if
+	// the key ID in its Header does not match any key that is still valid.
+	ErrUnknownKey = Error("unknown or expired key id")
 )
 
+// Different stages of deserialization at which a token may be rejected (see
+// DecodeError).
+const (
+	StageHeader    Stage = iota // the Header, its parameters and the key ID
+	StagePayload                // Base64 decoding or decompression of Payload
+	StageSignature              // verification of Signature or sealed Payload
+	StageCodec                  // decoding of binary Payload with its Codec
+	StageCaveat                 // checking of Caveats (see MacaroonSerializer)
+	maxStage
+)
+
+// stageNames are the names of the Stages, as written by DecodeError.
+var stageNames = [maxStage]string{"header", "payload-decode", "signature",
+	"codec", "caveat"}
+
+// Stage is an enum type used for the stages of deserialization.
+type Stage uint
+
+// String makes Stage implement the fmt.Stringer interface.
+func (s Stage) String() string {
+	if s < maxStage {
+		return stageNames[s]
+	}
+
+	return fmt.Sprintf("stage #%d", uint(s))
+}
+
+// DecodeError is returned by the Serializers of this package if a token is
+// rejected during deserialization. It records the Stage at which the token was
+// rejected, the algorithm that was to verify it and the underlying cause, if
+// any, e.g.:
+//
+//     auth/serializer: cannot deserialize, malformed input (payload-decode,
+//     alg=eddsa): illegal base64 data at input byte 12
+//
+// Err is one of the Errors of this package, such as ErrBadFormat or
+// ErrBadSign, so that errors.Is(err, ErrBadSign) reports whether a token was
+// rejected for its Signature. Cause is the error that made the token fail, if
+// it was not an Error of this package; use errors.As to inspect it.
+type DecodeError struct {
+	Stage Stage
+	Alg   string
+	Err   error
+	Cause error
+}
+
+// Error implements the builtin error interface.
+func (err *DecodeError) Error() string {
+	var s = fmt.Sprintf("%v (%v", err.Err, err.Stage)
+
+	if 0 != len(err.Alg) {
+		s += ", alg=" + err.Alg
+	}
+
+	if s += ")"; nil != err.Cause {
+		s += ": " + err.Cause.Error()
+	}
+
+	return s
+}
+
+// Unwrap returns the Cause of err, for errors.Is and errors.As.
+func (err *DecodeError) Unwrap() error {
+	return err.Cause
+}
+
+// Is reports whether target is the Err of err, for errors.Is.
+func (err *DecodeError) Is(target error) bool {
+	return target == err.Err
+}
+
+// decodeError returns err as a *DecodeError of the given stage. An Error of
+// this package becomes its Err; any other error becomes its Cause, with Err
+// set to ErrBadSign at StageSignature and to ErrBadFormat otherwise. A
+// *DecodeError is returned as-is, so that its stage is never overwritten.
+func decodeError(stage Stage, err error) error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *DecodeError:
+		return err
+	case Error:
+		return &DecodeError{Stage: stage, Err: err}
+	}
+
+	if StageSignature == stage {
+		return &DecodeError{Stage: stage, Err: ErrBadSign, Cause: err}
+	}
+
+	return formatError(stage, err)
+}
+
+// formatError returns a *DecodeError of the given stage for a token that is
+// malformed (ErrBadFormat) because of cause.
+func formatError(stage Stage, cause error) error {
+	return &DecodeError{Stage: stage, Err: ErrBadFormat, Cause: cause}
+}
+
+// withAlg sets the Alg of err to alg, if err is a *DecodeError without one,
+// and returns err.
+func withAlg(err error, alg string) error {
+	if de, ok := err.(*DecodeError); ok && 0 == len(de.Alg) {
+		de.Alg = alg
+	}
+
+	return err
+}
+
 // Error is a generic type implementing the builtin error interface that may be
 // returned by a Serializer.
 type Error string
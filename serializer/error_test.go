@@ -0,0 +1,82 @@
+package serializer
+
+import (
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeError(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var sealer, _ = NewSealer(SealXChaCha20, tRandBuf[:32])
+	var jwt, _ = NewJWT(hmacSer)
+	var kr = NewKeyRing()
+
+	kr.Add("k0", hmacSer)
+	kr.Activate("k0")
+
+	var s, _ = hmacSer.Serialize(tPayload)
+	var ks, _ = kr.Serialize(tPayload)
+	var ss, _ = sealer.Serialize(tPayload)
+	var js, _ = jwt.Serialize(tPayload)
+	var i = strings.LastIndexByte(s, '.')
+
+	for _, c := range []struct {
+		ser   Serializer
+		s     string
+		stage Stage
+		alg   string
+		err   error
+		cause bool
+	}{
+		{hmacSer, "foo", StageHeader, "hmac+sha-256", ErrBadFormat, false},
+		{hmacSer, "auth;codec=json" + s[4:], StageHeader, "hmac+sha-256",
+			ErrBadCodec, false},
+		{hmacSer, "auth.!" + s[6:], StagePayload, "hmac+sha-256",
+			ErrBadFormat, true},
+		{hmacSer, s[:i] + ".!", StageSignature, "hmac+sha-256",
+			ErrBadFormat, true},
+		{hmacSer, s[:i] + ".AAAA", StageSignature, "hmac+sha-256",
+			ErrBadSign, false},
+		{hmacSer, "auth.AAAA.AAAA", StageSignature, "hmac+sha-256",
+			ErrBadSign, false},
+		{kr, "auth;kid=k1" + ks[11:], StageHeader, "", ErrUnknownKey, false},
+		{kr, ks[:strings.LastIndexByte(ks, '.')] + ".AAAA", StageSignature,
+			"hmac+sha-256", ErrBadSign, false},
+		{sealer, ss[:len(ss)-4] + "AAAA", StageSignature, "XC20P",
+			ErrBadSign, true},
+		{jwt, js[:strings.LastIndexByte(js, '.')] + ".AAAA", StageSignature,
+			"HS256", ErrBadSign, false},
+	} {
+		var p tPayloadT
+		var err = c.ser.Deserialize(c.s, &p)
+		var de *DecodeError
+
+		if !errors.As(err, &de) {
+			t.Errorf("%.24s: expect *DecodeError, got %v", c.s, err)
+		} else if de.Stage != c.stage || de.Alg != c.alg {
+			t.Errorf("%.24s: expect %v, %q, got %v, %q", c.s, c.stage, c.alg,
+				de.Stage, de.Alg)
+		} else if !errors.Is(err, c.err) || (nil != de.Cause) != c.cause {
+			t.Errorf("%.24s: unexpected error %v", c.s, err)
+		}
+	}
+
+	// the cause of a malformed StringToken is kept
+	var ce base64.CorruptInputError
+	var err = hmacSer.Deserialize("auth.!"+s[6:], &tPayloadT{})
+
+	if !errors.As(err, &ce) || 0 != ce {
+		t.Errorf("expect base64.CorruptInputError, got %v", err)
+	} else if exp := "auth/serializer: cannot deserialize, malformed input " +
+		"(payload-decode, alg=hmac+sha-256): illegal base64 data at input " +
+		"byte 0"; exp != err.Error() {
+		t.Errorf("expect %q, got %q", exp, err.Error())
+	}
+
+	if errors.Is(err, ErrBadSign) {
+		t.Error("expect malformed StringToken not to match ErrBadSign")
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"io"
 	"strings"
 	"sync"
-
-	"github.com/vmihailenco/msgpack"
 )
 
 // bufferPool is a package global sync.Pool of bytes.Buffers.
@@ -17,182 +15,357 @@ var bufferPool = sync.Pool{
 	},
 }
 
+// slicePool is a package global sync.Pool of byte-slices that StringTokens are
+// assembled in before they are copied to a string.
+var slicePool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// tokenEncoding is the Base64 encoding of the Payload and Signature parts of
+// a StringToken.
+var tokenEncoding = base64.RawURLEncoding
+
 // genericSerializer is an internal implementation of the Serializer interface
 // that is returned by the NewSerializer function with SignMethod = SignNone.
 // This Serializer does not compute the Signature part of StringToken. Instead
 // just the Payload part is encoded, appended to Header and returned.
-type genericSerializer struct{}
+type genericSerializer struct {
+	opts Options
+}
 
 // Serialize makes genericSerializer implement the Serializer interface.
 func (sr *genericSerializer) Serialize(
 	token interface{}) (s string, err error) {
-	return genericSerialize(token, nil)
+	return genericSerialize(&sr.opts, algName(SignNone, 0), token, nil)
 }
 
 // Deserialize makes genericSerializer implement the Serializer interface.
 func (sr *genericSerializer) Deserialize(
 	s string, token interface{}) (err error) {
-	return genericDeserialize(s, token, nil)
+	return genericDeserialize(s, &sr.opts, algName(SignNone, 0), token, nil)
+}
+
+// AppendSerialize makes genericSerializer implement the BytesSerializer
+// interface.
+func (sr *genericSerializer) AppendSerialize(
+	dst []byte, token interface{}) ([]byte, error) {
+	return genericAppend(dst, &sr.opts, algName(SignNone, 0), token, nil)
+}
+
+// DeserializeBytes makes genericSerializer implement the BytesSerializer
+// interface.
+func (sr *genericSerializer) DeserializeBytes(
+	b []byte, token interface{}) (err error) {
+	return genericDeserializeBytes(b, &sr.opts, algName(SignNone, 0), token,
+		nil)
 }
 
 // genericSerialize encodes the Payload part of a StringToken and calls the
 // writeSign function, if non-nil, to compute the Signature part which is
 // appended to the Payload part and returned.
 //
-// token is msgpack encoded to get the binary form of Payload, which is what is
-// used to compute the Signature. The binary forms of Payload and Signature are
-// Base64 (url-safe) encoded before being arranged into a StringToken and
-// returned.
+// token is encoded with the Codec of opts (msgpack by default) to get the
+// binary form of Payload, which is what is used to compute the Signature. alg
+// is the name of the algorithm that computes the Signature (see algName). The
+// binary forms of Payload and Signature are Base64 (url-safe) encoded before
+// being arranged into a StringToken and returned.
 //
 // This function is written such that it can be plugged directly into the
 // Serialize method of any of the internal Serializer implementations and only
 // the writeSign functions need to be implemented.
-func genericSerialize(payload interface{},
+func genericSerialize(opts *Options, alg string, payload interface{},
 	writeSign func([]byte, io.Writer) error) (s string, err error) {
+	return headerSerialize(formatHeader(opts.params(alg)...), opts, payload,
+		writeSign)
+}
 
-	var buf = bufferPool.Get().(*bytes.Buffer)
-	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+// genericAppend is the same as genericSerialize, except that the StringToken
+// is appended to dst, and the extended byte-slice is returned.
+func genericAppend(dst []byte, opts *Options, alg string, payload interface{},
+	writeSign func([]byte, io.Writer) error) ([]byte, error) {
+	return headerAppend(dst, formatHeader(opts.params(alg)...), opts, payload,
+		writeSign)
+}
+
+// headerSerialize is the same as genericSerialize, except that the Header
+// part of the StringToken is hdr. hdr must include the trailing '.' separator
+// and the parameters of opts (see formatHeader and Options.params), except
+// for the Compression, which is appended if the Payload is compressed.
+func headerSerialize(hdr string, opts *Options, payload interface{},
+	writeSign func([]byte, io.Writer) error) (s string, err error) {
 
-	var b64 = base64.NewEncoder(base64.RawURLEncoding, buf)
-	var mpe = msgpack.NewEncoder(buf)
-	var pos int
+	var b = slicePool.Get().(*[]byte)
+	defer slicePool.Put(b)
 
-	// binary (msgpack) encode the payload and write to buffer
-	if err = mpe.Encode(payload); nil != err {
+	if *b, err = headerAppend((*b)[:0], hdr, opts, payload,
+		writeSign); nil != err {
 		return
 	}
 
-	// note the position where the binary Payload ends (pos)
-	// this position is also where the encoded StringToken begins
-	pos = buf.Len()
+	return string(*b), nil
+}
 
-	// write the Header
-	if _, err = buf.WriteString(header); nil != err {
-		return
-	}
+// headerAppend is the same as headerSerialize, except that the StringToken is
+// appended to dst, and the extended byte-slice is returned. dst is returned
+// unchanged on error.
+func headerAppend(dst []byte, hdr string, opts *Options, payload interface{},
+	writeSign func([]byte, io.Writer) error) ([]byte, error) {
 
-	// Base64 encode PayloadBin and cotinue writing to the buffer following where
-	// Header was written
-	if _, err = b64.Write(buf.Bytes()[:pos]); nil != err {
-		return
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var c = opts.codec()
+	var off, pos, sig int
+	var b []byte
+
+	// write the prefix that binds the Codec and the Compression to the
+	// Signature, and note the position where it ends (off)
+	writeSignPrefix(buf, c)
+	off = buf.Len()
+
+	// binary encode the payload and write to buffer, compressing it if that
+	// saves space, and note the position where the binary Payload ends (pos)
+	if err := c.Encode(buf, payload); nil != err {
+		return dst, err
 	}
 
-	// ensure encoding finishes by calling b64.Close; see docs for base64.Encoder
-	if err = b64.Close(); nil != err {
-		return
+	if ok, err := opts.Compress.compress(buf, off); nil != err {
+		return dst, err
+	} else if ok {
+		hdr = appendParam(hdr,
+			param{paramZip, compressionNames[opts.Compress]})
+
+		// the last byte of the prefix is the Compression
+		buf.Bytes()[off-1] = byte(opts.Compress)
 	}
 
-	// if writeSign callback was provided, write the separator, then use the
-	// callback to:
-	//     - compute Signature using first pos bytes of buffer
-	//     - write the Base64 encoded Signature to buffer following the separator
-	if nil != writeSign {
-		if err = buf.WriteByte('.'); nil != err {
-			return
-		}
+	pos = buf.Len()
+	sig = pos
 
-		b64 = base64.NewEncoder(base64.RawURLEncoding, buf)
-		if err = writeSign(buf.Bytes()[:pos], b64); nil != err {
-			return
-		}
+	// if writeSign callback was provided, use it to compute Signature using
+	// the first pos bytes of buffer, followed by the length of the (empty)
+	// associated data (see appendAD), or just the binary Payload if it needs
+	// no prefix (see signedBytes), and write the binary Signature to the
+	// buffer following them; note the position where it starts (sig)
+	if nil != writeSign {
+		buf.Write(noAD[:])
+		sig = buf.Len()
 
-		if err = b64.Close(); nil != err {
-			return
+		if err := writeSign(signedBytes(buf.Bytes()[:sig]),
+			buf); nil != err {
+			return dst, err
 		}
 	}
 
 	// at this point the buffer looks like this:
-	//     [PayloadBin][Header].[PayloadB64].[SignatureB64]
+	//     [Prefix][PayloadBin][ADLength][SignatureBin]
 	//
-	// the first part (PayloadBin) was written and preserved just so Signature
-	// could be calculated using that, and is no longer needed; the returned
-	// string token starts at Header, to the end of the buffer
+	// the Header is appended to dst, followed by the Base64 encoded Payload
+	// and Signature, if any, so that dst only grows once
+	b = buf.Bytes()
+	dst = growSlice(dst, len(hdr)+tokenEncoding.EncodedLen(pos-off)+
+		1+tokenEncoding.EncodedLen(len(b)-sig))
+
+	dst = append(dst, hdr...)
+	dst = appendBase64(dst, b[off:pos])
+
+	if nil != writeSign {
+		dst = append(dst, '.')
+		dst = appendBase64(dst, b[sig:])
+	}
 
-	return string(buf.Bytes()[pos:]), nil
+	return dst, nil
 }
 
 // genericDeserialize decodes a StringToken, verifying the Signature part by
 // calling compareSign, if compareSign is not nil, and unpacking the binary
-// (msgpack encoded) Payload to the payload argument.
+// Payload to the payload argument, using the Codec named in the Header if it
+// is accepted by opts. If the Header names an algorithm, it must be alg. s is
+// checked against the length limit of opts before anything else.
 //
 // This function is written such that it can be plugged directly into the
 // Deserialize method of any of the internal Serializer implementations and only
 // the compareSign functions need to be implemented.
-func genericDeserialize(s string, payload interface{},
+func genericDeserialize(s string, opts *Options, alg string,
+	payload interface{}, compareSign func([]byte, []byte) error) (err error) {
+
+	const H = len(header)
+
+	var ps []param
+	var c Codec
+
+	if err = opts.checkLength(len(s)); nil != err {
+		return withAlg(err, alg)
+	}
+
+	// StringTokens without Header parameters are the common case
+	if len(s) > H && strings.HasPrefix(s, header) {
+		s = s[H:]
+	} else if ps, s, err = parseHeader(s); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	if c, err = opts.decoder(alg, ps); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	return withAlg(bodyDeserialize(s, c, payload, compareSign), alg)
+}
+
+// bodyDeserialize is the same as genericDeserialize, except that s is the
+// part of a StringToken that follows the Header and its separator, i.e. the
+// Header must have already been parsed and checked by the caller, and the
+// Payload is decoded with the Codec c.
+//
+// s is copied to a pooled byte-slice and decoded by bodyDeserializeBytes; the
+// copy is cheaper than converting s with []byte(s), which allocates.
+func bodyDeserialize(s string, c Codec, payload interface{},
 	compareSign func([]byte, []byte) error) (err error) {
 
+	var b = slicePool.Get().(*[]byte)
+	defer slicePool.Put(b)
+
+	*b = append((*b)[:0], s...)
+	return bodyDeserializeBytes(*b, c, payload, compareSign)
+}
+
+// genericDeserializeBytes is the same as genericDeserialize, except that the
+// StringToken is held in the byte-slice b, which is not modified or retained.
+func genericDeserializeBytes(b []byte, opts *Options, alg string,
+	payload interface{}, compareSign func([]byte, []byte) error) (err error) {
+
 	const H = len(header)
 
-	var i int
-	var buf *bytes.Buffer
-	var p, sig []byte
-	var mpd *msgpack.Decoder
-	var wg sync.WaitGroup
-	var goB64Decode = func(s string, b *[]byte) {
-		defer wg.Done()
-		if *b, err = base64.RawURLEncoding.DecodeString(s); nil != err {
-			return
-		}
+	var ps []param
+	var c Codec
+
+	if err = opts.checkLength(len(b)); nil != err {
+		return withAlg(err, alg)
 	}
 
-	if len(s) <= H || !strings.HasPrefix(s, header) {
-		return ErrBadFormat
+	// the conversion in the comparison does not allocate
+	if len(b) > H && string(b[:H]) == header {
+		b = b[H:]
+	} else if ps, b, err = parseHeaderBytes(b); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
 	}
-	s = s[H:]
 
-	// if a compareSign method was not provided, StringToken may not contain
-	// the Signature part; so just decode Base64->msgpack->{payload} and return
-	if nil == compareSign {
-		var b64 io.Reader
+	if c, err = opts.decoder(alg, ps); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
 
-		buf = bytes.NewBufferString(s)
-		defer func() { buf.Reset(); bufferPool.Put(buf) }()
+	return withAlg(bodyDeserializeBytes(b, c, payload, compareSign), alg)
+}
 
-		b64 = base64.NewDecoder(base64.RawURLEncoding, buf)
-		mpd = msgpack.NewDecoder(b64)
+// bodyDeserializeBytes is the same as bodyDeserialize, except that the part of
+// the StringToken following the Header is held in the byte-slice b.
+//
+// The Payload and Signature parts are Base64 decoded one after the other into
+// a single pooled buffer, so that no memory is allocated for them. Decoding
+// them concurrently does not pay off for StringTokens of a few hundred bytes.
+//
+// Errors are returned as *DecodeErrors without an algorithm (see withAlg).
+func bodyDeserializeBytes(b []byte, c Codec, payload interface{},
+	compareSign func([]byte, []byte) error) (err error) {
+
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var i = bytes.IndexByte(b, '.')
+	var off, pos int
+
+	if 0 == len(b) {
+		return decodeError(StagePayload, ErrBadFormat)
+	}
+
+	// if a compareSign method was not provided, StringToken may not contain
+	// the Signature part; so just decode Base64->Codec->{payload} and return
+	if nil == compareSign {
+		if i >= 0 {
+			b = b[:i]
+		}
 
-		err = mpd.Decode(payload)
-		if _, ok := err.(base64.CorruptInputError); ok {
-			err = ErrBadFormat
+		if err = decodeBase64(buf, b); nil != err {
+			return formatError(StagePayload, err)
 		}
 
-		return
+		return decodeError(StageCodec, c.Decode(buf, payload))
 	}
 
 	// if we're here, the StringToken MUST contain a Signature part
-	if i = strings.IndexByte(s, '.'); i < 0 || i == len(s)-1 {
-		return ErrBadFormat
+	if i < 0 || i == len(b)-1 {
+		return decodeError(StageSignature, ErrBadFormat)
 	}
 
-	// Base64 decode the Payload and Signature part of StringToken concurrently
-	wg.Add(2)
-	go goB64Decode(s[:i], &p)
-	go goB64Decode(s[i+1:], &sig)
-	if wg.Wait(); nil != err {
-		if _, ok := err.(base64.CorruptInputError); ok {
-			return ErrBadFormat
-		}
+	// decode into the buffer so that it looks like this:
+	//     [Prefix][PayloadBin][ADLength][SignatureBin]
+	writeSignPrefix(buf, c)
+	off = buf.Len()
 
-		return
+	if err = decodeBase64(buf, b[:i]); nil != err {
+		return formatError(StagePayload, err)
 	}
 
-	// once Signature is Base64 decoded, call compareSign to verify the binary
-	// Signature against binary Payload
-	if err = compareSign(p, sig); nil != err {
-		return
+	pos = buf.Len()
+	buf.Write(noAD[:])
+
+	if err = decodeBase64(buf, b[i+1:]); nil != err {
+		return formatError(StageSignature, err)
 	}
 
-	// PERF NOTE:
-	// when a bytes.Buffer is constructed from a []byte, the Buffer takes control
-	// of the []byte, which means the original alloc for the []byte can be reused
-	// with a sync.Pool, reducing load from GC and save memory; BUT... this must
-	// not be done unless we are POSITIVE the []byte will not be in use after the
-	// function returns
-	buf = bytes.NewBuffer(p)
-	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+	if err = compareSign(signedBytes(buf.Bytes()[:pos+adLenSize]),
+		buf.Bytes()[pos+adLenSize:]); nil != err {
+		return decodeError(StageSignature, err)
+	}
+
+	// if Signature checks out, we decode binary Payload into payload
+	buf.Truncate(pos)
+	buf.Next(off)
+
+	return decodeError(StageCodec, c.Decode(buf, payload))
+}
+
+// decodeBase64 decodes the Base64 (url-safe, unpadded) encoded b and writes
+// the result to buf. A base64.CorruptInputError is returned if b is not
+// properly encoded.
+func decodeBase64(buf *bytes.Buffer, b []byte) (err error) {
+	var l, n = buf.Len(), tokenEncoding.DecodedLen(len(b))
+
+	// decode to the unused capacity of buf, which is grown beforehand, so the
+	// Write merely extends buf over the decoded bytes
+	buf.Grow(n)
 
-	// if Signature checks out, we msgpack decode binary Payload into payload
-	mpd = msgpack.NewDecoder(buf)
-	err = mpd.Decode(payload)
+	var d = buf.Bytes()[l : l+n]
+	if n, err = tokenEncoding.Decode(d, b); nil != err {
+		return
+	}
+
+	buf.Write(d[:n])
 	return
 }
+
+// appendBase64 appends the Base64 (url-safe, unpadded) encoding of b to dst
+// and returns the extended byte-slice.
+func appendBase64(dst, b []byte) []byte {
+	var l, n = len(dst), tokenEncoding.EncodedLen(len(b))
+
+	dst = growSlice(dst, n)[:l+n]
+	tokenEncoding.Encode(dst[l:], b)
+
+	return dst
+}
+
+// growSlice returns b, with its capacity grown if needed to fit another n
+// bytes.
+func growSlice(b []byte, n int) []byte {
+	if cap(b)-len(b) >= n {
+		return b
+	}
+
+	var c = make([]byte, len(b), 2*cap(b)+n)
+	copy(c, b)
+
+	return c
+}
@@ -2,16 +2,21 @@ package serializer
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"io"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
 func TestGenericSerialize(t *testing.T) {
 	t.Run("CbNone", func(t *testing.T) {
-		if s, err := genericSerialize(tPayload, nil); nil != err {
+		if s, err := genericSerialize(&Options{}, "", tPayload,
+			nil); nil != err {
 			t.Error(err)
 		} else if exp := header + tStrToken; s != exp {
 			t.Errorf("StringToken(returned) != StringToken(expected)"+
@@ -24,7 +29,8 @@ func TestGenericSerialize(t *testing.T) {
 		var touched = false
 		var cb = func([]byte, io.Writer) error { touched = true; return nil }
 
-		if _, err := genericSerialize(tPayload, cb); nil != err {
+		if _, err := genericSerialize(&Options{}, "", tPayload,
+			cb); nil != err {
 			t.Error(err)
 		} else if !touched {
 			t.Error("writeSign never called by genericSerialize")
@@ -34,10 +40,12 @@ func TestGenericSerialize(t *testing.T) {
 	t.Run("CbErr", func(t *testing.T) {
 		var cb = func([]byte, io.Writer) error { return Error("") }
 
-		if s, err := genericSerialize(tPayload, cb); nil == err {
+		if s, err := genericSerialize(&Options{}, "", tPayload,
+			cb); nil == err {
 			t.Errorf("writeSign error ignored by genericSerialize")
 		} else if 0 != len(s) {
-			t.Errorf("genericSerialize returned StringToken after writeSign error")
+			t.Errorf("genericSerialize returned StringToken after " +
+				"writeSign error")
 		}
 	})
 
@@ -52,7 +60,8 @@ func TestGenericSerialize(t *testing.T) {
 		rand.Read(b[:])
 		wb = "." + base64.RawURLEncoding.EncodeToString(b[:])
 
-		if s, err := genericSerialize(tPayload, cb); nil != err {
+		if s, err := genericSerialize(&Options{}, "", tPayload,
+			cb); nil != err {
 			t.Error(err)
 		} else if exp := header + tStrToken + wb; s != exp {
 			t.Errorf("writeSign write did not function as expected"+
@@ -65,9 +74,11 @@ func TestGenericSerialize(t *testing.T) {
 func TestGenericDeserialize(t *testing.T) {
 	t.Run("CbNone", func(t *testing.T) {
 		var p tPayloadT
-		if err := genericDeserialize(tStrToken, &p, nil); ErrBadFormat != err {
+		if err := genericDeserialize(tStrToken, &Options{}, "",
+			&p, nil); !errors.Is(err, ErrBadFormat) {
 			t.Error("StringToken format not enforced by genericDeserialize")
-		} else if err = genericDeserialize(header+tStrToken, &p, nil); nil != err {
+		} else if err = genericDeserialize(header+tStrToken, &Options{}, "",
+			&p, nil); nil != err {
 			t.Error(err)
 		} else if !reflect.DeepEqual(tPayload, p) {
 			t.Error("deserialzed payload does not match expectation")
@@ -79,13 +90,13 @@ func TestGenericDeserialize(t *testing.T) {
 		var p tPayloadT
 		var cb = func([]byte, []byte) error { touched = true; return nil }
 
-		if err := genericDeserialize(
-			header+tStrToken, &p, cb); ErrBadFormat != err {
+		if err := genericDeserialize(header+tStrToken, &Options{}, "", &p,
+			cb); !errors.Is(err, ErrBadFormat) {
 			t.Error("StringToken format not enforced with compareSign != nil")
 		} else if touched {
 			t.Error("compareSign called with wrong StringToken format")
 		} else if err = genericDeserialize(
-			header+tStrToken+".sig", &p, cb); nil != err {
+			header+tStrToken+".sig", &Options{}, "", &p, cb); nil != err {
 			t.Error(err)
 		} else if !touched {
 			t.Error("compareSign never called by genericDeserialize")
@@ -96,7 +107,8 @@ func TestGenericDeserialize(t *testing.T) {
 		var p tPayloadT
 		var cb = func([]byte, []byte) error { return Error("") }
 
-		if err := genericDeserialize(header+tStrToken, &p, cb); nil == err {
+		if err := genericDeserialize(header+tStrToken, &Options{}, "",
+			&p, cb); nil == err {
 			t.Error("compareSign error ignored by genericDeserialize")
 		}
 
@@ -111,10 +123,12 @@ func TestGenericDeserialize(t *testing.T) {
 		var sg string
 		var cb = func(b []byte, sig []byte) error {
 			if base64.RawURLEncoding.EncodeToString(b) != tStrToken {
-				return Error("args passed to compareSign do not match the payload")
+				return Error("args passed to compareSign do not match " +
+					"the payload")
 			}
 			if !bytes.Equal(sig, sb[:]) {
-				return Error("args passed to compareSign do not match the signature")
+				return Error("args passed to compareSign do not match " +
+					"the signature")
 			}
 			return nil
 		}
@@ -122,7 +136,8 @@ func TestGenericDeserialize(t *testing.T) {
 		rand.Read(sb[:])
 		sg = "." + base64.RawURLEncoding.EncodeToString(sb[:])
 
-		if err := genericDeserialize(header+tStrToken+sg, &p, cb); nil != err {
+		if err := genericDeserialize(header+tStrToken+sg, &Options{}, "",
+			&p, cb); nil != err {
 			t.Error(err)
 		}
 	})
@@ -130,7 +145,8 @@ func TestGenericDeserialize(t *testing.T) {
 
 func BenchmarkGenericSerialize(b *testing.B) {
 	var base = func(b *testing.B) {
-		if _, err := genericSerialize(tPayload, nil); nil != err {
+		if _, err := genericSerialize(&Options{}, "", tPayload,
+			nil); nil != err {
 			b.Fatal(err)
 		}
 	}
@@ -162,7 +178,7 @@ func BenchmarkGenericDeserialize(b *testing.B) {
 
 	var base = func(b *testing.B) {
 		var p tPayloadT
-		if err := genericDeserialize(s, &p, nil); nil != err {
+		if err := genericDeserialize(s, &Options{}, "", &p, nil); nil != err {
 			b.Fatal(err)
 		}
 	}
@@ -187,3 +203,113 @@ func BenchmarkGenericDeserialize(b *testing.B) {
 	b.Run("LoConc", conc(b, 4))
 	b.Run("HiConc", conc(b, 64))
 }
+
+func TestGenericDeserializeConc(t *testing.T) {
+	// run with the race detector (go test -race) to check that concurrent
+	// deserializations share no mutable state
+	var ser, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var s, _ = ser.Serialize(tPayload)
+	var i = strings.LastIndexByte(s, '.')
+	var wg sync.WaitGroup
+
+	var cases = []struct {
+		s   string
+		err error
+	}{
+		{s, nil},
+		{s[:i] + ".!" + s[i+2:], ErrBadFormat},
+		{"auth.!" + s[6:], ErrBadFormat},
+		{s[:i] + ".AAAA", ErrBadSign},
+	}
+
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for n := 0; n < 64; n++ {
+				var c = cases[(g+n)%len(cases)]
+				var p tPayloadT
+
+				if err := ser.Deserialize(c.s, &p); !errors.Is(err, c.err) {
+					t.Errorf("expect %v, got %v", c.err, err)
+				} else if nil == err && !reflect.DeepEqual(tPayload, p) {
+					t.Error("deserialized payload does not match expectation")
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+// BenchmarkBodyDeserialize compares bodyDeserialize to tBodyDeserializeConc,
+// which decodes the Payload and Signature in goroutines.
+func BenchmarkBodyDeserialize(b *testing.B) {
+	var ser, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var sg = ser.(signer)
+	var s, _ = ser.Serialize(tPayload)
+
+	s = s[len(header):]
+
+	var base = func(f func(string, Codec, interface{},
+		func([]byte, []byte) error) error) func(*testing.B) {
+
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				var p tPayloadT
+
+				for pb.Next() {
+					if err := f(s, Msgpack, &p, sg.compareSign); nil != err {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+
+	b.Run("Sequential", base(bodyDeserialize))
+	b.Run("Goroutines", base(tBodyDeserializeConc))
+}
+
+// tBodyDeserializeConc is the former implementation of bodyDeserialize for
+// signed StringTokens, which decoded the Payload and Signature parts in two
+// goroutines. It is kept as the baseline of BenchmarkBodyDeserialize, and
+// verifies the same signed bytes as bodyDeserializeBytes.
+func tBodyDeserializeConc(s string, c Codec, payload interface{},
+	compareSign func([]byte, []byte) error) error {
+
+	var p, sig []byte
+	var perr, serr error
+	var wg sync.WaitGroup
+	var i = strings.IndexByte(s, '.')
+
+	if i < 0 || i == len(s)-1 {
+		return ErrBadFormat
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p, perr = base64.RawURLEncoding.DecodeString(s[:i])
+	}()
+	go func() {
+		defer wg.Done()
+		sig, serr = base64.RawURLEncoding.DecodeString(s[i+1:])
+	}()
+
+	if wg.Wait(); nil != perr || nil != serr {
+		return ErrBadFormat
+	}
+
+	// sign the same bytes as bodyDeserializeBytes (see signedBytes)
+	var b = append(append(signPrefix(c), p...), noAD[:]...)
+
+	if err := compareSign(signedBytes(b), sig); nil != err {
+		return err
+	}
+
+	return c.Decode(bytes.NewBuffer(p), payload)
+}
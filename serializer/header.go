@@ -0,0 +1,144 @@
+package serializer
+
+import (
+	"bytes"
+	"strings"
+)
+
+// param is a single name=value parameter in the Header part of a StringToken.
+// Parameters follow the constant "auth" and are each preceded by a ';'
+// character, for example:
+//
+//     auth;kid=2025-10.[Payload].[Signature]
+//
+// A StringToken without parameters has the plain "auth" Header.
+type param struct {
+	name, value string
+}
+
+// formatHeader returns the Header part of a StringToken with the given
+// parameters appended in order, including the trailing '.' separator.
+// formatHeader() is equivalent to the header constant.
+func formatHeader(ps ...param) string {
+	var b strings.Builder
+
+	if 0 == len(ps) {
+		return header
+	}
+
+	b.WriteString(header[:len(header)-1])
+	for _, p := range ps {
+		b.WriteByte(';')
+		b.WriteString(p.name)
+		b.WriteByte('=')
+		b.WriteString(p.value)
+	}
+
+	b.WriteByte('.')
+	return b.String()
+}
+
+// appendParam returns the Header hdr, as returned by formatHeader, with the
+// parameter p appended.
+func appendParam(hdr string, p param) string {
+	return hdr[:len(hdr)-1] + ";" + p.name + "=" + p.value + "."
+}
+
+// parseHeader parses the Header part of StringToken s and returns its
+// parameters along with the rest of s following the Header and its separator.
+// ErrBadFormat is returned if s does not begin with a well-formed Header.
+func parseHeader(s string) (ps []param, rest string, err error) {
+	const H = len(header) - 1
+
+	var i int
+
+	if len(s) <= H || s[:H] != header[:H] {
+		return nil, "", ErrBadFormat
+	}
+
+	if i = strings.IndexByte(s, '.'); i < H || i == len(s)-1 {
+		return nil, "", ErrBadFormat
+	}
+
+	if ps, err = parseParams(s[H:i]); nil != err {
+		return nil, "", err
+	}
+
+	return ps, s[i+1:], nil
+}
+
+// parseHeaderBytes is the same as parseHeader, except that the StringToken is
+// held in the byte-slice b.
+func parseHeaderBytes(b []byte) (ps []param, rest []byte, err error) {
+	const H = len(header) - 1
+
+	var i int
+
+	if len(b) <= H || string(b[:H]) != header[:H] {
+		return nil, nil, ErrBadFormat
+	}
+
+	if i = bytes.IndexByte(b, '.'); i < H || i == len(b)-1 {
+		return nil, nil, ErrBadFormat
+	}
+
+	if ps, err = parseParams(string(b[H:i])); nil != err {
+		return nil, nil, err
+	}
+
+	return ps, b[i+1:], nil
+}
+
+// parseParams parses the parameters h of a Header, i.e. the part between the
+// constant "auth" and the separator.
+func parseParams(h string) (ps []param, err error) {
+	for 0 != len(h) {
+		var p param
+		var j int
+
+		if ';' != h[0] {
+			return nil, ErrBadFormat
+		}
+
+		if h = h[1:]; 0 == len(h) {
+			return nil, ErrBadFormat
+		}
+
+		if j = strings.IndexByte(h, ';'); j < 0 {
+			j = len(h)
+		}
+
+		if k := strings.IndexByte(h[:j], '='); k > 0 {
+			p.name, p.value = h[:k], h[k+1:j]
+		}
+
+		if !validParam(p.name) || !validParam(p.value) {
+			return nil, ErrBadFormat
+		}
+
+		ps, h = append(ps, p), h[j:]
+	}
+
+	return
+}
+
+// validParam returns true if s is a non-empty string made up of characters
+// that may be used in a Header parameter name or value. These are a subset of
+// the characters allowed in a Bearer token (see RFC 6750) that excludes the
+// '.' separator.
+func validParam(s string) bool {
+	if 0 == len(s) {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case '-' == c, '_' == c, '~' == c, '+' == c, '/' == c:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
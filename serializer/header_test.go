@@ -0,0 +1,55 @@
+package serializer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormatHeader(t *testing.T) {
+	if h := formatHeader(); h != header {
+		t.Errorf("expect formatHeader() = %q, got %q", header, h)
+	}
+
+	if h, exp := formatHeader(param{"kid", "2025-10"}, param{"x", "y"}),
+		"auth;kid=2025-10;x=y."; h != exp {
+		t.Errorf("expect formatHeader(...) = %q, got %q", exp, h)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	var base = func(s string, exp []param, rest string) func(*testing.T) {
+		return func(t *testing.T) {
+			var ps, r, err = parseHeader(s)
+			if nil != err {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(ps, exp) {
+				t.Errorf("params do not match expectation"+
+					"\nexp: %+v"+
+					"\nret: %+v", exp, ps)
+			}
+
+			if r != rest {
+				t.Errorf("expect rest = %q, got %q", rest, r)
+			}
+		}
+	}
+
+	t.Run("Plain", base("auth.foo.bar", nil, "foo.bar"))
+	t.Run("Params", base("auth;kid=2025-10;a=b/c.foo",
+		[]param{{"kid", "2025-10"}, {"a", "b/c"}}, "foo"))
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, s := range []string{
+			"", "auth", "auth.", "foo.bar", "authx.foo", "auth;.foo",
+			"auth;kid.foo", "auth;kid=.foo", "auth;=v.foo", "auth;k=v;.foo",
+			"auth;k=v=w.foo", "auth;k=v;;x=y.foo", "auth;k=v w.foo",
+		} {
+			if _, _, err := parseHeader(s); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat for %q, got (%v)", s, err)
+			}
+		}
+	})
+}
@@ -16,18 +16,36 @@ const hmacKeyMinLen = 256
 type hmacSerializer struct {
 	key  []byte
 	hash crypto.Hash
+	opts Options
 }
 
 // Serialize makes hmacSerializer implement the Serializer interface.
 func (sr *hmacSerializer) Serialize(
 	token interface{}) (s string, err error) {
-	return genericSerialize(token, sr.writeSign)
+	return genericSerialize(&sr.opts, algName(sr.algorithm()), token,
+		sr.writeSign)
 }
 
 // Deserialize makes hmacSerializer implement the Serializer interface.
 func (sr *hmacSerializer) Deserialize(
 	s string, token interface{}) (err error) {
-	return genericDeserialize(s, token, sr.compareSign)
+	return genericDeserialize(s, &sr.opts, algName(sr.algorithm()), token,
+		sr.compareSign)
+}
+
+// AppendSerialize makes hmacSerializer implement the BytesSerializer interface.
+func (sr *hmacSerializer) AppendSerialize(
+	dst []byte, token interface{}) ([]byte, error) {
+	return genericAppend(dst, &sr.opts, algName(sr.algorithm()), token,
+		sr.writeSign)
+}
+
+// DeserializeBytes makes hmacSerializer implement the BytesSerializer
+// interface.
+func (sr *hmacSerializer) DeserializeBytes(
+	b []byte, token interface{}) (err error) {
+	return genericDeserializeBytes(b, &sr.opts, algName(sr.algorithm()),
+		token, sr.compareSign)
 }
 
 // newHmacSerializer returns a new hmacSerializer struct with the given key and
@@ -53,7 +71,17 @@ func newHmacSerializer(
 			"", fmt.Sprintf("%d bytes", hmacKeyMinLen))
 	}
 
-	return &hmacSerializer{k, hash}, nil
+	return &hmacSerializer{key: k, hash: hash}, nil
+}
+
+// algorithm makes hmacSerializer implement the signer interface.
+func (sr *hmacSerializer) algorithm() (SignMethod, crypto.Hash) {
+	return SignHMAC, sr.hash
+}
+
+// options makes hmacSerializer implement the signer interface.
+func (sr *hmacSerializer) options() *Options {
+	return &sr.opts
 }
 
 // writeSign computes the Signature part of StringToken from the binary (msgpack
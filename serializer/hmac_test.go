@@ -3,6 +3,7 @@ package serializer
 import (
 	"bytes"
 	"crypto"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +25,25 @@ func TestHMACSign(t *testing.T) {
 		t.Errorf("bad signature written by writeSign (%v)", err)
 	}
 }
+
+func TestHMACBare(t *testing.T) {
+	var key = []byte(strings.Repeat("0123456789abcdef", 16))
+	var ser, _ = New(SignHMAC, key, crypto.SHA256)
+	var p map[string]interface{}
+
+	// signed by earlier versions of this package, over the bare Payload
+	const s = "auth.gaNzdWKlYWxpY2U." +
+		"gBGN_CeNqWJbMPlSuNGQv-2ibcPE1HJGNVpiynsxofk"
+
+	if err := ser.Deserialize(s, &p); nil != err {
+		t.Error(err)
+	} else if "alice" != p["sub"] {
+		t.Errorf("unexpected Payload: %v", p)
+	}
+
+	if b, err := ser.Serialize(p); nil != err {
+		t.Error(err)
+	} else if s != b {
+		t.Errorf("expect %q, got %q", s, b)
+	}
+}
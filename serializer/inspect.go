@@ -0,0 +1,195 @@
+package serializer
+
+import (
+	"bytes"
+	"strings"
+)
+
+// UnverifiedToken is the content of a StringToken as returned by Inspect.
+// NOTHING in it is verified: the Signature is not checked, and any of the
+// fields may have been forged. It is meant for debugging and logging, and
+// must never be used to authorize a request.
+type UnverifiedToken struct {
+	// Header is the Header part of the StringToken, without the separator,
+	// e.g. "auth;kid=2025-10;alg=eddsa".
+	Header string
+
+	// KeyID is the key ID written by a KeyRing ("kid"), if any.
+	KeyID string
+
+	// Label is the label that the signing key was derived for ("ctx"), if
+	// any (see NewDerived).
+	Label string
+
+	// HeaderAlg is the algorithm named in the Header ("alg"), or the
+	// SealMethod of a sealed StringToken ("enc"), if any. It is empty unless
+	// the StringToken was generated with Options.Alg, which is not the
+	// default; the algorithm is not inferred from the Signature, as its
+	// length is ambiguous (64 bytes for HMAC-SHA512, ECDSA P-256 and Ed25519
+	// alike).
+	HeaderAlg string
+
+	// Caveats are the Caveats of a StringToken of a MacaroonSerializer, if
+	// any; the number of them is recorded in the Header ("cav").
+	Caveats []Caveat
+
+	// Sealed is true if the Payload is encrypted (see NewSealer); Payload and
+	// Signature are then nil.
+	Sealed bool
+
+	// Codec is the Codec named in the Header, i.e. Msgpack by default.
+	Codec Codec
+
+	// Payload is the binary Payload, decompressed if it was compressed (see
+	// Options.Compress).
+	Payload []byte
+
+	// Signature is the binary Signature, or nil if the StringToken has none.
+	Signature []byte
+}
+
+// Inspect parses the StringToken s WITHOUT verifying it, and returns its
+// parts. It fails only if s is malformed, i.e. if it would fail to
+// deserialize with ErrBadFormat, or if it names a Codec other than Msgpack,
+// JSON or CBOR (ErrBadCodec).
+//
+// Use UnverifiedToken.Decode to unpack the Payload. Inspect does not need any
+// keys, and so is meant for debugging StringTokens that fail to deserialize
+// with a signature or key mismatch. Its result must never be trusted.
+func Inspect(s string) (t *UnverifiedToken, err error) {
+	var opts = Options{Accept: []Codec{JSON, CBOR}}
+	var ps []param
+	var body string
+	var alg = algName(SignNone, 0)
+	var n int
+	var c Codec
+
+	if ps, body, err = parseHeader(s); nil != err {
+		return nil, decodeError(StageHeader, err)
+	}
+
+	t = &UnverifiedToken{Header: s[:len(s)-len(body)-1]}
+
+	// the parameters are consumed in the order that Serializers write them
+	// (see KeyRing.Deserialize and Options.decoder)
+	if 0 != len(ps) && ps[0].name == paramKeyID {
+		t.KeyID, ps = ps[0].value, ps[1:]
+	} else if 0 != len(ps) && ps[0].name == paramLabel {
+		t.Label, ps = ps[0].value, ps[1:]
+	} else if 0 != len(ps) && ps[0].name == paramCaveats {
+		if n, err = macaroonCaveats(ps); nil != err {
+			return nil, err
+		}
+
+		ps = ps[1:]
+	}
+
+	if 0 != len(ps) && ps[0].name == paramSeal {
+		t.HeaderAlg, t.Sealed, alg, ps = ps[0].value, true, "", ps[1:]
+	} else if 0 != len(ps) && ps[0].name == paramAlg {
+		t.HeaderAlg, alg = ps[0].value, ps[0].value
+	}
+
+	if c, err = opts.decoder(alg, ps); nil != err {
+		return nil, decodeError(StageHeader, err)
+	}
+
+	// the Codec is kept without the decompression and limits that the
+	// decoder wraps it in
+	if zc, ok := c.(zipCodec); ok {
+		t.Codec = zc.Codec
+	} else {
+		t.Codec = c
+	}
+
+	if lc, ok := t.Codec.(limitCodec); ok {
+		t.Codec = lc.Codec
+	}
+
+	if t.Sealed {
+		return
+	}
+
+	if err = t.decodeBody(body, n, c); nil != err {
+		return nil, err
+	}
+
+	return
+}
+
+// Decode unpacks the Payload of t to v, which may be a pointer to a struct,
+// or to a map[string]interface{} to inspect Payloads of unknown structure. An
+// error is returned if t is sealed.
+func (t *UnverifiedToken) Decode(v interface{}) error {
+	if t.Sealed {
+		return errorf(efSealedData, "")
+	}
+
+	return t.Codec.Decode(bytes.NewReader(t.Payload), v)
+}
+
+// decodeBody decodes the Payload and Signature parts of a StringToken body,
+// following the Header, which are Base64 decoded as in bodyDeserialize, and
+// decompresses the Payload if c is a zipCodec. The Payload is followed by n
+// Caveats if the StringToken is one of a MacaroonSerializer.
+func (t *UnverifiedToken) decodeBody(
+	body string, n int, c Codec) (err error) {
+
+	var buf = new(bytes.Buffer)
+
+	if 0 != n {
+		var parts = strings.Split(body, ".")
+
+		// the parts are [Payload].[Caveat]....[Signature]
+		if len(parts) != n+2 {
+			return decodeError(StageSignature, ErrBadFormat)
+		}
+
+		for _, p := range parts[1 : n+1] {
+			var cb, err = tokenEncoding.DecodeString(p)
+			if nil != err {
+				return formatError(StageCaveat, err)
+			}
+
+			t.Caveats = append(t.Caveats, parseCaveat(cb))
+		}
+
+		body = parts[0] + "." + parts[n+1]
+	}
+
+	var i = strings.IndexByte(body, '.')
+
+	if i < 0 {
+		i = len(body)
+	} else if i == len(body)-1 {
+		return decodeError(StageSignature, ErrBadFormat)
+	}
+
+	if err = decodeBase64(buf, []byte(body[:i])); nil != err {
+		return formatError(StagePayload, err)
+	}
+
+	if zc, ok := c.(zipCodec); ok {
+		var zb = new(bytes.Buffer)
+
+		if err = zc.decompress(buf, zb); nil != err {
+			return
+		}
+
+		buf = zb
+	}
+
+	t.Payload = buf.Bytes()
+
+	if i < len(body) {
+		var sb = new(bytes.Buffer)
+
+		if err = decodeBase64(sb, []byte(body[i+1:])); nil != err {
+			return formatError(StageSignature, err)
+		}
+
+		t.Signature = sb.Bytes()
+	}
+
+	return
+}
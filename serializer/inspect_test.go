@@ -0,0 +1,133 @@
+package serializer
+
+import (
+	"crypto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var noneSer, _ = New(SignNone, nil, 0)
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var sealer, _ = NewSealer(SealXChaCha20, tRandBuf[:32])
+	var kr = NewKeyRing()
+
+	edSer, _ = WithOptions(edSer, Options{Alg: true, Codec: CBOR,
+		Compress: CompressDeflate})
+	kr.Add("k0", edSer)
+	kr.Add("k1", sealer)
+	kr.Activate("k0")
+
+	var base = func(ser Serializer, exp UnverifiedToken) func(*testing.T) {
+		return func(t *testing.T) {
+			var s, _ = ser.Serialize(tPayload)
+			var u *UnverifiedToken
+			var p tPayloadT
+			var err error
+
+			if u, err = Inspect(s); nil != err {
+				t.Fatal(err)
+			} else if !strings.HasPrefix(s, u.Header+".") {
+				t.Errorf("unexpected Header %q", u.Header)
+			}
+
+			if u.KeyID != exp.KeyID || u.HeaderAlg != exp.HeaderAlg ||
+				u.Codec != exp.Codec || u.Sealed != exp.Sealed {
+				t.Errorf("expect %+v, got %+v", exp, *u)
+			}
+
+			if exp.Sealed {
+				if nil == u.Decode(&p) {
+					t.Error("expect error decoding sealed payload")
+				}
+
+				return
+			}
+
+			if err = u.Decode(&p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("decoded payload does not match expectation")
+			}
+
+			// the Signature is returned, but not verified
+			if (nil == u.Signature) != (ser == noneSer) {
+				t.Errorf("unexpected signature %x", u.Signature)
+			} else if i := strings.LastIndexByte(s, '.'); nil != u.Signature {
+				var s = s[:i+1] + "AAAA"
+
+				if nil == ser.Deserialize(s, &p) {
+					t.Fatal("expect error for bad signature")
+				} else if u, err = Inspect(s); nil != err {
+					t.Error(err)
+				} else if string(u.Signature) != "\x00\x00\x00" {
+					t.Errorf("unexpected signature %x", u.Signature)
+				}
+			}
+		}
+	}
+
+	t.Run("None", base(noneSer, UnverifiedToken{Codec: Msgpack}))
+	t.Run("HMAC", base(hmacSer, UnverifiedToken{Codec: Msgpack}))
+	t.Run("Options", base(edSer, UnverifiedToken{HeaderAlg: "eddsa",
+		Codec: CBOR}))
+	t.Run("KeyRing", base(kr, UnverifiedToken{KeyID: "k0", HeaderAlg: "eddsa",
+		Codec: CBOR}))
+	t.Run("Sealed", base(sealer, UnverifiedToken{HeaderAlg: "XC20P",
+		Codec: Msgpack, Sealed: true}))
+
+	t.Run("Macaroon", func(t *testing.T) {
+		var mac, _ = NewMacaroon(tRandBuf[:256])
+		var s, _ = mac.Serialize(tPayload)
+		var cs = []Caveat{{"op", "read"}, ExpiresCaveat(time.Now())}
+		var u *UnverifiedToken
+		var p tPayloadT
+		var err error
+
+		base(mac, UnverifiedToken{Codec: Msgpack})(t)
+
+		if s, err = Attenuate(s, cs...); nil != err {
+			t.Fatal(err)
+		} else if u, err = Inspect(s); nil != err {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(cs, u.Caveats) {
+			t.Errorf("expect caveats %v, got %v", cs, u.Caveats)
+		} else if err = u.Decode(&p); nil != err {
+			t.Error(err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("decoded payload does not match expectation")
+		}
+
+		if _, err = Inspect(s[:strings.LastIndexByte(s,
+			'.')]); nil == err {
+			t.Error("expect error for missing caveat")
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		var s, _ = hmacSer.Serialize(map[string]interface{}{"uri": "foo"})
+		var m map[string]interface{}
+
+		if u, err := Inspect(s); nil != err {
+			t.Fatal(err)
+		} else if err = u.Decode(&m); nil != err {
+			t.Error(err)
+		} else if "foo" != m["uri"] {
+			t.Errorf("unexpected payload %v", m)
+		}
+	})
+
+	t.Run("Bad", func(t *testing.T) {
+		for _, s := range []string{
+			"", "auth", "foo.bar", "auth.!", "auth.foo.", "auth.foo.!",
+			"auth;codec=foo.bar", "auth;zip=foo.bar", "auth;x=y.foo",
+		} {
+			if u, err := Inspect(s); nil == err || nil != u {
+				t.Errorf("expect error for %q", s)
+			}
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package serializer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// JWKSPath is the conventional path at which a JWK Set is served (see
+// NewJWKSHandler).
+const JWKSPath = "/.well-known/jwks.json"
+
+// jwkSet is a JWK Set (see RFC 7517, sec. 5).
+type jwkSet struct {
+	Keys []*jwk `json:"keys"`
+}
+
+// JWKS returns the JSON encoded JWK Set (RFC 7517) of the public keys that
+// verify the tokens generated by ser, so that relying services can verify
+// them without holding a copy of the keys.
+//
+// ser must be a Serializer returned by New or NewVerifier for an asymmetric
+// SignMethod, a KeyRing, a Serializer returned by NewJWT, NewSDJWT or NewCWT
+// for either of them, or one returned by NewPASETOPublic. Each key is listed
+// with its "kid", "alg" and "use" ("sig") parameters. The key ID of a single
+// key is its JWK Thumbprint (RFC 7638); the keys of a KeyRing are listed under
+// their key IDs, starting with the active key, followed by all other keys that
+// still verify tokens, including retired keys within their grace period. HMAC
+// and sealing keys are never listed; "alg" is omitted for keys without a JWS
+// algorithm (see NewJWT).
+func JWKS(ser Serializer) (b []byte, err error) {
+	var set = jwkSet{Keys: []*jwk{}}
+	var kids []string
+	var sgs []signer
+
+	if kids, sgs, err = verifyKeys(ser); nil != err {
+		return
+	}
+
+	for i, sg := range sgs {
+		var k *jwk
+
+		if k = publicJWK(sg); nil == k {
+			continue
+		}
+
+		if k.Kid = kids[i]; 0 == len(k.Kid) {
+			k.Kid = jwkThumbprint(k)
+		}
+
+		set.Keys = append(set.Keys, k)
+	}
+
+	return json.Marshal(&set)
+}
+
+// NewJWKSHandler returns a http.Handler that serves the JWK Set of ser (see
+// JWKS), e.g. at JWKSPath. The document is generated for every request, so
+// that keys added to or removed from a KeyRing are published right away, but
+// clients are allowed to cache it for maxAge, which should be shorter than
+// the time between adding a key to a KeyRing and activating it.
+//
+// Only GET and HEAD requests are served. Responses carry an ETag, so clients
+// can revalidate cached documents with If-None-Match. An error is returned if
+// ser has no public keys to publish, such as a KeyRing of HMAC keys only; the
+// JWK Set of a KeyRing is empty once all of its public keys are removed.
+func NewJWKSHandler(ser Serializer,
+	maxAge time.Duration) (http.Handler, error) {
+
+	var _, sgs, err = verifyKeys(ser)
+
+	if nil != err {
+		return nil, err
+	}
+
+	for _, sg := range sgs {
+		if nil != publicJWK(sg) {
+			return &jwksHandler{ser, maxAge}, nil
+		}
+	}
+
+	return nil, errorf(efBadSerlr, "", ser, &jwkSet{})
+}
+
+// jwksHandler is the http.Handler returned by NewJWKSHandler.
+type jwksHandler struct {
+	ser    Serializer
+	maxAge time.Duration
+}
+
+// ServeHTTP makes jwksHandler implement the http.Handler interface.
+func (h *jwksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b []byte
+	var err error
+
+	if http.MethodGet != r.Method && http.MethodHead != r.Method {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	if b, err = JWKS(h.ser); nil != err {
+		http.Error(w, http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError)
+		return
+	}
+
+	var sum = sha256.Sum256(b)
+	var etag = `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control",
+		fmt.Sprintf("public, max-age=%d", int64(h.maxAge/time.Second)))
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write(b)
+}
+
+// verifyKeys returns the signers that verify the tokens generated by ser,
+// along with their key IDs (empty for a single key). An error is returned if
+// ser has no public keys, e.g. an HMAC Serializer.
+func verifyKeys(ser Serializer) (kids []string, sgs []signer, err error) {
+	switch sr := ser.(type) {
+	case *KeyRing:
+		kids, sgs = sr.validKeys(time.Now())
+		return
+
+	case *jwtSerializer:
+		return sourceKeys(sr.keys)
+
+	case *sdJWTSerializer:
+		return verifyKeys(sr.jwt)
+
+	case *cwtSerializer:
+		return sourceKeys(sr.keys)
+
+	case *pasetoSerializer:
+		if nil != sr.sg {
+			return verifyKeys(sr.sg)
+		}
+
+	case signer:
+		if nil != publicJWK(sr) {
+			return []string{""}, []signer{sr}, nil
+		}
+	}
+
+	return nil, nil, errorf(efBadSerlr, "", ser, &jwkSet{})
+}
+
+// sourceKeys returns the signers that verify the tokens signed with the keys
+// of ks, as verifyKeys does.
+func sourceKeys(ks keySource) (kids []string, sgs []signer, err error) {
+	if k, ok := ks.(singleKey); ok {
+		return verifyKeys(k.signer)
+	}
+
+	return verifyKeys(ks.(Serializer))
+}
+
+// publicJWK returns the JSON Web Key of the public key of sg, or nil if sg
+// does not use an asymmetric key.
+func publicJWK(sg signer) (k *jwk) {
+	var b64 = base64.RawURLEncoding.EncodeToString
+	var pub crypto.PublicKey
+
+	if cs, ok := sg.(interface{ public() crypto.PublicKey }); ok {
+		pub = cs.public()
+	}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k = &jwk{Kty: "RSA", N: b64(pub.N.Bytes()),
+			E: b64(big.NewInt(int64(pub.E)).Bytes())}
+
+	case *ecdsa.PublicKey:
+		var size = (pub.Curve.Params().BitSize + 7) / 8
+
+		k = &jwk{Kty: "EC", Crv: pub.Curve.Params().Name,
+			X: b64(pub.X.FillBytes(make([]byte, size))),
+			Y: b64(pub.Y.FillBytes(make([]byte, size)))}
+
+	case ed25519.PublicKey:
+		k = &jwk{Kty: "OKP", Crv: "Ed25519", X: b64(pub)}
+
+	default:
+		return nil
+	}
+
+	k.Use = "sig"
+	k.Alg, _ = jwsAlg(sg)
+	return
+}
+
+// jwkThumbprint returns the JWK Thumbprint (see RFC 7638) of the public key k,
+// i.e. the SHA-256 digest of its required members in lexicographic order.
+func jwkThumbprint(k *jwk) string {
+	var m = map[string]string{"kty": k.Kty}
+
+	switch k.Kty {
+	case "RSA":
+		m["e"], m["n"] = k.E, k.N
+	case "EC":
+		m["crv"], m["x"], m["y"] = k.Crv, k.X, k.Y
+	case "OKP":
+		m["crv"], m["x"] = k.Crv, k.X
+	}
+
+	// json.Marshal sorts map keys, and none of the values need escaping
+	var b, _ = json.Marshal(m)
+	var sum = sha256.Sum256(b)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,232 @@
+package serializer
+
+import (
+	"crypto"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKS(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var ecSer, _ = New(SignECDSA, tECDSAKey, crypto.SHA256)
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var rsaSer, _ = New(SignRSA, tRSAKey, crypto.SHA256)
+	var rsaVer, _ = NewVerifier(SignRSA, &tRSAKey.PublicKey, crypto.SHA256)
+	var sealer, _ = NewSealer(SealAESGCM, tRandBuf[:32])
+
+	// parse returns the JWK Set b, and checks that every key in it verifies
+	// the StringTokens of the Serializer with the same key ID in sers
+	var parse = func(t *testing.T, b []byte,
+		sers map[string]Serializer) (set struct{ Keys []jwk }) {
+
+		if err := json.Unmarshal(b, &set); nil != err {
+			t.Fatal(err)
+		}
+
+		for _, j := range set.Keys {
+			var b, _ = json.Marshal(&j)
+			var k, err = ParseKey(b)
+			var ver Serializer
+			var s string
+			var p tPayloadT
+
+			if nil != err {
+				t.Fatal(err)
+			} else if ver, err = k.Serializer(); nil != err {
+				t.Fatal(err)
+			} else if j.Use != "sig" {
+				t.Errorf("expect use = sig, got %q", j.Use)
+			}
+
+			if ser, ok := sers[j.Kid]; !ok {
+				t.Errorf("unexpected key id %q", j.Kid)
+			} else if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			} else if err = ver.Deserialize(s, &p); nil != err {
+				t.Errorf("key %q: %v", j.Kid, err)
+			}
+		}
+
+		return
+	}
+
+	t.Run("Single", func(t *testing.T) {
+		var b, err = JWKS(ecSer)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		var k0 = publicJWK(ecSer.(signer))
+		var kid = jwkThumbprint(k0)
+		var set = parse(t, b, map[string]Serializer{kid: ecSer})
+
+		if 1 != len(set.Keys) {
+			t.Fatalf("expect 1 key, got %d", len(set.Keys))
+		} else if k := set.Keys[0]; "ES256" != k.Alg || "P-256" != k.Crv {
+			t.Errorf("unexpected key %+v", k)
+		}
+
+		// the thumbprint does not depend on the optional members
+		k0.Alg, k0.Use = "", ""
+		if jwkThumbprint(k0) != kid {
+			t.Error("JWK Thumbprint depends on optional members")
+		}
+	})
+
+	t.Run("KeyRing", func(t *testing.T) {
+		var kr = NewKeyRing()
+		var set struct{ Keys []jwk }
+
+		kr.Add("a-hmac", hmacSer)
+		kr.Add("b-rsa", rsaVer)
+		kr.Add("c-seal", sealer)
+		kr.Add("d-ec", ecSer)
+		kr.Add("e-ed", edSer)
+		kr.Activate("e-ed")
+		kr.Retire("d-ec", time.Now().Add(-time.Second))
+		kr.Retire("b-rsa", time.Now().Add(time.Hour))
+
+		if b, err := JWKS(kr); nil != err {
+			t.Fatal(err)
+		} else {
+			set = parse(t, b, map[string]Serializer{
+				"e-ed": edSer, "b-rsa": rsaSer,
+			})
+		}
+
+		// the active key comes first; expired, HMAC and sealing keys are not
+		// listed
+		if 2 != len(set.Keys) {
+			t.Fatalf("expect 2 keys, got %d", len(set.Keys))
+		} else if set.Keys[0].Kid != "e-ed" || set.Keys[1].Kid != "b-rsa" {
+			t.Errorf("unexpected key ids %q, %q",
+				set.Keys[0].Kid, set.Keys[1].Kid)
+		} else if set.Keys[0].Alg != "EdDSA" || set.Keys[1].Alg != "RS256" {
+			t.Errorf("unexpected algorithms %q, %q",
+				set.Keys[0].Alg, set.Keys[1].Alg)
+		}
+	})
+
+	t.Run("JWT", func(t *testing.T) {
+		var kr = NewKeyRing()
+		var jwt, _ = NewJWT(edSer)
+		var sd, _ = NewSDJWT(edSer, []string{"email"})
+		var cwt, _ = NewCWT(edSer)
+		var krCWT, _ = NewCWT(kr)
+		var exp, _ = JWKS(edSer)
+
+		kr.Add("k0", edSer)
+		kr.Activate("k0")
+
+		for _, ser := range []Serializer{jwt, sd, cwt} {
+			if b, err := JWKS(ser); nil != err {
+				t.Errorf("%T: %v", ser, err)
+			} else if string(exp) != string(b) {
+				t.Errorf("%T: expect %s, got %s", ser, exp, b)
+			}
+		}
+
+		if exp, _ = JWKS(kr); nil == exp {
+			t.Fatal("expect JWK Set of KeyRing")
+		} else if b, err := JWKS(krCWT); nil != err {
+			t.Error(err)
+		} else if string(exp) != string(b) {
+			t.Errorf("expect %s, got %s", exp, b)
+		}
+	})
+
+	t.Run("PASETO", func(t *testing.T) {
+		var public, _ = NewPASETOPublic(edSer, nil, nil)
+		var b, err = JWKS(public)
+
+		if nil != err {
+			t.Fatal(err)
+		} else if exp, _ := JWKS(edSer); string(b) != string(exp) {
+			t.Errorf("expect %s, got %s", exp, b)
+		}
+	})
+
+	t.Run("Bad", func(t *testing.T) {
+		var jwt, _ = NewJWT(hmacSer)
+		var cwt, _ = NewCWT(hmacSer)
+		var local, _ = NewPASETOLocal(tRandBuf[:32], nil, nil)
+
+		for _, ser := range []Serializer{hmacSer, sealer, jwt, cwt,
+			local} {
+			if b, err := JWKS(ser); nil == err || nil != b {
+				t.Errorf("expect error for %T", ser)
+			}
+		}
+	})
+}
+
+func TestJWKSHandler(t *testing.T) {
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var h, err = NewJWKSHandler(edSer, time.Hour)
+	var w *httptest.ResponseRecorder
+	var etag string
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	if h, err := NewJWKSHandler(hmacSer, time.Hour); nil == err || nil != h {
+		t.Error("expect error for HMAC Serializer")
+	}
+
+	// a KeyRing must hold a public key, although its JWK Set may be empty
+	var kr = NewKeyRing()
+	kr.Add("k0", hmacSer)
+
+	if b, err := JWKS(kr); nil != err || `{"keys":[]}` != string(b) {
+		t.Errorf("expect empty JWK Set, got %s (%v)", b, err)
+	} else if h, err := NewJWKSHandler(kr, time.Hour); nil == err ||
+		nil != h {
+		t.Error("expect error for KeyRing without public keys")
+	} else if err = kr.Add("k1", edSer); nil != err {
+		t.Fatal(err)
+	} else if _, err = NewJWKSHandler(kr, time.Hour); nil != err {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect status 200, got %d", w.Code)
+	} else if exp, _ := JWKS(edSer); w.Body.String() != string(exp) {
+		t.Errorf("unexpected body %s", w.Body)
+	}
+
+	for k, v := range map[string]string{
+		"Content-Type":  "application/jwk-set+json",
+		"Cache-Control": "public, max-age=3600",
+	} {
+		if w.Header().Get(k) != v {
+			t.Errorf("expect %s: %s, got %q", k, v, w.Header().Get(k))
+		}
+	}
+
+	if etag = w.Header().Get("ETag"); 0 == len(etag) {
+		t.Error("missing ETag")
+	}
+
+	var r = httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+
+	if h.ServeHTTP(w, r); w.Code != http.StatusNotModified {
+		t.Errorf("expect status 304, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, JWKSPath, nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,329 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// jwtSerializer is an internal implementation of Serializer returned by the
+// NewJWT function. Unlike the other Serializers in this package, it does not
+// produce StringTokens, but JSON Web Tokens (RFC 7519) in the JWS Compact
+// Serialization (RFC 7515), i.e.
+//
+//     B64(JSON(Header)).B64(JSON(Payload)).B64(Signature)
+//
+// where the Signature is computed from the ASCII bytes of the first two parts
+// and their separator.
+type jwtSerializer struct {
+	keys  keySource
+	allow map[string]bool
+}
+
+// jwtHeader is the JOSE Header of a JWT (see RFC 7515, sec. 4.1).
+type jwtHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid,omitempty"`
+	Typ  string   `json:"typ,omitempty"`
+	Crit []string `json:"crit,omitempty"`
+}
+
+// jwsAlgs lists the JWS algorithm names (see RFC 7518, sec. 3.1 and RFC
+// 8037, sec. 3.1) that a jwtSerializer supports.
+var jwsAlgs = map[string]bool{
+	"HS256": true, "HS384": true, "HS512": true,
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+	"ES256": true, "ES384": true, "ES512": true,
+	"EdDSA": true,
+}
+
+// jwtEncoding is the Base64 encoding of the parts of a JWT. It is strict, so
+// that no two encoded strings decode to the same bytes.
+var jwtEncoding = base64.RawURLEncoding.Strict()
+
+// NewJWT returns a Serializer that generates and parses JSON Web Tokens (RFC
+// 7519) in the JWS Compact Serialization (RFC 7515), so that tokens issued by
+// a Store can be read by standard JWT libraries.
+//
+// ser provides the keys, and must be a Serializer returned by New (other than
+// for SignNone) or NewVerifier, or a KeyRing. With a KeyRing the key ID of
+// the active key is written to the "kid" Header parameter, and read back to
+// pick the verifying key. The JWS "alg" Header parameter is derived from the
+// method and hash of each key, for example SignPSS with crypto.SHA256 is
+// "PS256", and SignECDSA requires a hash that matches the curve of the key
+// (crypto.SHA256 for P-256 and so on).
+//
+// The token passed to Serialize and Deserialize is JSON encoded (see
+// encoding/json) to get the claims of the JWT; token.Token maps its fields to
+// the registered claims "jti", "sub", "iss", "aud", "iat", "nbf" and "exp".
+// Deserialize does not validate the claims themselves.
+//
+// During deserialization, the "alg" Header parameter must be listed in allow
+// and must match the algorithm of the verifying key; "none" is never
+// accepted. If allow is empty, every supported algorithm is allowed, subject
+// to the same match. An error is returned if allow lists an algorithm that is
+// not supported.
+func NewJWT(ser Serializer, allow ...string) (Serializer, error) {
+	var sr = &jwtSerializer{}
+	var err error
+
+	if sr.keys, err = newKeySource(ser, sr); nil != err {
+		return nil, err
+	}
+
+	if k, ok := sr.keys.(singleKey); ok {
+		if _, err = jwsAlg(k.signer); nil != err {
+			return nil, err
+		}
+	}
+
+	if 0 != len(allow) {
+		sr.allow = make(map[string]bool, len(allow))
+	}
+
+	for _, alg := range allow {
+		if !jwsAlgs[alg] {
+			return nil, errorf(efJWSAllow, "", alg)
+		}
+
+		sr.allow[alg] = true
+	}
+
+	return sr, nil
+}
+
+// Serialize makes jwtSerializer implement the Serializer interface.
+func (sr *jwtSerializer) Serialize(token interface{}) (s string, err error) {
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var h jwtHeader
+	var sg signer
+	var hb, pb []byte
+	var pos int
+
+	if h.Kid, sg, err = sr.keys.signKey(); nil != err {
+		return
+	}
+
+	if h.Alg, err = jwsAlg(sg); nil != err {
+		return
+	}
+
+	h.Typ = "JWT"
+	if hb, err = json.Marshal(&h); nil != err {
+		return
+	}
+
+	if pb, err = json.Marshal(token); nil != err {
+		return
+	}
+
+	// write the signing input B64(Header).B64(Payload) and note where it ends
+	// (pos), then write the Signature computed from it
+	writeBase64(buf, hb)
+	buf.WriteByte('.')
+	writeBase64(buf, pb)
+	pos = buf.Len()
+	buf.WriteByte('.')
+
+	var b64 = base64.NewEncoder(base64.RawURLEncoding, buf)
+	if err = sg.writeSign(buf.Bytes()[:pos], b64); nil != err {
+		return
+	}
+
+	if err = b64.Close(); nil != err {
+		return
+	}
+
+	return buf.String(), nil
+}
+
+// Deserialize makes jwtSerializer implement the Serializer interface. The
+// JWT is verified, and its claims unpacked, within the limits of the Options
+// of the verifying key (see Options.MaxLength).
+func (sr *jwtSerializer) Deserialize(s string, token interface{}) (err error) {
+	_, err = sr.deserialize(s, len(s), token)
+	return
+}
+
+// deserialize implements Deserialize, and returns the limits of the Options
+// of the verifying key. The length n, that of s unless s is part of a longer
+// token (see NewSDJWT), is checked against the largest limit of the keys
+// before anything is decoded, and against that of the key once it is known.
+func (sr *jwtSerializer) deserialize(
+	s string, n int, token interface{}) (l limits, err error) {
+
+	var h jwtHeader
+	var sg signer
+	var alg string
+	var hb, pb, sig []byte
+	var i, j = strings.IndexByte(s, '.'), strings.LastIndexByte(s, '.')
+
+	// no key accepts a token longer than the longest limit, which is checked
+	// before anything is decoded; the limit of the key follows below
+	if n > sr.keys.maxLength() {
+		return l, decodeError(StageHeader, ErrTooLarge)
+	}
+
+	// a JWT has exactly three parts, and the Signature must not be empty, as
+	// an unsecured JWT (alg = "none") is never accepted
+	if i <= 0 || j == i || j == len(s)-1 ||
+		strings.IndexByte(s[i+1:j], '.') >= 0 {
+		return l, decodeError(StageHeader, ErrBadFormat)
+	}
+
+	if hb, err = jwtEncoding.DecodeString(s[:i]); nil != err {
+		return l, formatError(StageHeader, err)
+	}
+
+	if err = json.Unmarshal(hb, &h); nil != err {
+		return l, formatError(StageHeader, err)
+	}
+
+	// no JWS extensions are understood, so any "crit" parameter is rejected
+	// (see RFC 7515, sec. 4.1.11)
+	if nil != h.Crit ||
+		(0 != len(h.Typ) && !strings.EqualFold(h.Typ, "JWT")) {
+		return l, withAlg(decodeError(StageHeader, ErrBadFormat), h.Alg)
+	}
+
+	if !jwsAlgs[h.Alg] || (nil != sr.allow && !sr.allow[h.Alg]) {
+		return l, withAlg(decodeError(StageHeader, ErrBadAlg), h.Alg)
+	}
+
+	if sg, err = sr.keys.verifyKey(h.Kid); nil != err {
+		return l, withAlg(decodeError(StageHeader, err), h.Alg)
+	}
+
+	// the algorithm is bound to the key, not to the token; a token cannot pick
+	// a different algorithm to be verified with, e.g. HS256 with an RSA key
+	if alg, err = jwsAlg(sg); nil != err || alg != h.Alg {
+		return l, withAlg(decodeError(StageHeader, ErrBadAlg), h.Alg)
+	}
+
+	var o = sg.options()
+
+	if err = o.checkLength(n); nil != err {
+		return l, withAlg(err, alg)
+	}
+
+	l = o.limits()
+
+	if sig, err = jwtEncoding.DecodeString(s[j+1:]); nil != err {
+		return l, withAlg(formatError(StageSignature, err), alg)
+	}
+
+	if err = sg.compareSign([]byte(s[:j]), sig); nil != err {
+		return l, withAlg(decodeError(StageSignature, err), alg)
+	}
+
+	if pb, err = jwtEncoding.DecodeString(s[i+1 : j]); nil != err {
+		return l, withAlg(formatError(StagePayload, err), alg)
+	}
+
+	return l, withAlg(decodeError(StageCodec,
+		l.unmarshal(JSON, pb, token)), alg)
+}
+
+// jwsAlg returns the JWS algorithm name of the signer sg, or an error if the
+// method and hash of sg do not match any JWS algorithm.
+func jwsAlg(sg signer) (string, error) {
+	var m, h = sg.algorithm()
+	var size string
+
+	switch h {
+	case crypto.SHA256:
+		size = "256"
+	case crypto.SHA384:
+		size = "384"
+	case crypto.SHA512:
+		size = "512"
+	}
+
+	switch m {
+	case SignEdDSA:
+		return "EdDSA", nil
+
+	case SignHMAC:
+		if 0 != len(size) {
+			return "HS" + size, nil
+		}
+
+	case SignRSA:
+		if 0 != len(size) {
+			return "RS" + size, nil
+		}
+
+	case SignPSS:
+		if 0 != len(size) {
+			return "PS" + size, nil
+		}
+
+	case SignECDSA:
+		var curve elliptic.Curve
+
+		if cs, ok := sg.(interface{ public() crypto.PublicKey }); ok {
+			if k, ok := cs.public().(*ecdsa.PublicKey); ok {
+				curve = k.Curve
+			}
+		}
+
+		switch {
+		case h == crypto.SHA256 && curve == elliptic.P256(),
+			h == crypto.SHA384 && curve == elliptic.P384(),
+			h == crypto.SHA512 && curve == elliptic.P521():
+			return "ES" + size, nil
+		}
+	}
+
+	return "", errorf(efJWSAlg, "", m, h)
+}
+
+// parseJWSAlg returns the SignMethod and hash of the JWS algorithm name alg
+// (see jwsAlg), or false if alg is not supported.
+func parseJWSAlg(alg string) (m SignMethod, h crypto.Hash, ok bool) {
+	if !jwsAlgs[alg] {
+		return
+	}
+
+	if "EdDSA" == alg {
+		return SignEdDSA, 0, true
+	}
+
+	switch alg[:2] {
+	case "HS":
+		m = SignHMAC
+	case "RS":
+		m = SignRSA
+	case "PS":
+		m = SignPSS
+	case "ES":
+		m = SignECDSA
+	}
+
+	switch alg[2:] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	}
+
+	return m, h, true
+}
+
+// writeBase64 writes the Base64 (url-safe, unpadded) encoding of b to buf.
+func writeBase64(buf *bytes.Buffer, b []byte) {
+	var b64 = base64.NewEncoder(base64.RawURLEncoding, buf)
+
+	// writes to a bytes.Buffer never fail
+	b64.Write(b)
+	b64.Close()
+}
@@ -0,0 +1,238 @@
+package serializer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	// needed for crypto.SHA512 being used with ES512
+	_ "crypto/sha512"
+)
+
+func TestNewJWT(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var noneSer, _ = New(SignNone, nil, 0)
+
+	if ser, err := NewJWT(noneSer); nil == err || nil != ser {
+		t.Error("expect error for SignNone Serializer")
+	}
+
+	if ser, err := NewJWT(hmacSer, "none"); nil == err || nil != ser {
+		t.Error("expect error for allow = \"none\"")
+	}
+
+	if ser, err := NewJWT(hmacSer, "HS1"); nil == err || nil != ser {
+		t.Error("expect error for unsupported algorithm")
+	}
+
+	if ser, _ := New(SignHMAC, tRandBuf[:256], crypto.SHA224); nil == ser {
+		t.Fatal("cannot construct HMAC-SHA224 Serializer")
+	} else if ser, err := NewJWT(ser); nil == err || nil != ser {
+		t.Error("expect error for HMAC-SHA224 (no JWS algorithm)")
+	}
+
+	if ser, _ := New(SignECDSA, tECDSAKey, crypto.SHA384); nil == ser {
+		t.Fatal("cannot construct P-256/SHA384 Serializer")
+	} else if ser, err := NewJWT(ser); nil == err || nil != ser {
+		t.Error("expect error for hash not matching curve")
+	}
+}
+
+func TestJWT(t *testing.T) {
+	var p521Key, _ = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+
+	var base = func(m SignMethod, k interface{},
+		h crypto.Hash, alg string) func(*testing.T) {
+		return func(t *testing.T) {
+			var ser, jwt Serializer
+			var hdr jwtHeader
+			var p tPayloadT
+			var s string
+			var err error
+
+			if ser, err = New(m, k, h); nil != err {
+				t.Fatal(err)
+			} else if jwt, err = NewJWT(ser); nil != err {
+				t.Fatal(err)
+			} else if s, err = jwt.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			}
+
+			if parts := strings.Split(s, "."); 3 != len(parts) {
+				t.Fatalf("expect 3 parts in JWT, got %d", len(parts))
+			} else if b, err := base64.RawURLEncoding.
+				DecodeString(parts[0]); nil != err {
+				t.Fatal(err)
+			} else if err = json.Unmarshal(b, &hdr); nil != err {
+				t.Fatal(err)
+			} else if hdr.Alg != alg || hdr.Typ != "JWT" {
+				t.Errorf("unexpected JOSE Header %s", b)
+			}
+
+			if err = jwt.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			if err = jwt.Deserialize(tamperSign(s),
+				&p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for tampered token, got (%v)", err)
+			}
+		}
+	}
+
+	t.Run("HS256", base(SignHMAC, tRandBuf[:256], crypto.SHA256, "HS256"))
+	t.Run("RS256", base(SignRSA, tRSAKey, crypto.SHA256, "RS256"))
+	t.Run("PS256", base(SignPSS, tRSAKey, crypto.SHA256, "PS256"))
+	t.Run("ES256", base(SignECDSA, tECDSAKey, crypto.SHA256, "ES256"))
+	t.Run("ES512", base(SignECDSA, p521Key, crypto.SHA512, "ES512"))
+	t.Run("EdDSA", base(SignEdDSA, tEdDSAKey, 0, "EdDSA"))
+
+	// the Signature of an HS256 JWT is the HMAC of the signing input, so it
+	// can be checked independently
+	t.Run("Interop", func(t *testing.T) {
+		var ser, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+		var jwt, _ = NewJWT(ser)
+		var s, err = jwt.Serialize(tPayload)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		var i = strings.LastIndexByte(s, '.')
+		var mac = hmac.New(crypto.SHA256.New, tRandBuf[:256])
+		mac.Write([]byte(s[:i]))
+
+		if exp := base64.RawURLEncoding.EncodeToString(
+			mac.Sum(nil)); exp != s[i+1:] {
+			t.Errorf("JWS Signature does not match HMAC-SHA256 of signing input")
+		}
+	})
+
+	// the HS256 JWS of RFC 7515, appendix A.1, whose key is shorter than
+	// the minimum of New, and so is set directly
+	t.Run("RFC7515", func(t *testing.T) {
+		var key, _ = base64.RawURLEncoding.DecodeString("AyM1SysPpbyDfgZl" +
+			"d3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwj" +
+			"AzZr1Z9CAow")
+		var s = "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9." +
+			"eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9" +
+			"leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ." +
+			"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		var jwt, _ = NewJWT(&hmacSerializer{key: key, hash: crypto.SHA256})
+		var exp = map[string]interface{}{"iss": "joe",
+			"exp": float64(1300819380), "http://example.com/is_root": true}
+		var p map[string]interface{}
+
+		if err := jwt.Deserialize(s, &p); nil != err {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(exp, p) {
+			t.Errorf("expect %v, got %v", exp, p)
+		}
+
+		if err := jwt.Deserialize(s[:len(s)-2]+"Ak", &p); nil == err {
+			t.Error("expect error for bad signature")
+		}
+	})
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	var rsaSer, _ = New(SignRSA, tRSAKey, crypto.SHA256)
+	var rsaJWT, _ = NewJWT(rsaSer)
+	var p tPayloadT
+
+	var forge = func(hdr string) string {
+		var s, err = rsaJWT.Serialize(tPayload)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		return base64.RawURLEncoding.EncodeToString([]byte(hdr)) +
+			s[strings.IndexByte(s, '.'):]
+	}
+
+	// unsecured JWTs are rejected, with or without the Signature part
+	t.Run("None", func(t *testing.T) {
+		var s = forge(`{"alg":"none"}`)
+
+		if err := rsaJWT.Deserialize(s, &p); !errors.Is(err, ErrBadAlg) {
+			t.Errorf("expect ErrBadAlg for alg = none, got (%v)", err)
+		}
+
+		s = s[:strings.LastIndexByte(s, '.')+1]
+		if err := rsaJWT.Deserialize(s, &p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat for empty Signature, got (%v)", err)
+		}
+	})
+
+	// a token cannot pick an algorithm other than that of the key, e.g. an
+	// HMAC keyed with the RSA public key
+	t.Run("Confusion", func(t *testing.T) {
+		for _, alg := range []string{"HS256", "PS256", "RS512"} {
+			var s = forge(`{"alg":"` + alg + `"}`)
+			if err := rsaJWT.Deserialize(s, &p); !errors.Is(err, ErrBadAlg) {
+				t.Errorf("expect ErrBadAlg for alg = %s, got (%v)", alg, err)
+			}
+		}
+	})
+
+	t.Run("Allowlist", func(t *testing.T) {
+		var jwt, _ = NewJWT(rsaSer, "PS256")
+		var s, _ = rsaJWT.Serialize(tPayload)
+
+		if err := jwt.Deserialize(s, &p); !errors.Is(err, ErrBadAlg) {
+			t.Errorf("expect ErrBadAlg for disallowed RS256, got (%v)", err)
+		}
+	})
+
+	t.Run("Crit", func(t *testing.T) {
+		var s = forge(`{"alg":"RS256","crit":["exp"]}`)
+		if err := rsaJWT.Deserialize(s, &p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat for crit, got (%v)", err)
+		}
+	})
+}
+
+func TestJWTKeyRing(t *testing.T) {
+	var kr = NewKeyRing()
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var jwt Serializer
+	var s0, s1 string
+	var p tPayloadT
+	var err error
+
+	kr.Add("k0", hmacSer)
+	kr.Add("k1", edSer)
+	kr.Activate("k0")
+
+	if jwt, err = NewJWT(kr); nil != err {
+		t.Fatal(err)
+	} else if s0, err = jwt.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	}
+
+	kr.Activate("k1")
+	if s1, err = jwt.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{s0, s1} {
+		if err = jwt.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		}
+	}
+
+	kr.Remove("k0")
+	if err = jwt.Deserialize(s0, &p); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expect ErrUnknownKey for removed key, got (%v)", err)
+	}
+}
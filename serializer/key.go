@@ -0,0 +1,392 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+
+	// the hashes inferred for keys must be available (see KeyMethod)
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Key is a signing or verification key along with the SignMethod and hash
+// that it is used with, as returned by ParseKey and LoadKey.
+type Key struct {
+	// Key is one of *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey,
+	// the matching public keys, or a byte-slice for SignHMAC.
+	Key interface{}
+
+	// Method and Hash are the arguments for New or NewVerifier. They are
+	// inferred from Key (see KeyMethod), unless a JSON Web Key names its
+	// algorithm.
+	Method SignMethod
+	Hash   crypto.Hash
+}
+
+// jwk is a JSON Web Key (see RFC 7517, sec. 4 and RFC 7518, sec. 6). Only the
+// members needed to construct a Key, or to publish a public key (see JWKS),
+// are included.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+
+	// RSA (n, e, d, p, q), EC (x, y, d), OKP (x, d) and symmetric (k) keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	D string `json:"d,omitempty"`
+	P string `json:"p,omitempty"`
+	Q string `json:"q,omitempty"`
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	K string `json:"k,omitempty"`
+}
+
+// KeyMethod infers the SignMethod and hash to be used with key, which must be
+// one of the key types accepted by New or NewVerifier, or a crypto.Signer:
+//
+//     RSA keys     SignRSA with crypto.SHA256 (RS256)
+//     ECDSA keys   SignECDSA with the hash matching the curve (ES256, ES384
+//                  and ES512 for P-256, P-384 and P-521)
+//     Ed25519 keys SignEdDSA
+//     []byte       SignHMAC with crypto.SHA256 (HS256)
+//
+// An error is returned for any other key type.
+func KeyMethod(key interface{}) (m SignMethod, h crypto.Hash, err error) {
+	// the public keys of nil pointers cannot be inferred
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if nil == k {
+			return 0, 0, errorf(efNoKeyMethod, "", key)
+		}
+	case *ecdsa.PrivateKey:
+		if nil == k {
+			return 0, 0, errorf(efNoKeyMethod, "", key)
+		}
+	}
+
+	if sg, ok := key.(crypto.Signer); ok {
+		key = sg.Public()
+	}
+
+	switch k := key.(type) {
+	case []byte:
+		return SignHMAC, crypto.SHA256, nil
+
+	case *rsa.PublicKey:
+		return SignRSA, crypto.SHA256, nil
+
+	case *ecdsa.PublicKey:
+		if nil != k {
+			if h = curveHash(k.Curve); 0 != h {
+				return SignECDSA, h, nil
+			}
+		}
+
+	case ed25519.PublicKey:
+		return SignEdDSA, 0, nil
+	}
+
+	return 0, 0, errorf(efNoKeyMethod, "", key)
+}
+
+// ParseKey parses a key from its PEM or DER encoding, or from its JSON Web Key
+// (RFC 7517) encoding, and infers the SignMethod and hash it is used with (see
+// KeyMethod). The following encodings are supported:
+//
+//     PKCS #1 RSA private and public keys ("RSA PRIVATE KEY", "RSA PUBLIC KEY")
+//     PKCS #8 private keys ("PRIVATE KEY")
+//     SEC 1 EC private keys ("EC PRIVATE KEY")
+//     SPKI (PKIX) public keys ("PUBLIC KEY")
+//     JSON Web Keys of type "RSA", "EC", "OKP" (Ed25519) and "oct"
+//
+// Only the first key in PEM encoded input is parsed; other PEM blocks, such
+// as "EC PARAMETERS", are skipped. Encrypted keys are not supported. The "alg"
+// member of a JSON Web Key, if present, overrides the inferred SignMethod and
+// hash, e.g. "PS256" for an RSA key.
+func ParseKey(b []byte) (k *Key, err error) {
+	var key interface{}
+	var alg string
+
+	switch t := bytes.TrimSpace(b); {
+	case 0 != len(t) && '{' == t[0]:
+		key, alg, err = parseJWK(t)
+
+	case bytes.HasPrefix(t, []byte("-----BEGIN")):
+		key, err = parsePEM(t)
+
+	default:
+		key, err = parseDER(b)
+	}
+
+	if nil != err {
+		return
+	}
+
+	k = &Key{Key: key}
+	if k.Method, k.Hash, err = KeyMethod(key); nil != err {
+		return nil, err
+	}
+
+	if 0 != len(alg) {
+		if err = k.setAlg(alg); nil != err {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// LoadKey reads the file at path and parses the key it contains (see
+// ParseKey).
+func LoadKey(path string) (k *Key, err error) {
+	var b []byte
+
+	if b, err = ioutil.ReadFile(path); nil != err {
+		return
+	}
+
+	return ParseKey(b)
+}
+
+// Serializer returns a Serializer that uses k. If k holds a public key, the
+// Serializer is verify-only (see NewVerifier), otherwise it is the one
+// returned by New.
+func (k *Key) Serializer() (Serializer, error) {
+	switch k.Key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return NewVerifier(k.Method, k.Key, k.Hash)
+	}
+
+	return New(k.Method, k.Key, k.Hash)
+}
+
+// setAlg sets the SignMethod and hash of k from the JWS algorithm name alg.
+// An error is returned if alg is not supported or cannot be used with the key
+// type of k, e.g. "ES384" with a P-256 key.
+func (k *Key) setAlg(alg string) error {
+	var m, h, ok = parseJWSAlg(alg)
+
+	switch {
+	case !ok:
+		break
+
+	case m == k.Method && (m != SignECDSA || h == k.Hash),
+		m == SignPSS && k.Method == SignRSA:
+		k.Method, k.Hash = m, h
+		return nil
+	}
+
+	return errorf(efBadKeyAlg, "", alg, k.Key)
+}
+
+// parsePEM parses the first key in the PEM encoded input b.
+func parsePEM(b []byte) (key interface{}, err error) {
+	for {
+		var p *pem.Block
+
+		if p, b = pem.Decode(b); nil == p {
+			return nil, errorf(efBadKeyData, "", "no PEM encoded key")
+		}
+
+		// keys encrypted with RFC 1423 or PKCS #8 are not supported
+		if _, ok := p.Headers["Proc-Type"]; ok ||
+			"ENCRYPTED PRIVATE KEY" == p.Type {
+			return nil, errorf(efBadKeyData, "", "encrypted PEM block")
+		}
+
+		switch p.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(p.Bytes)
+		case "RSA PUBLIC KEY":
+			return x509.ParsePKCS1PublicKey(p.Bytes)
+		case "PRIVATE KEY":
+			return x509.ParsePKCS8PrivateKey(p.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(p.Bytes)
+		case "PUBLIC KEY":
+			return x509.ParsePKIXPublicKey(p.Bytes)
+		}
+	}
+}
+
+// parseDER parses the DER encoded key b, trying each of the supported
+// encodings in turn.
+func parseDER(b []byte) (key interface{}, err error) {
+	if key, err = x509.ParsePKCS8PrivateKey(b); nil == err {
+		return
+	}
+
+	if key, err = x509.ParsePKCS1PrivateKey(b); nil == err {
+		return
+	}
+
+	if key, err = x509.ParseECPrivateKey(b); nil == err {
+		return
+	}
+
+	if key, err = x509.ParsePKIXPublicKey(b); nil == err {
+		return
+	}
+
+	if key, err = x509.ParsePKCS1PublicKey(b); nil == err {
+		return
+	}
+
+	return nil, errorf(efBadKeyData, "", "unsupported DER encoding")
+}
+
+// parseJWK parses the JSON Web Key b and returns the key along with the value
+// of its "alg" member.
+func parseJWK(b []byte) (key interface{}, alg string, err error) {
+	var j jwk
+	var bad = errorf(efBadKeyData, "", "malformed or unsupported JWK")
+
+	if err = json.Unmarshal(b, &j); nil != err {
+		return nil, "", bad
+	}
+
+	switch j.Kty {
+	case "oct":
+		if key, err = jwtEncoding.DecodeString(j.K); nil != err {
+			return nil, "", bad
+		}
+
+	case "RSA":
+		var pub = &rsa.PublicKey{}
+		var n, e *big.Int
+
+		if n, e = jwkInt(j.N), jwkInt(j.E); nil == n || nil == e ||
+			!e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, "", bad
+		}
+
+		pub.N, pub.E, key = n, int(e.Int64()), pub
+
+		if 0 != len(j.D) {
+			var priv = &rsa.PrivateKey{PublicKey: *pub}
+
+			// the CRT parameters are recomputed; the primes are needed
+			priv.D = jwkInt(j.D)
+			priv.Primes = []*big.Int{jwkInt(j.P), jwkInt(j.Q)}
+			if nil == priv.D || nil == priv.Primes[0] || nil == priv.Primes[1] {
+				return nil, "", bad
+			}
+
+			priv.Precompute()
+			key = priv
+		}
+
+	case "EC":
+		var pub = &ecdsa.PublicKey{}
+		var size int
+
+		switch j.Crv {
+		case "P-256":
+			pub.Curve = elliptic.P256()
+		case "P-384":
+			pub.Curve = elliptic.P384()
+		case "P-521":
+			pub.Curve = elliptic.P521()
+		default:
+			return nil, "", bad
+		}
+
+		size = (pub.Curve.Params().BitSize + 7) / 8
+		if pub.X, pub.Y = jwkInt(j.X), jwkInt(j.Y); nil == pub.X ||
+			nil == pub.Y || len(j.X) != len(j.Y) ||
+			jwtEncoding.DecodedLen(len(j.X)) != size ||
+			!pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, "", bad
+		}
+
+		key = pub
+
+		if 0 != len(j.D) {
+			var priv = &ecdsa.PrivateKey{PublicKey: *pub, D: jwkInt(j.D)}
+
+			if nil == priv.D {
+				return nil, "", bad
+			}
+
+			// the private key must match the public key
+			var x, y = pub.Curve.ScalarBaseMult(priv.D.Bytes())
+			if x.Cmp(pub.X) != 0 || y.Cmp(pub.Y) != 0 {
+				return nil, "", bad
+			}
+
+			key = priv
+		}
+
+	case "OKP":
+		var x, d []byte
+
+		if "Ed25519" != j.Crv {
+			return nil, "", bad
+		}
+
+		if x, err = jwtEncoding.DecodeString(j.X); nil != err ||
+			len(x) != ed25519.PublicKeySize {
+			return nil, "", bad
+		}
+
+		key = ed25519.PublicKey(x)
+
+		if 0 != len(j.D) {
+			if d, err = jwtEncoding.DecodeString(j.D); nil != err ||
+				len(d) != ed25519.SeedSize {
+				return nil, "", bad
+			}
+
+			// the private key must match the public key
+			var priv = ed25519.NewKeyFromSeed(d)
+			if !bytes.Equal(priv.Public().(ed25519.PublicKey), x) {
+				return nil, "", bad
+			}
+
+			key = priv
+		}
+
+	default:
+		return nil, "", bad
+	}
+
+	return key, j.Alg, nil
+}
+
+// jwkInt decodes the Base64urlUInt encoded (see RFC 7518, sec. 2) integer s,
+// or returns nil if s is empty or not properly encoded.
+func jwkInt(s string) *big.Int {
+	var b, err = jwtEncoding.DecodeString(s)
+	if nil != err || 0 == len(b) {
+		return nil
+	}
+
+	return new(big.Int).SetBytes(b)
+}
+
+// curveHash returns the hash that matches the elliptic curve c, as used by
+// the JWS "ES" algorithms, or zero for any other curve.
+func curveHash(c elliptic.Curve) crypto.Hash {
+	switch c {
+	case elliptic.P256():
+		return crypto.SHA256
+	case elliptic.P384():
+		return crypto.SHA384
+	case elliptic.P521():
+		return crypto.SHA512
+	}
+
+	return 0
+}
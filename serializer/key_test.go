@@ -0,0 +1,267 @@
+package serializer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKeyMethod(t *testing.T) {
+	var p384Key, _ = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	var p224Key, _ = ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+
+	for _, c := range []struct {
+		key interface{}
+		m   SignMethod
+		h   crypto.Hash
+	}{
+		{tRandBuf[:], SignHMAC, crypto.SHA256},
+		{tRSAKey, SignRSA, crypto.SHA256},
+		{&tRSAKey.PublicKey, SignRSA, crypto.SHA256},
+		{tECDSAKey, SignECDSA, crypto.SHA256},
+		{p384Key, SignECDSA, crypto.SHA384},
+		{&p384Key.PublicKey, SignECDSA, crypto.SHA384},
+		{tEdDSAKey, SignEdDSA, 0},
+		{tEdDSAKey.Public(), SignEdDSA, 0},
+		{&tSigner{key: tECDSAKey}, SignECDSA, crypto.SHA256},
+	} {
+		if m, h, err := KeyMethod(c.key); nil != err {
+			t.Errorf("%T: %v", c.key, err)
+		} else if m != c.m || h != c.h {
+			t.Errorf("%T: expect method #%d with hash #%d, got #%d with #%d",
+				c.key, c.m, c.h, m, h)
+		}
+	}
+
+	for _, key := range []interface{}{
+		nil, "", (*rsa.PrivateKey)(nil), (*ecdsa.PublicKey)(nil), p224Key,
+	} {
+		if _, _, err := KeyMethod(key); nil == err {
+			t.Errorf("expect error for key (%T)", key)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	var base = func(b []byte, exp interface{}) func(*testing.T) {
+		return func(t *testing.T) {
+			var k *Key
+			var ser, ver Serializer
+			var p tPayloadT
+			var s string
+			var err error
+
+			if k, err = ParseKey(b); nil != err {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(k.Key, exp) {
+				t.Fatalf("parsed key (%T) does not match expectation", k.Key)
+			}
+
+			if ser, err = k.Serializer(); nil != err {
+				t.Fatal(err)
+			}
+
+			// a Serializer from the original private key verifies StringTokens
+			// signed with the parsed key, and the other way round
+			switch exp.(type) {
+			case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+				ver = ser
+				ser, _ = New(k.Method, privateKeyOf(exp), k.Hash)
+			default:
+				ver, _ = New(k.Method, exp, k.Hash)
+			}
+
+			if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			} else if err = ver.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			}
+		}
+	}
+
+	var pemOf = func(typ string, b []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})
+	}
+
+	var pkcs8 = func(k interface{}) []byte {
+		var b, err = x509.MarshalPKCS8PrivateKey(k)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	var spki = func(k interface{}) []byte {
+		var b, err = x509.MarshalPKIXPublicKey(k)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	var sec1, _ = x509.MarshalECPrivateKey(tECDSAKey)
+	var ecParams = pemOf("EC PARAMETERS", []byte{0x06, 0x08, 0x2a, 0x86,
+		0x48, 0xce, 0x3d, 0x03, 0x01, 0x07})
+
+	t.Run("PKCS1", base(pemOf("RSA PRIVATE KEY",
+		x509.MarshalPKCS1PrivateKey(tRSAKey)), tRSAKey))
+	t.Run("PKCS1/Public", base(pemOf("RSA PUBLIC KEY",
+		x509.MarshalPKCS1PublicKey(&tRSAKey.PublicKey)), &tRSAKey.PublicKey))
+	t.Run("PKCS8/RSA", base(pemOf("PRIVATE KEY", pkcs8(tRSAKey)), tRSAKey))
+	t.Run("PKCS8/ECDSA", base(pemOf("PRIVATE KEY",
+		pkcs8(tECDSAKey)), tECDSAKey))
+	t.Run("PKCS8/EdDSA", base(pemOf("PRIVATE KEY",
+		pkcs8(tEdDSAKey)), tEdDSAKey))
+	t.Run("SEC1", base(append(ecParams,
+		pemOf("EC PRIVATE KEY", sec1)...), tECDSAKey))
+	t.Run("SPKI/RSA", base(pemOf("PUBLIC KEY",
+		spki(&tRSAKey.PublicKey)), &tRSAKey.PublicKey))
+	t.Run("SPKI/ECDSA", base(pemOf("PUBLIC KEY",
+		spki(&tECDSAKey.PublicKey)), &tECDSAKey.PublicKey))
+	t.Run("SPKI/EdDSA", base(pemOf("PUBLIC KEY",
+		spki(tEdDSAKey.Public())), tEdDSAKey.Public()))
+	t.Run("DER/PKCS8", base(pkcs8(tEdDSAKey), tEdDSAKey))
+	t.Run("DER/SEC1", base(sec1, tECDSAKey))
+	t.Run("DER/SPKI", base(spki(&tRSAKey.PublicKey), &tRSAKey.PublicKey))
+
+	t.Run("Bad", func(t *testing.T) {
+		for _, b := range [][]byte{
+			nil,
+			[]byte("not a key"),
+			pemOf("CERTIFICATE", []byte("foo")),
+			pemOf("ENCRYPTED PRIVATE KEY", pkcs8(tEdDSAKey)),
+			ecParams,
+		} {
+			if k, err := ParseKey(b); nil == err || nil != k {
+				t.Errorf("expect error for input %q", b)
+			}
+		}
+	})
+}
+
+func TestParseJWK(t *testing.T) {
+	var b64 = base64.RawURLEncoding.EncodeToString
+	var b64Int = func(i *big.Int) string { return b64(i.Bytes()) }
+	var b64Fixed = func(i *big.Int) string {
+		return b64(i.FillBytes(make([]byte, 32)))
+	}
+
+	var rsaJWK = `{"kty":"RSA","n":"` + b64Int(tRSAKey.N) +
+		`","e":"` + b64Int(big.NewInt(int64(tRSAKey.E))) + `"`
+	var rsaPriv = rsaJWK + `,"d":"` + b64Int(tRSAKey.D) +
+		`","p":"` + b64Int(tRSAKey.Primes[0]) +
+		`","q":"` + b64Int(tRSAKey.Primes[1]) + `"`
+	var ecJWK = `{"kty":"EC","crv":"P-256","x":"` + b64Fixed(tECDSAKey.X) +
+		`","y":"` + b64Fixed(tECDSAKey.Y) + `"`
+	var okpJWK = `{"kty":"OKP","crv":"Ed25519","x":"` +
+		b64(tEdDSAKey.Public().(ed25519.PublicKey)) + `"`
+
+	for _, c := range []struct {
+		name, jwk string
+		m         SignMethod
+		h         crypto.Hash
+	}{
+		{"oct", `{"kty":"oct","k":"` + b64(tRandBuf[:256]) + `"}`,
+			SignHMAC, crypto.SHA256},
+		{"oct/HS512", `{"kty":"oct","alg":"HS512","k":"` +
+			b64(tRandBuf[:256]) + `"}`, SignHMAC, crypto.SHA512},
+		{"RSA", rsaPriv + `}`, SignRSA, crypto.SHA256},
+		{"RSA/PS384", rsaPriv + `,"alg":"PS384"}`, SignPSS, crypto.SHA384},
+		{"RSA/Public", rsaJWK + `}`, SignRSA, crypto.SHA256},
+		{"EC", ecJWK + `,"d":"` + b64Fixed(tECDSAKey.D) + `"}`,
+			SignECDSA, crypto.SHA256},
+		{"EC/Public", ecJWK + `}`, SignECDSA, crypto.SHA256},
+		{"OKP", okpJWK + `,"d":"` + b64(tEdDSAKey.Seed()) + `"}`,
+			SignEdDSA, 0},
+		{"OKP/Public", okpJWK + `}`, SignEdDSA, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var k *Key
+			var err error
+
+			if k, err = ParseKey([]byte(c.jwk)); nil != err {
+				t.Fatal(err)
+			} else if k.Method != c.m || k.Hash != c.h {
+				t.Errorf("expect method #%d with hash #%d, got #%d with #%d",
+					c.m, c.h, k.Method, k.Hash)
+			} else if _, err = k.Serializer(); nil != err {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("Bad", func(t *testing.T) {
+		var other, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		for _, jwk := range []string{
+			`{}`,
+			`{"kty":"oct","k":"!"}`,
+			`{"kty":"RSA","n":"` + b64Int(tRSAKey.N) + `"}`,
+			rsaPriv + `,"alg":"ES256"}`,
+			ecJWK + `,"alg":"ES384"}`,
+			ecJWK + `,"d":"` + b64Fixed(other.D) + `"}`,
+			`{"kty":"EC","crv":"P-256","x":"` + b64Fixed(tECDSAKey.X) +
+				`","y":"` + b64Fixed(tECDSAKey.X) + `"}`,
+			okpJWK + `,"d":"` + b64(tRandBuf[:32]) + `"}`,
+			`{"kty":"OKP","crv":"X25519","x":"` + b64(tRandBuf[:32]) + `"}`,
+		} {
+			if k, err := ParseKey([]byte(jwk)); nil == err || nil != k {
+				t.Errorf("expect error for JWK %s", jwk)
+			}
+		}
+	})
+}
+
+func TestLoadKey(t *testing.T) {
+	var dir, err = ioutil.TempDir("", "serializer")
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "key.pem")
+	var b, _ = x509.MarshalPKCS8PrivateKey(tEdDSAKey)
+
+	if err = ioutil.WriteFile(path, pem.EncodeToMemory(
+		&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0600); nil != err {
+		t.Fatal(err)
+	}
+
+	if k, err := LoadKey(path); nil != err {
+		t.Error(err)
+	} else if !reflect.DeepEqual(k, &Key{tEdDSAKey, SignEdDSA, 0}) {
+		t.Error("loaded key does not match expectation")
+	}
+
+	if k, err := LoadKey(filepath.Join(dir, "missing.pem")); nil == err ||
+		nil != k {
+		t.Error("expect error for missing file")
+	}
+}
+
+// privateKeyOf returns the test private key of the public key pub.
+func privateKeyOf(pub interface{}) interface{} {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return tRSAKey
+	case *ecdsa.PublicKey:
+		return tECDSAKey
+	}
+
+	return tEdDSAKey
+}
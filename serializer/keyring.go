@@ -0,0 +1,468 @@
+package serializer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// paramKeyID is the name of the Header parameter that carries the key ID of
+// the key that signed a StringToken.
+const paramKeyID = "kid"
+
+// KeyRing is a Serializer that holds several signing or sealing keys, each
+// identified by a key ID, and allows keys to be rotated without invalidating
+// StringTokens that are still in circulation.
+//
+// A KeyRing signs (or seals, see NewSealer) StringTokens with its active key
+// and records the key ID of the active key in the Header, for example:
+//
+//     auth;kid=2025-10.[Payload].[Signature]
+//     auth;kid=2025-11;enc=XC20P.[Nonce+Ciphertext]
+//
+// During deserialization the key ID is read from the Header and the
+// StringToken is verified with the matching key, as long as that key is still
+// in the KeyRing. StringTokens without a key ID are rejected.
+//
+// A typical rotation adds the new key, activates it and retires the old key
+// until the StringTokens it signed have expired:
+//
+//     kr.Add("2025-11", ser)
+//     kr.Activate("2025-11")
+//     kr.Retire("2025-10", time.Now().Add(72*time.Hour))
+//
+// The zero-value of KeyRing is not usable; use NewKeyRing. All methods of
+// KeyRing are safe for concurrent use.
+type KeyRing struct {
+	mu     sync.RWMutex
+	keys   map[string]*ringKey
+	active string
+	gen    uint64
+}
+
+// ringKey is a key stored in a KeyRing; either sg or sl is set. ringKeys are
+// never mutated once they are stored in a KeyRing, so they can be used after
+// the lock is released.
+type ringKey struct {
+	sg      signer
+	sl      *sealSerializer
+	retired bool
+	until   time.Time
+}
+
+// NewKeyRing returns an empty KeyRing. Keys must be added and one of them
+// activated before the KeyRing can generate StringTokens.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]*ringKey)}
+}
+
+// Serialize makes KeyRing implement the Serializer interface. The StringToken
+// is signed or sealed with the active key. ErrNoActiveKey is returned if no
+// key was activated.
+func (kr *KeyRing) Serialize(token interface{}) (s string, err error) {
+	return kr.serialize(context.Background(), nil, token)
+}
+
+// serialize implements Serialize, SerializeContext and SerializeAD; ctx is
+// bound to the active key (see bindContext), and the StringToken to ad (see
+// appendAD).
+func (kr *KeyRing) serialize(ctx context.Context,
+	ad []byte, token interface{}) (s string, err error) {
+
+	var kid string
+	var k *ringKey
+
+	if kid, k = kr.activeKey(); nil == k {
+		return "", ErrNoActiveKey
+	}
+
+	var ps = []param{{paramKeyID, kid}}
+
+	if nil != k.sl {
+		return k.sl.seal(formatHeader(append(ps, k.sl.params()...)...), ad,
+			token)
+	}
+
+	var sg = bindContext(ctx, k.sg)
+	var o = sg.options()
+	var alg = algName(sg.algorithm())
+
+	return headerSerialize(formatHeader(append(ps, o.params(alg)...)...), o,
+		token, adWriteSign(ad, sg.writeSign))
+}
+
+// Deserialize makes KeyRing implement the Serializer interface. The
+// StringToken is verified with the key identified by the key ID in its
+// Header, within the limits of the Options of that key. ErrUnknownKey is
+// returned if no such key exists in the KeyRing, or if the key was retired and
+// its grace period has elapsed.
+func (kr *KeyRing) Deserialize(s string, token interface{}) (err error) {
+	return kr.deserialize(s, nil, token)
+}
+
+// deserialize implements Deserialize and DeserializeAD.
+func (kr *KeyRing) deserialize(
+	s string, ad []byte, token interface{}) (err error) {
+
+	var ps []param
+	var body string
+	var k *ringKey
+	var c Codec
+
+	if ps, body, err = parseHeader(s); nil != err {
+		return decodeError(StageHeader, err)
+	}
+
+	if k, c, err = kr.headerKey(ps); nil != err {
+		return
+	} else if err = k.options().checkLength(len(s)); nil != err {
+		return withAlg(err, k.alg())
+	}
+
+	if nil != k.sl {
+		err = k.sl.open(s[:len(s)-len(body)-1], body, ad, c, token)
+	} else {
+		err = bodyDeserialize(body, c, token,
+			adCompareSign(ad, k.sg.compareSign))
+	}
+
+	return withAlg(err, k.alg())
+}
+
+// AppendSerialize makes KeyRing implement the BytesSerializer interface.
+func (kr *KeyRing) AppendSerialize(
+	dst []byte, token interface{}) ([]byte, error) {
+
+	var kid string
+	var k *ringKey
+
+	if kid, k = kr.activeKey(); nil == k {
+		return dst, ErrNoActiveKey
+	}
+
+	var ps = []param{{paramKeyID, kid}}
+
+	if nil != k.sl {
+		var s, err = k.sl.seal(formatHeader(append(ps,
+			k.sl.params()...)...), nil, token)
+		if nil != err {
+			return dst, err
+		}
+
+		return append(dst, s...), nil
+	}
+
+	var o = k.sg.options()
+	var alg = algName(k.sg.algorithm())
+
+	return headerAppend(dst, formatHeader(append(ps, o.params(alg)...)...), o,
+		token, k.sg.writeSign)
+}
+
+// DeserializeBytes makes KeyRing implement the BytesSerializer interface.
+func (kr *KeyRing) DeserializeBytes(b []byte, token interface{}) (err error) {
+	var ps []param
+	var body []byte
+	var k *ringKey
+	var c Codec
+
+	if ps, body, err = parseHeaderBytes(b); nil != err {
+		return decodeError(StageHeader, err)
+	}
+
+	if k, c, err = kr.headerKey(ps); nil != err {
+		return
+	} else if err = k.options().checkLength(len(b)); nil != err {
+		return withAlg(err, k.alg())
+	}
+
+	if nil != k.sl {
+		err = k.sl.openBytes(b[:len(b)-len(body)-1], body, nil, c, token)
+	} else {
+		err = bodyDeserializeBytes(body, c, token, k.sg.compareSign)
+	}
+
+	return withAlg(err, k.alg())
+}
+
+// headerKey returns the key identified by the Header parameters ps of a
+// StringToken, along with the Codec that decodes its Payload. Errors are
+// returned as *DecodeErrors at StageHeader.
+func (kr *KeyRing) headerKey(ps []param) (k *ringKey, c Codec, err error) {
+	// the key ID must be the first parameter, and may only be followed by the
+	// SealMethod of a sealing key or the algorithm of a signing key, and the
+	// Codec
+	if 0 == len(ps) || ps[0].name != paramKeyID {
+		return nil, nil, decodeError(StageHeader, ErrBadFormat)
+	}
+
+	if k = kr.keyAt(ps[0].value, time.Now()); nil == k {
+		return nil, nil, decodeError(StageHeader, ErrUnknownKey)
+	}
+
+	if nil != k.sl {
+		if 2 > len(ps) || ps[1] != k.sl.param() {
+			return nil, nil, withAlg(decodeError(StageHeader, ErrBadFormat),
+				k.alg())
+		}
+
+		c, err = k.sl.opts.decoder("", ps[2:])
+	} else {
+		c, err = k.sg.options().decoder(algName(k.sg.algorithm()), ps[1:])
+	}
+
+	if nil != err {
+		return nil, nil, withAlg(decodeError(StageHeader, err), k.alg())
+	}
+
+	return
+}
+
+// alg returns the name of the algorithm of k, as in the Header of the
+// StringTokens that k signs or seals.
+func (k *ringKey) alg() string {
+	if nil != k.sl {
+		return sealNames[k.sl.SealMethod]
+	}
+
+	return algName(k.sg.algorithm())
+}
+
+// options returns the Options of the Serializer of k.
+func (k *ringKey) options() *Options {
+	if nil != k.sl {
+		return &k.sl.opts
+	}
+
+	return k.sg.options()
+}
+
+// Add adds the key used by ser to the KeyRing under the key ID kid. ser must
+// be a Serializer returned by New (other than for SignNone), NewVerifier or
+// NewSealer. The key is not used to sign or seal StringTokens until it is
+// activated, but is used right away to verify or open them.
+//
+// kid must be non-empty and may only contain ASCII letters, digits and the
+// characters '-', '_', '~', '+' and '/'. An error is returned if kid is
+// already in use.
+func (kr *KeyRing) Add(kid string, ser Serializer) (err error) {
+	var k = &ringKey{}
+	var ok bool
+
+	if !validParam(kid) {
+		return errorf(efBadKeyID, "", kid)
+	}
+
+	if k.sg, ok = ser.(signer); !ok {
+		if k.sl, ok = ser.(*sealSerializer); !ok {
+			return errorf(efBadSerlr, "", ser, kr)
+		}
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if _, ok = kr.keys[kid]; ok {
+		return errorf(efKeyExists, "", kid)
+	}
+
+	kr.keys[kid] = k
+	kr.gen++
+	return
+}
+
+// Activate makes the key identified by kid the active key of the KeyRing, so
+// that all StringTokens generated afterwards are signed with it. The key that
+// was active before stays in the KeyRing and is still used for verification.
+//
+// An error is returned if kid does not exist, was retired, or was added from
+// a verify-only Serializer (see NewVerifier).
+func (kr *KeyRing) Activate(kid string) (err error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if k, ok := kr.keys[kid]; !ok {
+		return errorf(efKeyMissing, "", kid)
+	} else if k.retired {
+		return errorf(efKeyRetired, "", kid)
+	} else if _, ok = k.sg.(*verifierSerializer); ok {
+		return errorf(efBadSerlr, "", k.sg, kr)
+	}
+
+	kr.active = kid
+	kr.gen++
+	return
+}
+
+// Retire marks the key identified by kid as retired. A retired key can no
+// longer be activated, but is still used to verify StringTokens until the
+// time until. If until is the zero time.Time, the key is used for
+// verification until it is removed.
+//
+// The active key cannot be retired; activate another key first.
+func (kr *KeyRing) Retire(kid string, until time.Time) (err error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if k, ok := kr.keys[kid]; !ok {
+		return errorf(efKeyMissing, "", kid)
+	} else if kid == kr.active {
+		return errorf(efKeyActive, "", kid)
+	} else {
+		kr.keys[kid] = &ringKey{sg: k.sg, sl: k.sl, retired: true, until: until}
+		kr.gen++
+	}
+
+	return
+}
+
+// Remove removes the key identified by kid from the KeyRing. StringTokens
+// signed with the key fail to deserialize with ErrUnknownKey afterwards.
+//
+// The active key cannot be removed; activate another key first.
+func (kr *KeyRing) Remove(kid string) (err error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if _, ok := kr.keys[kid]; !ok {
+		return errorf(efKeyMissing, "", kid)
+	} else if kid == kr.active {
+		return errorf(efKeyActive, "", kid)
+	}
+
+	delete(kr.keys, kid)
+	kr.gen++
+	return
+}
+
+// Active returns the key ID of the active key, or an empty string if no key
+// was activated.
+func (kr *KeyRing) Active() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	return kr.active
+}
+
+// rotation returns a counter of the changes made to the keys of kr, and the
+// earliest time after now at which a retired key stops verifying
+// StringTokens, or the zero time.Time if there is none. StringTokens are
+// verified the same way until either of them changes (see Cache).
+func (kr *KeyRing) rotation(now time.Time) (gen uint64, next time.Time) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, k := range kr.keys {
+		if k.retired && now.Before(k.until) &&
+			(next.IsZero() || k.until.Before(next)) {
+			next = k.until
+		}
+	}
+
+	return kr.gen, next
+}
+
+// activeKey returns the active key and its key ID, or a nil *ringKey if no key
+// was activated.
+func (kr *KeyRing) activeKey() (kid string, k *ringKey) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	if 0 == len(kr.active) {
+		return
+	}
+
+	return kr.active, kr.keys[kr.active]
+}
+
+// signKey makes KeyRing implement the keySource interface. It returns the
+// active key and its key ID, or ErrNoActiveKey if no key was activated. An
+// error is returned if the active key is a sealing key.
+func (kr *KeyRing) signKey() (kid string, sg signer, err error) {
+	var k *ringKey
+
+	if kid, k = kr.activeKey(); nil == k {
+		return "", nil, ErrNoActiveKey
+	} else if nil == k.sg {
+		return "", nil, errorf(efBadSerlr, "", k.sl, kr)
+	}
+
+	return kid, k.sg, nil
+}
+
+// verifyKey makes KeyRing implement the keySource interface. It returns the
+// key identified by kid, or ErrUnknownKey if kid may not be used to verify
+// StringTokens at the current time or is a sealing key.
+func (kr *KeyRing) verifyKey(kid string) (sg signer, err error) {
+	var k *ringKey
+
+	if k = kr.keyAt(kid, time.Now()); nil == k || nil == k.sg {
+		return nil, ErrUnknownKey
+	}
+
+	return k.sg, nil
+}
+
+// maxLength makes KeyRing implement the keySource interface. It returns the
+// largest length limit of its signing keys, or DefaultMaxLength if it has
+// none.
+func (kr *KeyRing) maxLength() (n int) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for _, k := range kr.keys {
+		if nil != k.sg && k.sg.options().maxLength() > n {
+			n = k.sg.options().maxLength()
+		}
+	}
+
+	if 0 == n {
+		return DefaultMaxLength
+	}
+
+	return n
+}
+
+// validKeys returns the signing keys (not the sealing keys) that may be used
+// to verify StringTokens at time now, along with their key IDs. The active key
+// comes first, followed by the others in the order of their key IDs.
+func (kr *KeyRing) validKeys(now time.Time) (kids []string, sgs []signer) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	for kid, k := range kr.keys {
+		if nil != k.sg && kid != kr.active &&
+			!(k.retired && !k.until.IsZero() && now.After(k.until)) {
+			kids = append(kids, kid)
+		}
+	}
+
+	sort.Strings(kids)
+	if k := kr.keys[kr.active]; nil != k && nil != k.sg {
+		kids = append([]string{kr.active}, kids...)
+	}
+
+	for _, kid := range kids {
+		sgs = append(sgs, kr.keys[kid].sg)
+	}
+
+	return
+}
+
+// keyAt returns the key identified by kid if it may be used to verify a
+// StringToken at time now, or nil otherwise.
+func (kr *KeyRing) keyAt(kid string, now time.Time) (k *ringKey) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	if k = kr.keys[kid]; nil == k {
+		return
+	}
+
+	if k.retired && !k.until.IsZero() && now.After(k.until) {
+		return nil
+	}
+
+	return
+}
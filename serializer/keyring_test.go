@@ -0,0 +1,157 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRing(t *testing.T) {
+	var kr = NewKeyRing()
+	var hmacSer, rsaSer, rsaVer Serializer
+	var s0, s1 string
+	var p tPayloadT
+	var err error
+
+	if hmacSer, err = New(SignHMAC, tRandBuf[:256], crypto.SHA256); nil != err {
+		t.Fatal(err)
+	} else if rsaSer, err = New(SignRSA, tRSAKey, crypto.SHA256); nil != err {
+		t.Fatal(err)
+	} else if rsaVer, err = NewVerifier(SignRSA,
+		tRSAKey.Public(), crypto.SHA256); nil != err {
+		t.Fatal(err)
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := kr.Serialize(tPayload); ErrNoActiveKey != err {
+			t.Errorf("expect ErrNoActiveKey, got (%v)", err)
+		}
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		var none, _ = New(SignNone, nil, 0)
+
+		if err := kr.Add("", hmacSer); nil == err {
+			t.Error("expect error for empty key id")
+		} else if err = kr.Add("a.b", hmacSer); nil == err {
+			t.Error("expect error for key id with separator")
+		} else if err = kr.Add("none", none); nil == err {
+			t.Error("expect error for SignNone Serializer")
+		} else if err = kr.Add("ring", NewKeyRing()); nil == err {
+			t.Error("expect error for KeyRing Serializer")
+		}
+
+		if err := kr.Add("2025-10", hmacSer); nil != err {
+			t.Fatal(err)
+		} else if err = kr.Add("2025-10", rsaSer); nil == err {
+			t.Error("expect error for duplicate key id")
+		} else if err = kr.Add("2025-11", rsaSer); nil != err {
+			t.Fatal(err)
+		} else if err = kr.Add("verify", rsaVer); nil != err {
+			t.Fatal(err)
+		}
+
+		if err := kr.Activate("verify"); nil == err {
+			t.Error("expect error activating verify-only key")
+		} else if err = kr.Activate("missing"); nil == err {
+			t.Error("expect error activating missing key")
+		}
+	})
+
+	t.Run("Sign", func(t *testing.T) {
+		if err := kr.Activate("2025-10"); nil != err {
+			t.Fatal(err)
+		} else if s0, err = kr.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(s0, "auth;kid=2025-10.") {
+			t.Errorf("key id not written to Header: %q", s0)
+		}
+
+		if err := kr.Deserialize(s0, &p); nil != err {
+			t.Error(err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("deserialized payload does not match expectation")
+		}
+
+		if err := kr.Deserialize(tamperSign(s0),
+			&p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign for tampered token, got (%v)", err)
+		}
+
+		// the same StringToken with a plain or unknown Header is rejected
+		var body = s0[len("auth;kid=2025-10."):]
+		if err := kr.Deserialize(header+body,
+			&p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat without key id, got (%v)", err)
+		} else if err = kr.Deserialize("auth;kid=xxx."+body,
+			&p); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expect ErrUnknownKey for unknown key id, got (%v)", err)
+		} else if err = kr.Deserialize("auth;kid=2025-10;kid=2025-10."+body,
+			&p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat for repeated key id, got (%v)", err)
+		}
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		if err := kr.Activate("2025-11"); nil != err {
+			t.Fatal(err)
+		} else if s1, err = kr.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(s1, "auth;kid=2025-11.") {
+			t.Errorf("key id not written to Header: %q", s1)
+		}
+
+		// the previously active key still verifies
+		if err := kr.Deserialize(s0, &p); nil != err {
+			t.Error(err)
+		} else if err = kr.Deserialize(s1, &p); nil != err {
+			t.Error(err)
+		}
+
+		// a StringToken signed by one key does not verify with another
+		var s = "auth;kid=2025-10." + s1[len("auth;kid=2025-11."):]
+		if err := kr.Deserialize(s, &p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign for swapped key id, got (%v)", err)
+		}
+	})
+
+	t.Run("Retire", func(t *testing.T) {
+		if err := kr.Retire("2025-11", time.Time{}); nil == err {
+			t.Error("expect error retiring active key")
+		} else if err = kr.Retire("missing", time.Time{}); nil == err {
+			t.Error("expect error retiring missing key")
+		}
+
+		if err := kr.Retire("2025-10", time.Now().Add(time.Hour)); nil != err {
+			t.Fatal(err)
+		} else if err = kr.Activate("2025-10"); nil == err {
+			t.Error("expect error activating retired key")
+		} else if err = kr.Deserialize(s0, &p); nil != err {
+			t.Errorf("retired key in grace period does not verify (%v)", err)
+		}
+
+		if k := kr.keyAt("2025-10",
+			time.Now().Add(2*time.Hour)); nil != k {
+			t.Error("retired key verifies after its grace period")
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := kr.Remove("2025-11"); nil == err {
+			t.Error("expect error removing active key")
+		} else if err = kr.Remove("2025-10"); nil != err {
+			t.Fatal(err)
+		} else if err = kr.Deserialize(s0, &p); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expect ErrUnknownKey for removed key, got (%v)", err)
+		} else if err = kr.Remove("2025-10"); nil == err {
+			t.Error("expect error removing missing key")
+		}
+
+		if kid := kr.Active(); kid != "2025-11" {
+			t.Errorf("expect active key id %q, got %q", "2025-11", kid)
+		}
+	})
+}
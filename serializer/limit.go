@@ -0,0 +1,504 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack"
+	"github.com/vmihailenco/msgpack/codes"
+)
+
+// Default limits of deserialization, used where the corresponding field of
+// Options is zero.
+const (
+	DefaultMaxLength = 16 << 10 // see Options.MaxLength
+	DefaultMaxDepth  = 32       // see Options.MaxDepth
+	DefaultMaxItems  = 1024     // see Options.MaxItems
+)
+
+// cborModes caches the cbor.DecModes of limitCodecs, keyed by their limits.
+var cborModes sync.Map
+
+// msgpackFieldCache caches the results of msgpackFieldTypes, keyed by the
+// struct type.
+var msgpackFieldCache sync.Map
+
+// types of the interfaces that let values decode themselves from msgpack
+var (
+	msgpackDecoderType = reflect.TypeOf(
+		(*msgpack.CustomDecoder)(nil)).Elem()
+	msgpackUnmarshalerType = reflect.TypeOf(
+		(*msgpack.Unmarshaler)(nil)).Elem()
+)
+
+// limits are the limits of Options that apply to the binary Payload.
+type limits struct {
+	depth, items int
+	strict       bool
+}
+
+// limitCodec wraps the Msgpack, JSON and CBOR Codecs, so that Payloads are
+// checked against limits before they are decoded (see Options.decoder).
+type limitCodec struct {
+	Codec
+	limits
+}
+
+// checkLength returns ErrTooLarge, as a *DecodeError at StageHeader, if a
+// StringToken of n bytes exceeds the length limit of o.
+func (o *Options) checkLength(n int) error {
+	if n > o.maxLength() {
+		return decodeError(StageHeader, ErrTooLarge)
+	}
+
+	return nil
+}
+
+// maxLength returns the length limit of StringTokens.
+func (o *Options) maxLength() int {
+	if o.MaxLength <= 0 {
+		return DefaultMaxLength
+	}
+
+	return o.MaxLength
+}
+
+// limits returns the limits that apply to binary Payloads.
+func (o *Options) limits() limits {
+	var l = limits{o.MaxDepth, o.MaxItems, o.Strict}
+
+	if l.depth <= 0 {
+		l.depth = DefaultMaxDepth
+	}
+
+	if l.items <= 0 {
+		l.items = DefaultMaxItems
+	}
+
+	return l
+}
+
+// Decode makes limitCodec implement the Codec interface. ErrTooDeep is
+// returned if the Payload nests arrays and maps deeper than c.depth,
+// ErrTooLarge if any of them holds more than c.items elements, and, in strict
+// mode, ErrTrailingData if any bytes follow the Payload.
+func (c limitCodec) Decode(r io.Reader, v interface{}) (err error) {
+	var b []byte
+
+	// Payloads are usually decoded from a bytes.Buffer, whose contents can
+	// be scanned without copying them
+	if buf, ok := r.(*bytes.Buffer); ok {
+		b = buf.Bytes()
+	} else if b, err = ioutil.ReadAll(r); nil != err {
+		return
+	}
+
+	switch c.Codec.(type) {
+	case jsonCodec:
+		return c.decodeJSON(b, v)
+	case cborCodec:
+		return c.decodeCBOR(b, v)
+	}
+
+	return c.decodeMsgpack(b, v)
+}
+
+// decodeMsgpack decodes the msgpack Payload b to v. In strict mode, the maps
+// decoded to structs must not have keys other than the names of their fields.
+func (c limitCodec) decodeMsgpack(b []byte, v interface{}) (err error) {
+	var n int
+
+	if n, err = scanMsgpack(b, c.depth, c.items); nil != err {
+		return
+	}
+
+	if c.strict {
+		if n < len(b) {
+			return ErrTrailingData
+		}
+
+		if err = msgpackFields(msgpack.NewDecoder(bytes.NewReader(b)),
+			reflect.TypeOf(v)); nil != err {
+			return
+		}
+	}
+
+	return msgpack.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// decodeJSON decodes the JSON Payload b to v. In strict mode, the objects
+// decoded to structs must not have keys other than the names of their fields
+// (see json.Decoder.DisallowUnknownFields).
+func (c limitCodec) decodeJSON(b []byte, v interface{}) (err error) {
+	if err = scanJSON(b, c.depth, c.items); nil != err {
+		return
+	}
+
+	var d = json.NewDecoder(bytes.NewReader(b))
+
+	if c.strict {
+		d.DisallowUnknownFields()
+	}
+
+	if err = d.Decode(v); nil == err && c.strict {
+		if _, err = d.Token(); io.EOF == err {
+			err = nil
+		} else {
+			err = ErrTrailingData
+		}
+	}
+
+	return
+}
+
+// decodeCBOR decodes the CBOR Payload b to v, with the limits enforced by
+// fxamacker/cbor. In strict mode, the maps decoded to structs must not have
+// keys other than those of their fields.
+func (c limitCodec) decodeCBOR(b []byte, v interface{}) (err error) {
+	var dm cbor.DecMode
+
+	if dm, err = c.cborMode(); nil != err {
+		return
+	}
+
+	if c.strict {
+		err = dm.Unmarshal(b, v)
+	} else {
+		err = dm.NewDecoder(bytes.NewReader(b)).Decode(v)
+	}
+
+	var nl *cbor.MaxNestedLevelError
+	var ae *cbor.MaxArrayElementsError
+	var mp *cbor.MaxMapPairsError
+	var ed *cbor.ExtraneousDataError
+
+	switch {
+	case errors.As(err, &nl):
+		return ErrTooDeep
+	case errors.As(err, &ae), errors.As(err, &mp):
+		return ErrTooLarge
+	case errors.As(err, &ed):
+		return ErrTrailingData
+	}
+
+	return
+}
+
+// cborMode returns the cbor.DecMode that enforces the limits of c. The
+// limits are raised to the lowest that fxamacker/cbor accepts.
+func (c limitCodec) cborMode() (cbor.DecMode, error) {
+	if dm, ok := cborModes.Load(c.limits); ok {
+		return dm.(cbor.DecMode), nil
+	}
+
+	var opts = cbor.DecOptions{
+		MaxNestedLevels:  c.depth,
+		MaxArrayElements: c.items,
+		MaxMapPairs:      c.items,
+	}
+
+	if opts.MaxNestedLevels < 4 {
+		opts.MaxNestedLevels = 4
+	} else if opts.MaxNestedLevels > 65535 {
+		opts.MaxNestedLevels = 65535
+	}
+
+	if opts.MaxArrayElements < 16 {
+		opts.MaxArrayElements, opts.MaxMapPairs = 16, 16
+	}
+
+	if c.strict {
+		opts.ExtraReturnErrors = cbor.ExtraDecErrorUnknownField
+	}
+
+	var dm, err = opts.DecMode()
+	if nil != err {
+		return nil, err
+	}
+
+	cborModes.Store(c.limits, dm)
+	return dm, nil
+}
+
+// unmarshal decodes the claims b of a token of a format other than
+// StringToken (see NewJWT), encoded with the JSON or CBOR Codec c, to v within
+// the limits l. Unlike a Payload, b is rejected if followed by trailing data
+// even if l is not strict, as by json.Unmarshal and cbor.Unmarshal.
+func (l limits) unmarshal(c Codec, b []byte, v interface{}) (err error) {
+	if !l.strict {
+		if _, ok := c.(cborCodec); ok {
+			err = cbor.Wellformed(b)
+		} else if !json.Valid(b) {
+			err = json.Unmarshal(b, &json.RawMessage{})
+		}
+
+		if nil != err {
+			return
+		}
+	}
+
+	return limitCodec{c, l}.Decode(bytes.NewBuffer(b), v)
+}
+
+// msgpackScanner walks the msgpack encoding of a Payload, without decoding
+// it, to check the nesting depth of its arrays and maps and the number of
+// their elements.
+type msgpackScanner struct {
+	b     []byte
+	off   int
+	items int
+}
+
+// scanMsgpack returns the length of the msgpack object at the start of b. An
+// error is returned if it is truncated or malformed, if it nests arrays and
+// maps deeper than depth (ErrTooDeep), or if any of them holds more than
+// items elements (ErrTooLarge).
+func scanMsgpack(b []byte, depth, items int) (int, error) {
+	var s = msgpackScanner{b: b, items: items}
+	var err = s.scan(depth)
+
+	return s.off, err
+}
+
+// scan skips the msgpack object at s.off, which may nest up to depth arrays
+// and maps.
+func (s *msgpackScanner) scan(depth int) (err error) {
+	var size uint64 // the bytes following the type of a scalar object
+	var n, m uint64 // the elements of an array or map, and their objects
+
+	if s.off >= len(s.b) {
+		return io.ErrUnexpectedEOF
+	}
+
+	var c = s.b[s.off]
+	s.off++
+
+	switch {
+	case c <= 0x7f, c >= 0xe0, 0xc0 == c, 0xc2 == c, 0xc3 == c:
+		// fixint, nil and bool have no bytes following the type
+	case c <= 0x8f: // fixmap
+		n, m = uint64(c&0x0f), 2
+	case c <= 0x9f: // fixarray
+		n, m = uint64(c&0x0f), 1
+	case c <= 0xbf: // fixstr
+		size = uint64(c & 0x1f)
+	case 0xc1 == c:
+		return fmt.Errorf("msgpack: invalid code %#x", c)
+	case c <= 0xc6: // bin 8, 16 and 32
+		size, err = s.uint(1 << (c - 0xc4))
+	case c <= 0xc9: // ext 8, 16 and 32, whose data follows the ext type
+		size, err = s.uint(1 << (c - 0xc7))
+		size++
+	case c <= 0xcb: // float 32 and 64
+		size = 4 << (c - 0xca)
+	case c <= 0xcf: // uint 8 to 64
+		size = 1 << (c - 0xcc)
+	case c <= 0xd3: // int 8 to 64
+		size = 1 << (c - 0xd0)
+	case c <= 0xd8: // fixext 1 to 16, whose data follows the ext type
+		size = 1 + 1<<(c-0xd4)
+	case c <= 0xdb: // str 8, 16 and 32
+		size, err = s.uint(1 << (c - 0xd9))
+	case c <= 0xdd: // array 16 and 32
+		n, err = s.uint(2 << (c - 0xdc))
+		m = 1
+	default: // map 16 and 32
+		n, err = s.uint(2 << (c - 0xde))
+		m = 2
+	}
+
+	switch {
+	case nil != err:
+		return
+	case size > uint64(len(s.b)-s.off):
+		return io.ErrUnexpectedEOF
+	case 0 == m:
+		s.off += int(size)
+		return
+	case depth <= 0:
+		return ErrTooDeep
+	case n > uint64(s.items):
+		return ErrTooLarge
+	}
+
+	for i := n * m; i > 0; i-- {
+		if err = s.scan(depth - 1); nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// uint reads the big-endian unsigned integer of k bytes at s.off.
+func (s *msgpackScanner) uint(k int) (v uint64, err error) {
+	if k > len(s.b)-s.off {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	for _, c := range s.b[s.off : s.off+k] {
+		v = v<<8 | uint64(c)
+	}
+
+	s.off += k
+	return
+}
+
+// msgpackFields checks that the msgpack object read by d, which is to be
+// decoded to a value of type t, has no maps with keys other than the names of
+// the fields of the structs that they are decoded to. Values that decode
+// themselves (see msgpack.CustomDecoder) are skipped.
+func msgpackFields(d *msgpack.Decoder, t reflect.Type) (err error) {
+	var c codes.Code
+	var n int
+
+	if c, err = d.PeekCode(); nil != err {
+		return
+	}
+
+	for nil != t && reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+
+	var isMap = codes.IsFixedMap(c) || codes.Map16 == c || codes.Map32 == c
+	var isArray = codes.IsFixedArray(c) || codes.Array16 == c ||
+		codes.Array32 == c
+
+	switch {
+	case nil == t || reflect.PtrTo(t).Implements(msgpackDecoderType) ||
+		reflect.PtrTo(t).Implements(msgpackUnmarshalerType):
+
+	case isMap && reflect.Struct == t.Kind():
+		var fs = msgpackFieldTypes(t)
+		var name string
+
+		n, err = d.DecodeMapLen()
+		for i := 0; i < n && nil == err; i++ {
+			if name, err = d.DecodeString(); nil != err {
+				return
+			} else if _, ok := fs[name]; !ok {
+				return fmt.Errorf("msgpack: unknown field %q in %v", name, t)
+			}
+
+			err = msgpackFields(d, fs[name])
+		}
+
+		return
+
+	case isMap && reflect.Map == t.Kind():
+		n, err = d.DecodeMapLen()
+		for i := 0; i < n && nil == err; i++ {
+			if err = d.Skip(); nil == err {
+				err = msgpackFields(d, t.Elem())
+			}
+		}
+
+		return
+
+	case isArray && (reflect.Slice == t.Kind() || reflect.Array == t.Kind()):
+		n, err = d.DecodeArrayLen()
+		for i := 0; i < n && nil == err; i++ {
+			err = msgpackFields(d, t.Elem())
+		}
+
+		return
+	}
+
+	return d.Skip()
+}
+
+// msgpackFieldTypes returns the types of the fields of the struct type t, by
+// the names that msgpack encodes them with. The fields of embedded structs are
+// included, as they may be inlined.
+func msgpackFieldTypes(t reflect.Type) map[string]reflect.Type {
+	if fs, ok := msgpackFieldCache.Load(t); ok {
+		return fs.(map[string]reflect.Type)
+	}
+
+	var fs = make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		var name = f.Tag.Get("msgpack")
+
+		if i := strings.IndexByte(name, ','); i >= 0 {
+			name = name[:i]
+		}
+
+		if "-" == name || (0 != len(f.PkgPath) && !f.Anonymous) {
+			continue
+		} else if 0 == len(name) {
+			name = f.Name
+		}
+
+		fs[name] = f.Type
+
+		var et = f.Type
+		if reflect.Ptr == et.Kind() {
+			et = et.Elem()
+		}
+
+		if !f.Anonymous || reflect.Struct != et.Kind() || et == t {
+			continue
+		}
+
+		for n, ft := range msgpackFieldTypes(et) {
+			if _, ok := fs[n]; !ok {
+				fs[n] = ft
+			}
+		}
+	}
+
+	msgpackFieldCache.Store(t, fs)
+	return fs
+}
+
+// scanJSON checks the JSON value at the start of b, like scanMsgpack, but
+// does not return its length.
+func scanJSON(b []byte, depth, items int) error {
+	var d = json.NewDecoder(bytes.NewReader(b))
+	var n []int // the tokens left in each enclosing array and object
+
+	for {
+		var t, err = d.Token()
+		if nil != err {
+			return err
+		}
+
+		switch t {
+		case json.Delim(']'), json.Delim('}'):
+			n = n[:len(n)-1]
+
+		default:
+			// the keys and values of objects are separate tokens
+			if l := len(n) - 1; l >= 0 {
+				if n[l]--; n[l] < 0 {
+					return ErrTooLarge
+				}
+			}
+
+			switch t {
+			case json.Delim('['):
+				n = append(n, items)
+			case json.Delim('{'):
+				n = append(n, 2*items)
+			}
+
+			if len(n) > depth {
+				return ErrTooDeep
+			}
+		}
+
+		if 0 == len(n) {
+			return nil
+		}
+	}
+}
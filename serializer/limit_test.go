@@ -0,0 +1,295 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// tNestedT is a test payload that nests tPayloadT.
+type tNestedT struct {
+	P  tPayloadT            `msgpack:"p"`
+	Ps []tPayloadT          `msgpack:"ps"`
+	M  map[string]tPayloadT `msgpack:"m"`
+}
+
+func TestLimits(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var sealer, _ = NewSealer(SealAESGCM, tRandBuf[:32])
+	var noneSer, _ = New(SignNone, nil, 0)
+	var kr = NewKeyRing()
+
+	noneSer, _ = WithOptions(noneSer, Options{Accept: []Codec{JSON, CBOR}})
+	kr.Add("k0", hmacSer)
+	kr.Activate("k0")
+
+	// unsigned returns an unsigned StringToken with the binary Payload b
+	var unsigned = func(codec string, b []byte) string {
+		var hdr = "auth."
+
+		if 0 != len(codec) {
+			hdr = "auth;codec=" + codec + "."
+		}
+
+		return hdr + tokenEncoding.EncodeToString(b)
+	}
+
+	// nest returns n times open, followed by inner, and n times close
+	var nest = func(n int, open, inner, close string) []byte {
+		return []byte(strings.Repeat(open, n) + inner +
+			strings.Repeat(close, n))
+	}
+
+	t.Run("Length", func(t *testing.T) {
+		var short, _ = WithOptions(hmacSer, Options{MaxLength: 64})
+		var huge = "auth." + strings.Repeat("A", 1<<20)
+		var s, _ = hmacSer.Serialize(tPayload)
+		var ks, _ = kr.Serialize(tPayload)
+		var ss, _ = sealer.Serialize(tPayload)
+		var p tPayloadT
+
+		for _, c := range []struct {
+			ser Serializer
+			s   string
+		}{
+			{hmacSer, huge}, {noneSer, huge}, {sealer, huge}, {short, s},
+			{kr, ks + strings.Repeat("A", 1<<20)},
+			{sealer, ss + strings.Repeat("A", 1<<20)},
+		} {
+			var err = c.ser.Deserialize(c.s, &p)
+			var de *DecodeError
+
+			if !errors.Is(err, ErrTooLarge) {
+				t.Errorf("%.16s: expect ErrTooLarge, got %v", c.s, err)
+			} else if errors.As(err, &de); StageHeader != de.Stage {
+				t.Errorf("%.16s: unexpected stage %v", c.s, de.Stage)
+			}
+		}
+
+		if err := short.(BytesSerializer).DeserializeBytes([]byte(s),
+			&p); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expect ErrTooLarge, got %v", err)
+		}
+
+		if err := hmacSer.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Payload", func(t *testing.T) {
+		var tight, _ = WithOptions(noneSer, Options{MaxDepth: 4,
+			MaxItems: 32, Accept: []Codec{JSON, CBOR}})
+
+		for _, c := range []struct {
+			ser Serializer
+			s   string
+			err error
+		}{
+			// nested msgpack arrays, within and beyond the default depth
+			{noneSer, unsigned("", nest(32, "\x91", "\xc0", "")), nil},
+			{noneSer, unsigned("", nest(33, "\x91", "\xc0", "")), ErrTooDeep},
+			{noneSer, unsigned("", nest(1<<10, "\x81\xa1k", "\xc0", "")),
+				ErrTooDeep},
+			{tight, unsigned("", nest(5, "\x91", "\xc0", "")), ErrTooDeep},
+
+			// msgpack collections declaring more elements than allowed
+			{noneSer, unsigned("", []byte("\xdd\xff\xff\xff\xff")),
+				ErrTooLarge},
+			{noneSer, unsigned("", []byte("\xdf\xff\xff\xff\xff")),
+				ErrTooLarge},
+			{tight, unsigned("", append([]byte{0xdc, 0, 33},
+				bytes.Repeat([]byte{0xc0}, 33)...)), ErrTooLarge},
+			{tight, unsigned("", append([]byte{0xdc, 0, 32},
+				bytes.Repeat([]byte{0xc0}, 32)...)), nil},
+
+			// msgpack objects declaring more bytes than there are
+			{noneSer, unsigned("", []byte("\xdc\x00\x10\xc0")), ErrBadFormat},
+			{noneSer, unsigned("", []byte("\xdb\xff\xff\xff\xff")),
+				ErrBadFormat},
+			{noneSer, unsigned("", []byte("\xc9\x00\x00\x00\x08\x01")),
+				ErrBadFormat},
+			{noneSer, unsigned("", []byte("\xc1")), ErrBadFormat},
+			{noneSer, unsigned("", nil), ErrBadFormat},
+
+			// nested JSON arrays and objects
+			{noneSer, unsigned("json", nest(33, "[", "", "]")), ErrTooDeep},
+			{noneSer, unsigned("json", nest(1<<10, `{"k":`, "0", "}")),
+				ErrTooDeep},
+			{tight, unsigned("json", nest(4, "[", "", "]")), nil},
+			{tight, unsigned("json", []byte("["+strings.Repeat("0,", 32)+
+				"0]")), ErrTooLarge},
+			{tight, unsigned("json", []byte(`{`+strings.Repeat(`"k":0,`,
+				32)+`"k":0}`)), ErrTooLarge},
+			{tight, unsigned("json", []byte(`{`+strings.Repeat(`"k":0,`,
+				31)+`"k":0}`)), nil},
+			{noneSer, unsigned("json", []byte("[[[")), ErrBadFormat},
+
+			// nested CBOR arrays and an array declaring 2^32-1 elements
+			{noneSer, unsigned("cbor", nest(33, "\x81", "\xf6", "")),
+				ErrTooDeep},
+			{noneSer, unsigned("cbor", []byte("\x9a\xff\xff\xff\xff")),
+				ErrTooLarge},
+			{tight, unsigned("cbor", nest(4, "\x81", "\xf6", "")), nil},
+		} {
+			var v interface{}
+
+			if err := c.ser.Deserialize(c.s, &v); nil == c.err && nil != err {
+				t.Errorf("%.32s: %v", c.s, err)
+			} else if !errors.Is(err, c.err) {
+				t.Errorf("%.32s: expect %v, got %v", c.s, c.err, err)
+			}
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		var strict, _ = WithOptions(noneSer, Options{Strict: true,
+			Accept: []Codec{JSON, CBOR}})
+		var b, _ = msgpack.Marshal(tPayload)
+		var unknown, _ = msgpack.Marshal(map[string]interface{}{
+			"uri": "foo", "ts9": 1})
+		var nested, _ = msgpack.Marshal(map[string]interface{}{
+			"p": map[string]interface{}{"uri": "foo"},
+			"ps": []interface{}{map[string]interface{}{"urs": []string{"a"}},
+				map[string]interface{}{"urs": []string{"b"}, "ts9": 1}},
+		})
+		var nestedMap, _ = msgpack.Marshal(map[string]interface{}{
+			"m": map[string]interface{}{"k": map[string]interface{}{
+				"ts9": 1}},
+		})
+		var known, _ = msgpack.Marshal(tNestedT{P: tPayload,
+			Ps: []tPayloadT{tPayload}, M: map[string]tPayloadT{"k": {}}})
+
+		for _, c := range []struct {
+			s   string
+			p   interface{}
+			err error // the error in strict mode
+		}{
+			{unsigned("", b), &tPayloadT{}, nil},
+			{unsigned("", append(b, 0xc0)), &tPayloadT{}, ErrTrailingData},
+			{unsigned("", unknown), &tPayloadT{}, ErrBadFormat},
+			{unsigned("", unknown), &map[string]interface{}{}, nil},
+			{unsigned("", known), &tNestedT{}, nil},
+			{unsigned("", nested), &tNestedT{}, ErrBadFormat},
+			{unsigned("", nestedMap), &tNestedT{}, ErrBadFormat},
+			{unsigned("json", []byte(`{"URI":"foo"}`)), &tPayloadT{}, nil},
+			{unsigned("json", []byte(`{"URI":"foo"} 0`)), &tPayloadT{},
+				ErrTrailingData},
+			{unsigned("json", []byte(`{"URI":"foo","X":0}`)), &tPayloadT{},
+				ErrBadFormat},
+			{unsigned("cbor", []byte("\xa1\x63URI\x63foo\xf6")), &tPayloadT{},
+				ErrTrailingData},
+			{unsigned("cbor", []byte("\xa1\x61X\x00")), &tPayloadT{},
+				ErrBadFormat},
+		} {
+			// all of these deserialize unless in strict mode
+			if err := noneSer.Deserialize(c.s, c.p); nil != err {
+				t.Errorf("%.32s: %v", c.s, err)
+			}
+
+			if err := strict.Deserialize(c.s, c.p); nil == c.err && nil != err {
+				t.Errorf("%.32s: %v", c.s, err)
+			} else if !errors.Is(err, c.err) {
+				t.Errorf("%.32s: expect %v, got %v", c.s, c.err, err)
+			}
+		}
+	})
+
+	t.Run("Formats", func(t *testing.T) {
+		var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+		var local, _ = NewPASETOLocal(tRandBuf[:32], nil, nil)
+		var long = map[string]interface{}{"URI": strings.Repeat("x", 1024)}
+		var many = map[string]interface{}{}
+		var unknown = map[string]interface{}{"URI": "foo", "X": 0}
+		var p tPayloadT
+
+		// CBOR allows at least 16 elements
+		for i := 0; i < 20; i++ {
+			many[strings.Repeat("k", i+1)] = i
+		}
+
+		// the tokens are generated with the Options of ser, and parsed with
+		// those of tight, which limit their length, size and fields; the
+		// claims of an SD-JWT include "_sd" and "_sd_alg"
+		var formats = map[string]func(ser Serializer) Serializer{
+			"JWT": func(ser Serializer) Serializer {
+				var jwt, _ = NewJWT(ser)
+				return jwt
+			},
+			"SD-JWT": func(ser Serializer) Serializer {
+				var sd, _ = NewSDJWT(ser, []string{"URI"})
+				return sd
+			},
+			"CWT": func(ser Serializer) Serializer {
+				var cwt, _ = NewCWT(ser)
+				return cwt
+			},
+			"PASETO": func(ser Serializer) Serializer {
+				var pst, _ = NewPASETOPublic(ser, nil, nil)
+				return pst
+			},
+		}
+
+		for name, f := range formats {
+			var ser = hmacSer
+			if "PASETO" == name {
+				ser = edSer
+			}
+
+			var tight, _ = WithOptions(ser, Options{MaxLength: 512,
+				MaxItems: 7, Strict: true})
+
+			for _, c := range []struct {
+				token interface{}
+				err   error
+			}{
+				{tPayloadT{URI: "foo"}, nil}, {long, ErrTooLarge},
+				{many, ErrTooLarge}, {unknown, ErrBadFormat},
+			} {
+				var s, _ = f(ser).Serialize(c.token)
+
+				if err := f(ser).Deserialize(s, &p); nil != err {
+					t.Errorf("%s: %v", name, err)
+				} else if err = f(tight).Deserialize(s,
+					&p); !errors.Is(err, c.err) {
+					t.Errorf("%s: expect %v, got %v", name, c.err, err)
+				}
+			}
+		}
+
+		// a token longer than the limit of any key is rejected before it
+		// is decoded, so that it does not fail as malformed
+		for name, f := range formats {
+			var huge = strings.Repeat("!", 1<<20)
+			var ser = f(kr)
+			var de *DecodeError
+
+			if "PASETO" == name {
+				ser = f(edSer)
+			}
+
+			if err := ser.Deserialize(huge, &p); !errors.As(err, &de) ||
+				!errors.Is(err, ErrTooLarge) || StageHeader != de.Stage {
+				t.Errorf("%s: expect ErrTooLarge, got %v", name, err)
+			}
+
+			if bs, ok := ser.(BinarySerializer); ok {
+				if err := bs.DeserializeBinary([]byte(huge),
+					&p); !errors.Is(err, ErrTooLarge) {
+					t.Errorf("%s: expect ErrTooLarge, got %v", name, err)
+				}
+			}
+		}
+
+		// v4.local tokens are limited by the defaults
+		var s, _ = local.Serialize(map[string]interface{}{
+			"URI": strings.Repeat("x", DefaultMaxLength)})
+
+		if err := local.Deserialize(s, &p); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expect ErrTooLarge, got %v", err)
+		}
+	})
+}
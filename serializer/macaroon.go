@@ -0,0 +1,405 @@
+package serializer
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// paramCaveats is the name of the Header parameter that marks the
+// StringTokens of a MacaroonSerializer, and carries the number of their
+// caveats.
+const paramCaveats = "cav"
+
+// macaroonLabel is the label that the root key of a MacaroonSerializer is
+// derived for (see macaroonKey).
+const macaroonLabel = "macaroon"
+
+// CaveatExpires is the name of the caveat that limits the lifetime of a
+// StringToken (see ExpiresCaveat). Its checker is registered with every
+// MacaroonSerializer.
+const CaveatExpires = "exp"
+
+// Caveat is a first-party caveat, a condition that must hold for a StringToken
+// of a MacaroonSerializer to be accepted, such as "op=read" or "path=/reports"
+// (see Attenuate). Name selects the CaveatChecker that checks Value, and may
+// only contain the characters allowed in a key ID (see KeyRing.Add).
+type Caveat struct {
+	Name, Value string
+}
+
+// CaveatChecker checks the Value of a Caveat during deserialization, and
+// returns an error if the condition does not hold. ctx is the context passed
+// to DeserializeContext, and may carry the request that the StringToken is
+// presented with.
+type CaveatChecker func(ctx context.Context, value string) error
+
+// CaveatError is the Cause of a *DecodeError at StageCaveat. It records the
+// Caveat that was not satisfied and the error returned by its CaveatChecker,
+// which is nil if no CaveatChecker was registered for it.
+type CaveatError struct {
+	Caveat Caveat
+	Err    error
+}
+
+// MacaroonSerializer is a Serializer for attenuable StringTokens, modeled on
+// Macaroons: the holder of a StringToken can append Caveats that narrow it
+// down, without a key and without going back to the issuer (see Attenuate),
+// but can never remove them. The Signature is an HMAC-SHA-256 chain: the
+// Payload is signed with the key, and each Caveat with the Signature before
+// it, so that the Signature of the last Caveat is the one in the StringToken.
+//
+// The StringTokens of a MacaroonSerializer have the caveats in Base64 encoded
+// parts between the Payload and the Signature, and their number in the Header
+// ("auth;cav=2"). During deserialization, the chain is recomputed from the key
+// and each Caveat is passed to the CaveatChecker registered for its name;
+// StringTokens with a Caveat that is unknown or not satisfied fail with
+// ErrCaveat. The Payload is unpacked only once all Caveats are satisfied.
+type MacaroonSerializer struct {
+	key      []byte
+	opts     Options
+	checkers *caveatCheckers
+}
+
+// caveatCheckers are the CaveatCheckers of a MacaroonSerializer, shared by
+// its copies (see WithOptions).
+type caveatCheckers struct {
+	mu sync.RWMutex
+	m  map[string]CaveatChecker
+}
+
+// NewMacaroon returns a MacaroonSerializer that signs StringTokens with a key
+// derived from key (see macaroonKey), which is subject to the same minimum
+// length as the keys of SignHMAC (256 bytes). Thus its StringTokens are never
+// valid for a Serializer of SignHMAC with the same key, nor are those of the
+// latter valid for the MacaroonSerializer. Only the CaveatChecker of
+// CaveatExpires is registered; register any other with Check.
+func NewMacaroon(key []byte) (*MacaroonSerializer, error) {
+	if len(key) < hmacKeyMinLen {
+		return nil, errorf(efBadKeyLen,
+			"", fmt.Sprintf("%d bytes", hmacKeyMinLen))
+	}
+
+	var root, err = macaroonKey(key)
+	if nil != err {
+		return nil, err
+	}
+
+	return &MacaroonSerializer{
+		key: root,
+		checkers: &caveatCheckers{m: map[string]CaveatChecker{
+			CaveatExpires: checkExpires,
+		}},
+	}, nil
+}
+
+// ExpiresCaveat returns a Caveat that StringTokens are only accepted before t,
+// as a Unix timestamp.
+func ExpiresCaveat(t time.Time) Caveat {
+	return Caveat{CaveatExpires, strconv.FormatInt(t.Unix(), 10)}
+}
+
+// Attenuate returns the StringToken s of a MacaroonSerializer with the Caveats
+// cs appended. It does not need the key of the MacaroonSerializer, nor does it
+// verify s. An error is returned if s is malformed, or if the name of one of
+// cs is not valid.
+func Attenuate(s string, cs ...Caveat) (string, error) {
+	var ps []param
+	var body string
+	var n, i int
+	var sig []byte
+	var err error
+
+	if ps, body, err = parseHeader(s); nil != err {
+		return "", decodeError(StageHeader, err)
+	} else if n, err = macaroonCaveats(ps); nil != err {
+		return "", err
+	}
+
+	if i = strings.LastIndexByte(body, '.'); i < 0 {
+		return "", decodeError(StageSignature, ErrBadFormat)
+	} else if sig, err = tokenEncoding.DecodeString(body[i+1:]); nil != err {
+		return "", formatError(StageSignature, err)
+	} else if sha256.Size != len(sig) {
+		return "", decodeError(StageSignature, ErrBadFormat)
+	}
+
+	var b strings.Builder
+
+	ps[0].value = strconv.Itoa(n + len(cs))
+	b.WriteString(formatHeader(ps...))
+	b.WriteString(body[:i])
+
+	for _, c := range cs {
+		if !validParam(c.Name) {
+			return "", errorf(efBadCaveat, "", c.Name)
+		}
+
+		var cb = c.bytes()
+
+		sig = chainCaveat(sig, cb)
+		b.WriteByte('.')
+		b.WriteString(tokenEncoding.EncodeToString(cb))
+	}
+
+	b.WriteByte('.')
+	b.WriteString(tokenEncoding.EncodeToString(sig))
+
+	return b.String(), nil
+}
+
+// Check registers check as the CaveatChecker of the Caveats named name,
+// replacing the one registered before, if any. An error is returned if name
+// is not valid.
+func (sr *MacaroonSerializer) Check(name string, check CaveatChecker) error {
+	if !validParam(name) {
+		return errorf(efBadCaveat, "", name)
+	}
+
+	sr.checkers.mu.Lock()
+	defer sr.checkers.mu.Unlock()
+
+	sr.checkers.m[name] = check
+	return nil
+}
+
+// Serialize makes MacaroonSerializer implement the Serializer interface. The
+// returned StringToken has no Caveats.
+func (sr *MacaroonSerializer) Serialize(
+	token interface{}) (s string, err error) {
+	return sr.SerializeContext(context.Background(), token)
+}
+
+// Deserialize makes MacaroonSerializer implement the Serializer interface.
+// The CaveatCheckers are passed context.Background().
+func (sr *MacaroonSerializer) Deserialize(
+	s string, token interface{}) (err error) {
+	return sr.DeserializeContext(context.Background(), s, token)
+}
+
+// SerializeContext makes MacaroonSerializer implement the ContextSerializer
+// interface.
+func (sr *MacaroonSerializer) SerializeContext(
+	ctx context.Context, token interface{}) (s string, err error) {
+
+	if err = ctx.Err(); nil != err {
+		return
+	}
+
+	var alg = algName(SignHMAC, crypto.SHA256)
+	var ps = append([]param{{paramCaveats, "0"}}, sr.opts.params(alg)...)
+
+	return headerSerialize(formatHeader(ps...), &sr.opts, token,
+		sr.writeSign)
+}
+
+// DeserializeContext makes MacaroonSerializer implement the
+// ContextSerializer interface. ctx is passed to the CaveatCheckers.
+func (sr *MacaroonSerializer) DeserializeContext(
+	ctx context.Context, s string, token interface{}) (err error) {
+
+	var alg = algName(SignHMAC, crypto.SHA256)
+	var ps []param
+	var body string
+	var n int
+	var c Codec
+
+	if err = ctx.Err(); nil != err {
+		return
+	} else if err = sr.opts.checkLength(len(s)); nil != err {
+		return withAlg(err, alg)
+	} else if ps, body, err = parseHeader(s); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	} else if n, err = macaroonCaveats(ps); nil != err {
+		return withAlg(err, alg)
+	} else if c, err = sr.opts.decoder(alg, ps[1:]); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	return withAlg(sr.bodyDeserialize(ctx, body, n, c, token), alg)
+}
+
+// bodyDeserialize is the same as the function of that name, except that the
+// Payload is followed by n Caveats, which are checked once the Signature
+// chain checks out. Errors are returned as *DecodeErrors without an algorithm.
+func (sr *MacaroonSerializer) bodyDeserialize(ctx context.Context,
+	body string, n int, c Codec, payload interface{}) (err error) {
+
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var parts = strings.Split(body, ".")
+	var cs []Caveat
+	var sig []byte
+	var off, pos int
+
+	// the parts are [Payload].[Caveat]....[Signature]
+	if len(parts) != n+2 || 0 == len(parts[n+1]) {
+		return decodeError(StageSignature, ErrBadFormat)
+	}
+
+	cs = make([]Caveat, n)
+
+	writeSignPrefix(buf, c)
+	off = buf.Len()
+
+	if err = decodeBase64(buf, []byte(parts[0])); nil != err {
+		return formatError(StagePayload, err)
+	}
+
+	// the Payload is signed like that of any other StringToken, without
+	// associated data (see headerAppend)
+	pos = buf.Len()
+	buf.Write(noAD[:])
+	sig = hmacSHA256(sr.key, signedBytes(buf.Bytes()))
+
+	for i, p := range parts[1 : n+1] {
+		var cb []byte
+
+		if cb, err = tokenEncoding.DecodeString(p); nil != err {
+			return formatError(StageCaveat, err)
+		}
+
+		sig, cs[i] = chainCaveat(sig, cb), parseCaveat(cb)
+	}
+
+	if b, err := tokenEncoding.DecodeString(parts[n+1]); nil != err {
+		return formatError(StageSignature, err)
+	} else if !hmac.Equal(sig, b) {
+		return decodeError(StageSignature, ErrBadSign)
+	}
+
+	if err = sr.check(ctx, cs); nil != err {
+		return
+	}
+
+	buf.Truncate(pos)
+	buf.Next(off)
+	return decodeError(StageCodec, c.Decode(buf, payload))
+}
+
+// check passes each of cs to its CaveatChecker, and returns ErrCaveat, as a
+// *DecodeError at StageCaveat with a *CaveatError as its Cause, for the first
+// one that is unknown or not satisfied.
+func (sr *MacaroonSerializer) check(
+	ctx context.Context, cs []Caveat) (err error) {
+
+	for _, c := range cs {
+		sr.checkers.mu.RLock()
+		var check, ok = sr.checkers.m[c.Name]
+		sr.checkers.mu.RUnlock()
+
+		if !ok {
+			err = &CaveatError{Caveat: c}
+		} else if err = check(ctx, c.Value); nil != err {
+			err = &CaveatError{c, err}
+		} else {
+			continue
+		}
+
+		return &DecodeError{Stage: StageCaveat, Err: ErrCaveat, Cause: err}
+	}
+
+	return
+}
+
+// writeSign signs the binary Payload b, the start of the Signature chain.
+func (sr *MacaroonSerializer) writeSign(b []byte, w io.Writer) (err error) {
+	_, err = w.Write(hmacSHA256(sr.key, b))
+	return
+}
+
+// Error implements the builtin error interface.
+func (err *CaveatError) Error() string {
+	if nil == err.Err {
+		return fmt.Sprintf("unknown caveat %q", err.Caveat.Name)
+	}
+
+	return fmt.Sprintf("caveat %q: %v", err.Caveat.Name, err.Err)
+}
+
+// Unwrap returns the error of the CaveatChecker, for errors.Is and errors.As.
+func (err *CaveatError) Unwrap() error {
+	return err.Err
+}
+
+// bytes returns the binary form of c, which the Signature chain covers.
+func (c Caveat) bytes() []byte {
+	return []byte(c.Name + "=" + c.Value)
+}
+
+// parseCaveat returns the Caveat whose binary form is b. A malformed Caveat
+// has no name, and so is never satisfied.
+func parseCaveat(b []byte) Caveat {
+	var s = string(b)
+	var i = strings.IndexByte(s, '=')
+
+	if i < 0 || !validParam(s[:i]) {
+		return Caveat{Value: s}
+	}
+
+	return Caveat{s[:i], s[i+1:]}
+}
+
+// macaroonCaveats returns the number of caveats recorded in the Header
+// parameters ps, which must be those of a MacaroonSerializer.
+func macaroonCaveats(ps []param) (n int, err error) {
+	if 0 == len(ps) || ps[0].name != paramCaveats {
+		return 0, decodeError(StageHeader, ErrBadFormat)
+	} else if n, err = strconv.Atoi(ps[0].value); nil != err || n < 0 {
+		return 0, decodeError(StageHeader, ErrBadFormat)
+	}
+
+	return
+}
+
+// macaroonKey returns the root key of the Signature chain of a
+// MacaroonSerializer, derived from key with HKDF-SHA-256 (RFC 5869) for
+// macaroonLabel, like the keys of NewDerived.
+func macaroonKey(key []byte) ([]byte, error) {
+	var root = make([]byte, sha256.Size)
+	var r = hkdf.New(sha256.New, key, nil, []byte(macaroonLabel))
+
+	if _, err := io.ReadFull(r, root); nil != err {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// chainCaveat returns the Signature of the Caveat whose binary form is b,
+// keyed with the Signature sig before it.
+func chainCaveat(sig, b []byte) []byte {
+	return hmacSHA256(sig, b)
+}
+
+// hmacSHA256 returns the HMAC-SHA-256 of b with key.
+func hmacSHA256(key, b []byte) []byte {
+	var h = hmac.New(sha256.New, key)
+
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+// checkExpires is the CaveatChecker of CaveatExpires.
+func checkExpires(ctx context.Context, value string) error {
+	var exp, err = strconv.ParseInt(value, 10, 64)
+
+	if nil != err {
+		return err
+	} else if t := time.Unix(exp, 0); !time.Now().Before(t) {
+		return fmt.Errorf("expired at %v", t.UTC())
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package serializer
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tOpKey is the context key of the operation checked by the "op" caveat.
+type tOpKey struct{}
+
+func TestMacaroonSerializer(t *testing.T) {
+	var ser, _ = NewMacaroon(tRandBuf[:256])
+	var other, _ = NewMacaroon(tRandBuf[256:])
+	var read = context.WithValue(context.Background(), tOpKey{}, "read")
+	var write = context.WithValue(context.Background(), tOpKey{}, "write")
+	var s0, s1, s2 string
+	var err error
+
+	ser.Check("op", func(ctx context.Context, value string) error {
+		if op, _ := ctx.Value(tOpKey{}).(string); op != value {
+			return Error("operation not allowed")
+		}
+
+		return nil
+	})
+
+	if s0, err = ser.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(s0, "auth;cav=0.") {
+		t.Errorf("unexpected Header %.16s", s0)
+	}
+
+	// caveats are appended without the key, one after the other
+	if s1, err = Attenuate(s0, Caveat{"op", "read"}); nil != err {
+		t.Fatal(err)
+	} else if s2, err = Attenuate(s1,
+		ExpiresCaveat(time.Now().Add(time.Minute))); nil != err {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(s2, "auth;cav=2.") {
+		t.Errorf("unexpected Header %.16s", s2)
+	}
+
+	for _, c := range []struct {
+		ctx context.Context
+		s   string
+	}{{write, s0}, {read, s1}, {read, s2}} {
+		var p tPayloadT
+
+		if err = ser.DeserializeContext(c.ctx, c.s, &p); nil != err {
+			t.Errorf("%.16s: %v", c.s, err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("deserialized payload does not match expectation")
+		}
+	}
+
+	t.Run("Caveat", func(t *testing.T) {
+		var expired, _ = Attenuate(s1,
+			ExpiresCaveat(time.Now().Add(-time.Second)))
+		var unknown, _ = Attenuate(s1, Caveat{"path", "/reports"})
+
+		for _, c := range []struct {
+			ctx   context.Context
+			s     string
+			cav   Caveat
+			known bool
+		}{
+			{write, s1, Caveat{"op", "read"}, true},
+			{write, s2, Caveat{"op", "read"}, true},
+			{read, expired, Caveat{CaveatExpires, ""}, true},
+			{read, unknown, Caveat{"path", "/reports"}, false},
+			{context.Background(), s1, Caveat{"op", "read"}, true},
+		} {
+			var p tPayloadT
+			var err = ser.DeserializeContext(c.ctx, c.s, &p)
+			var ce *CaveatError
+			var de *DecodeError
+
+			if !errors.Is(err, ErrCaveat) || !errors.As(err, &ce) ||
+				!errors.As(err, &de) {
+				t.Errorf("expect ErrCaveat, got %v", err)
+			} else if StageCaveat != de.Stage || c.cav.Name != ce.Caveat.Name ||
+				(0 != len(c.cav.Value) && c.cav != ce.Caveat) {
+				t.Errorf("unexpected caveat error %v", err)
+			} else if c.known != (nil != ce.Err) {
+				t.Errorf("unexpected checker error %v", ce.Err)
+			} else if !reflect.DeepEqual(tPayloadT{}, p) {
+				t.Error("expect payload not to be unpacked")
+			}
+		}
+	})
+
+	t.Run("Key", func(t *testing.T) {
+		var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+		var s, _ = hmacSer.Serialize(tPayload)
+		var p tPayloadT
+
+		// the root key is derived from the key, so that neither StringToken
+		// can be passed off as the other by rewriting its Header
+		if err := ser.Deserialize(strings.Replace(s, "auth.", "auth;cav=0.",
+			1), &p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign, got %v", err)
+		}
+
+		if err := hmacSer.Deserialize(strings.Replace(s0, "auth;cav=0.",
+			"auth.", 1), &p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign, got %v", err)
+		}
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		var i = strings.LastIndexByte(s2, '.')
+		var j = strings.LastIndexByte(s2[:i], '.')
+		var p tPayloadT
+
+		// caveats cannot be removed, nor changed, nor can the count in the
+		// Header be changed, and the chain starts with the key
+		for _, c := range []struct {
+			ser *MacaroonSerializer
+			s   string
+			err error
+		}{
+			{ser, "auth;cav=1" + s2[10:j] + s2[i:], ErrBadSign},
+			{ser, strings.Replace(s2, s2[j+1:i], tokenEncoding.EncodeToString(
+				ExpiresCaveat(time.Now().Add(time.Hour)).bytes()), 1),
+				ErrBadSign},
+			{ser, "auth;cav=1" + s2[10:], ErrBadFormat},
+			{ser, "auth;cav=-1" + s2[10:], ErrBadFormat},
+			{ser, s2[:i+1], ErrBadFormat},
+			{ser, "auth" + s0[10:], ErrBadFormat},
+			{other, s0, ErrBadSign},
+			{other, s2, ErrBadSign},
+		} {
+			if err := c.ser.DeserializeContext(read, c.s,
+				&p); !errors.Is(err, c.err) {
+				t.Errorf("%.16s: expect %v, got %v", c.s, c.err, err)
+			}
+		}
+	})
+
+	t.Run("Attenuate", func(t *testing.T) {
+		var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+		var s, _ = hmacSer.Serialize(tPayload)
+
+		if _, err := Attenuate(s, Caveat{"op", "read"}); !errors.Is(err,
+			ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat, got %v", err)
+		}
+
+		for _, name := range []string{"", "a=b", "a.b"} {
+			if _, err := Attenuate(s0, Caveat{name, "x"}); nil == err {
+				t.Errorf("expect error for caveat name %q", name)
+			} else if err = ser.Check(name, nil); nil == err {
+				t.Errorf("expect error for checker name %q", name)
+			}
+		}
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		var js, _ = WithOptions(ser, Options{Codec: JSON})
+		var s, _ = js.Serialize(tPayload)
+		var p tPayloadT
+
+		if s, err = Attenuate(s, Caveat{"op", "read"}); nil != err {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(s, "auth;cav=1;codec=json.") {
+			t.Errorf("unexpected Header %.24s", s)
+		}
+
+		// the CaveatCheckers are shared by the copy
+		if err = WithContext(js).DeserializeContext(read, s,
+			&p); nil != err {
+			t.Error(err)
+		} else if err = WithContext(js).DeserializeContext(write, s,
+			&p); !errors.Is(err, ErrCaveat) {
+			t.Errorf("expect ErrCaveat, got %v", err)
+		}
+
+		// nor are the StringTokens cached, as their caveats must be checked
+		// every time
+		var c, _ = NewCache(ser, 8, 0)
+		for i := 0; i < 2; i++ {
+			if err = c.Deserialize(s0, &p); nil != err {
+				t.Fatal(err)
+			}
+		}
+
+		if 0 != c.Stats().Len {
+			t.Error("expect StringToken not to be cached")
+		}
+	})
+}
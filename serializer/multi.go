@@ -0,0 +1,108 @@
+package serializer
+
+// paramAlg is the name of the Header parameter that carries the name of the
+// algorithm that computed the Signature of a StringToken (see Options.Alg).
+const paramAlg = "alg"
+
+// multiVerifier is an internal implementation of Serializer returned by the
+// NewMultiVerifier function. It verifies each StringToken with the signer
+// whose algorithm is named in its Header.
+type multiVerifier struct {
+	sgs map[string]signer
+}
+
+// NewMultiVerifier returns a Serializer that can only parse and verify
+// StringTokens, like the one returned by NewVerifier, but does so with any of
+// the Serializers in sers, picking the one whose SignMethod and hash are named
+// in the Header of the StringToken. This allows StringTokens signed with
+// different algorithms to be accepted side by side, for example while
+// migrating from RSA to ECDSA keys. The StringTokens must be generated by
+// Serializers that record their algorithm (see Options.Alg).
+//
+// sers must be Serializers returned by New (other than for SignNone) or
+// NewVerifier, each with a different algorithm; use a KeyRing to verify
+// StringTokens signed by several keys with the same algorithm. allow lists
+// the names of the algorithms that are accepted, for example "rsa+sha-256" or
+// "eddsa" (see Options.Alg). If allow is empty, the algorithms of sers are
+// allowed. An error is returned if allow lists an algorithm that none of sers
+// uses.
+//
+// StringTokens that do not name their algorithm, or name one that is not
+// allowed, fail to deserialize with ErrBadAlg, so that a StringToken cannot
+// downgrade itself to SignNone or to a weaker hash. The Serialize method of
+// the returned Serializer always fails with ErrVerifyOnly.
+func NewMultiVerifier(sers []Serializer, allow ...string) (Serializer, error) {
+	var sr = &multiVerifier{sgs: make(map[string]signer, len(sers))}
+
+	if 0 == len(sers) {
+		return nil, errorf(efBadSerlr, "", nil, sr)
+	}
+
+	for _, ser := range sers {
+		var sg, ok = ser.(signer)
+		var alg string
+
+		if !ok {
+			return nil, errorf(efBadSerlr, "", ser, sr)
+		}
+
+		if alg = algName(sg.algorithm()); nil != sr.sgs[alg] {
+			return nil, errorf(efAlgExists, "", alg)
+		}
+
+		sr.sgs[alg] = sg
+	}
+
+	if 0 == len(allow) {
+		return sr, nil
+	}
+
+	var sgs = make(map[string]signer, len(allow))
+
+	for _, alg := range allow {
+		if sgs[alg] = sr.sgs[alg]; nil == sgs[alg] {
+			return nil, errorf(efAlgAllow, "", alg)
+		}
+	}
+
+	sr.sgs = sgs
+	return sr, nil
+}
+
+// Serialize makes multiVerifier implement the Serializer interface. It always
+// returns ErrVerifyOnly.
+func (sr *multiVerifier) Serialize(token interface{}) (s string, err error) {
+	return "", ErrVerifyOnly
+}
+
+// Deserialize makes multiVerifier implement the Serializer interface.
+func (sr *multiVerifier) Deserialize(s string, token interface{}) (err error) {
+	var ps []param
+	var body string
+	var sg signer
+	var c Codec
+
+	if ps, body, err = parseHeader(s); nil != err {
+		return decodeError(StageHeader, err)
+	}
+
+	// the algorithm must be the first parameter, and is checked again by the
+	// decoder of the signer
+	if 0 == len(ps) || ps[0].name != paramAlg {
+		return decodeError(StageHeader, ErrBadAlg)
+	}
+
+	var alg = ps[0].value
+
+	if sg = sr.sgs[alg]; nil == sg {
+		return withAlg(decodeError(StageHeader, ErrBadAlg), alg)
+	}
+
+	if err = sg.options().checkLength(len(s)); nil != err {
+		return withAlg(err, alg)
+	} else if c, err = sg.options().decoder(alg, ps); nil != err {
+		return withAlg(decodeError(StageHeader, err), alg)
+	}
+
+	return withAlg(bodyDeserialize(body, c, token, sg.compareSign), alg)
+}
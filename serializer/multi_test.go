@@ -0,0 +1,148 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlgName(t *testing.T) {
+	for _, c := range []struct {
+		m   SignMethod
+		h   crypto.Hash
+		exp string
+	}{
+		{SignNone, 0, "none"},
+		{SignHMAC, crypto.SHA512, "hmac+sha-512"},
+		{SignRSA, crypto.SHA256, "rsa+sha-256"},
+		{SignPSS, crypto.SHA512_256, "pss+sha-512/256"},
+		{SignECDSA, crypto.SHA3_384, "ecdsa+sha3-384"},
+		{SignEdDSA, 0, "eddsa"},
+	} {
+		if n := algName(c.m, c.h); n != c.exp {
+			t.Errorf("expect %q, got %q", c.exp, n)
+		} else if !validParam(n) {
+			t.Errorf("%q cannot be used in a Header", n)
+		}
+	}
+
+	if s := maxSignMethod.String(); "SignMethod(6)" != s {
+		t.Errorf("unexpected name %q for undefined SignMethod", s)
+	}
+}
+
+func TestMultiVerifier(t *testing.T) {
+	var opts = Options{Alg: true}
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var rsaSer, _ = New(SignRSA, tRSAKey, crypto.SHA256)
+	var ecSer, _ = New(SignECDSA, tECDSAKey, crypto.SHA256)
+	var ecVer, _ = NewVerifier(SignECDSA, &tECDSAKey.PublicKey, crypto.SHA256)
+	var weakSer, _ = New(SignRSA, tRSAKey, crypto.SHA1)
+	var noneSer, _ = New(SignNone, nil, 0)
+	var ver, err = NewMultiVerifier([]Serializer{hmacSer, rsaSer, ecVer,
+		weakSer}, "rsa+sha-256", "ecdsa+sha-256", "hmac+sha-256")
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// serialize returns a StringToken generated by ser with Options.Alg
+	var serialize = func(t *testing.T, ser Serializer) string {
+		var s string
+		var err error
+
+		if ser, err = WithOptions(ser, opts); nil != err {
+			t.Fatal(err)
+		} else if s, err = ser.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		}
+
+		return s
+	}
+
+	t.Run("Header", func(t *testing.T) {
+		var s = serialize(t, ecSer)
+		var p tPayloadT
+
+		if !strings.HasPrefix(s, "auth;alg=ecdsa+sha-256.") {
+			t.Errorf("unexpected Header in %s", s)
+		}
+
+		// the algorithm is checked, but not required, by single Serializers
+		if err := ecVer.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		} else if err = rsaSer.Deserialize(s, &p); !errors.Is(err, ErrBadAlg) {
+			t.Errorf("expect ErrBadAlg, got %v", err)
+		}
+
+		var jsonSer, _ = WithOptions(hmacSer, Options{Alg: true, Codec: JSON})
+		if s, _ = jsonSer.Serialize(tPayload); !strings.HasPrefix(s,
+			"auth;alg=hmac+sha-256;codec=json.") {
+			t.Errorf("unexpected Header in %s", s)
+		}
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		for _, ser := range []Serializer{hmacSer, rsaSer, ecSer} {
+			var p tPayloadT
+
+			if err := ver.Deserialize(serialize(t, ser), &p); nil != err {
+				t.Errorf("%T: %v", ser, err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+		}
+
+		if _, err := ver.Serialize(tPayload); ErrVerifyOnly != err {
+			t.Errorf("expect ErrVerifyOnly, got %v", err)
+		}
+	})
+
+	t.Run("Downgrade", func(t *testing.T) {
+		var p tPayloadT
+		var s = serialize(t, rsaSer)
+		var body = s[strings.IndexByte(s, '.'):]
+
+		for _, s := range []string{
+			serialize(t, noneSer),
+			serialize(t, weakSer),
+			"auth" + body,
+			"auth;alg=none" + body,
+			"auth;codec=msgpack;alg=rsa+sha-256" + body,
+		} {
+			if err := ver.Deserialize(s, &p); !errors.Is(err, ErrBadAlg) {
+				t.Errorf("expect ErrBadAlg for %s, got %v", s, err)
+			}
+		}
+
+		// a StringToken cannot pick another verifier than the one that
+		// matches its Signature
+		if err := ver.Deserialize("auth;alg=ecdsa+sha-256"+body,
+			&p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign, got %v", err)
+		}
+	})
+
+	t.Run("Bad", func(t *testing.T) {
+		var sealer, _ = NewSealer(SealAESGCM, tRandBuf[:32])
+
+		for _, c := range []struct {
+			sers  []Serializer
+			allow []string
+		}{
+			{nil, nil},
+			{[]Serializer{noneSer}, nil},
+			{[]Serializer{sealer}, nil},
+			{[]Serializer{ecSer, ecVer}, nil},
+			{[]Serializer{ecSer}, []string{"none"}},
+			{[]Serializer{ecSer}, []string{"ecdsa+sha-384"}},
+		} {
+			if ser, err := NewMultiVerifier(c.sers,
+				c.allow...); nil == err || nil != ser {
+				t.Errorf("expect error for %v allowing %q", c.sers, c.allow)
+			}
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// pasetoLocal and pasetoPublic are the headers of PASETO v4 tokens (see
+	// https://github.com/paseto-standard/paseto-spec).
+	pasetoLocal  = "v4.local."
+	pasetoPublic = "v4.public."
+
+	// pasetoKeyLen is the length of a v4.local key, pasetoNonceLen the length
+	// of the random nonce and pasetoTagLen the length of the authentication
+	// tag of a v4.local token.
+	pasetoKeyLen   = 32
+	pasetoNonceLen = 32
+	pasetoTagLen   = 32
+)
+
+// pasetoSerializer is an internal implementation of Serializer returned by
+// the NewPASETOLocal and NewPASETOPublic functions. Like the one returned by
+// NewJWT, it does not produce StringTokens, but PASETO version 4 tokens:
+//
+//     v4.local.B64(Nonce+Ciphertext+Tag)[.B64(Footer)]
+//     v4.public.B64(Payload+Signature)[.B64(Footer)]
+//
+// The Payload is the JSON encoding of the token. Exactly one of key and sg is
+// set, for v4.local and v4.public tokens respectively.
+type pasetoSerializer struct {
+	key []byte
+	sg  signer
+
+	footer, implicit []byte
+}
+
+// NewPASETOLocal returns a Serializer that generates and parses PASETO v4.local
+// tokens, i.e. tokens whose JSON encoded Payload is encrypted with XChaCha20
+// and authenticated with a keyed BLAKE2b MAC, as specified by the PASETO v4
+// protocol. key must be a byte-slice of exactly 32 bytes.
+//
+// footer is written, unencrypted but authenticated, to every token; implicit
+// is an implicit assertion, i.e. authenticated data that is not written to the
+// token at all, but must be known to parse it. Both may be nil, and are
+// copied. Deserialize rejects tokens whose footer does not match footer.
+//
+// The token passed to Serialize and Deserialize is JSON encoded (see
+// encoding/json); token.Token encodes its Timestamps as JSON numbers, like the
+// NumericDate claims of a JWT.
+func NewPASETOLocal(key, footer, implicit []byte) (Serializer, error) {
+	if len(key) != pasetoKeyLen {
+		return nil, errorf(efBadKeySize, "", "32 bytes")
+	}
+
+	return &pasetoSerializer{
+		key:      append([]byte(nil), key...),
+		footer:   append([]byte(nil), footer...),
+		implicit: append([]byte(nil), implicit...),
+	}, nil
+}
+
+// NewPASETOPublic returns a Serializer that generates and parses PASETO
+// v4.public tokens, i.e. tokens whose JSON encoded Payload is signed with
+// Ed25519, as specified by the PASETO v4 protocol.
+//
+// ser must be a Serializer returned by New or NewVerifier for SignEdDSA; in
+// the latter case, the returned Serializer can only parse tokens. footer and
+// implicit are the same as for NewPASETOLocal.
+func NewPASETOPublic(ser Serializer, footer, implicit []byte) (Serializer, error) {
+	var sr = &pasetoSerializer{footer: append([]byte(nil), footer...),
+		implicit: append([]byte(nil), implicit...)}
+	var ok bool
+
+	if sr.sg, ok = ser.(signer); !ok {
+		return nil, errorf(efBadSerlr, "", ser, sr)
+	}
+
+	if m, _ := sr.sg.algorithm(); m != SignEdDSA {
+		return nil, errorf(efBadMethod, "", m, sr)
+	}
+
+	return sr, nil
+}
+
+// Serialize makes pasetoSerializer implement the Serializer interface.
+func (sr *pasetoSerializer) Serialize(token interface{}) (s string, err error) {
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var m []byte
+
+	if m, err = json.Marshal(token); nil != err {
+		return
+	}
+
+	if nil != sr.sg {
+		err = sr.sign(buf, m)
+	} else {
+		err = sr.encrypt(buf, m)
+	}
+
+	if nil != err {
+		return
+	}
+
+	if 0 != len(sr.footer) {
+		buf.WriteByte('.')
+		writeBase64(buf, sr.footer)
+	}
+
+	return buf.String(), nil
+}
+
+// Deserialize makes pasetoSerializer implement the Serializer interface.
+func (sr *pasetoSerializer) Deserialize(s string, token interface{}) (err error) {
+	var h = pasetoLocal
+	var f, b, m []byte
+	var i int
+
+	if nil != sr.sg {
+		h = pasetoPublic
+	}
+
+	// the algorithm of a DecodeError is the version and purpose of the token
+	var alg = h[:len(h)-1]
+	var o = sr.options()
+
+	if err = o.checkLength(len(s)); nil != err {
+		return withAlg(err, alg)
+	} else if !strings.HasPrefix(s, h) {
+		return withAlg(decodeError(StageHeader, ErrBadFormat), alg)
+	}
+
+	if s = s[len(h):]; 0 == len(s) {
+		return withAlg(decodeError(StagePayload, ErrBadFormat), alg)
+	}
+
+	// split off the footer, if any; it must match the expected footer
+	if i = strings.IndexByte(s, '.'); i >= 0 {
+		if f, err = jwtEncoding.DecodeString(s[i+1:]); nil != err {
+			return withAlg(formatError(StageHeader, err), alg)
+		}
+
+		s = s[:i]
+	}
+
+	if (i >= 0 && 0 == len(f)) ||
+		1 != subtle.ConstantTimeCompare(f, sr.footer) {
+		return withAlg(decodeError(StageHeader, ErrBadFormat), alg)
+	}
+
+	if b, err = jwtEncoding.DecodeString(s); nil != err {
+		return withAlg(formatError(StagePayload, err), alg)
+	}
+
+	if nil != sr.sg {
+		m, err = sr.verify(b)
+	} else {
+		m, err = sr.decrypt(b)
+	}
+
+	if nil != err {
+		return withAlg(decodeError(StageSignature, err), alg)
+	}
+
+	return withAlg(decodeError(StageCodec,
+		o.limits().unmarshal(JSON, m, token)), alg)
+}
+
+// options returns the Options whose limits apply to the tokens of sr, i.e.
+// those of the signer of a v4.public Serializer; v4.local tokens are limited
+// by the defaults.
+func (sr *pasetoSerializer) options() *Options {
+	if nil != sr.sg {
+		return sr.sg.options()
+	}
+
+	return &Options{}
+}
+
+// encrypt writes the v4.local token of the message m to buf, excluding the
+// footer (see PASETO v4.Encrypt).
+func (sr *pasetoSerializer) encrypt(buf *bytes.Buffer, m []byte) (err error) {
+	var n [pasetoNonceLen]byte
+	var ek, n2, ak []byte
+	var c = make([]byte, len(m))
+	var xc *chacha20.Cipher
+	var mac hash.Hash
+
+	if _, err = rand.Read(n[:]); nil != err {
+		return
+	}
+
+	if ek, n2, ak, err = sr.splitKey(n[:]); nil != err {
+		return
+	}
+
+	if xc, err = chacha20.NewUnauthenticatedCipher(ek, n2); nil != err {
+		return
+	}
+
+	xc.XORKeyStream(c, m)
+
+	if mac, err = blake2b.New(pasetoTagLen, ak); nil != err {
+		return
+	}
+
+	writePAE(mac, []byte(pasetoLocal), n[:], c, sr.footer, sr.implicit)
+
+	buf.WriteString(pasetoLocal)
+	writeBase64(buf, append(append(n[:], c...), mac.Sum(nil)...))
+	return
+}
+
+// decrypt authenticates and decrypts the decoded body b of a v4.local token
+// and returns the message (see PASETO v4.Decrypt). ErrBadSign is returned if
+// the token cannot be authenticated.
+func (sr *pasetoSerializer) decrypt(b []byte) (m []byte, err error) {
+	var n, c, t []byte
+	var ek, n2, ak []byte
+	var xc *chacha20.Cipher
+	var mac hash.Hash
+
+	if len(b) < pasetoNonceLen+pasetoTagLen {
+		return nil, decodeError(StagePayload, ErrBadFormat)
+	}
+
+	n, c, t = b[:pasetoNonceLen],
+		b[pasetoNonceLen:len(b)-pasetoTagLen], b[len(b)-pasetoTagLen:]
+
+	if ek, n2, ak, err = sr.splitKey(n); nil != err {
+		return
+	}
+
+	if mac, err = blake2b.New(pasetoTagLen, ak); nil != err {
+		return
+	}
+
+	writePAE(mac, []byte(pasetoLocal), n, c, sr.footer, sr.implicit)
+	if !hmac.Equal(t, mac.Sum(nil)) {
+		return nil, ErrBadSign
+	}
+
+	if xc, err = chacha20.NewUnauthenticatedCipher(ek, n2); nil != err {
+		return
+	}
+
+	m = make([]byte, len(c))
+	xc.XORKeyStream(m, c)
+	return
+}
+
+// splitKey derives the encryption key ek, the XChaCha20 nonce n2 and the
+// authentication key ak for the nonce n from the v4.local key of sr.
+func (sr *pasetoSerializer) splitKey(n []byte) (ek, n2, ak []byte, err error) {
+	var h hash.Hash
+
+	if h, err = blake2b.New(56, sr.key); nil != err {
+		return
+	}
+
+	h.Write([]byte("paseto-encryption-key"))
+	h.Write(n)
+	ek = h.Sum(nil)
+	ek, n2 = ek[:32], ek[32:]
+
+	if h, err = blake2b.New(32, sr.key); nil != err {
+		return
+	}
+
+	h.Write([]byte("paseto-auth-key-for-aead"))
+	h.Write(n)
+	ak = h.Sum(nil)
+	return
+}
+
+// sign writes the v4.public token of the message m to buf, excluding the
+// footer (see PASETO v4.Sign).
+func (sr *pasetoSerializer) sign(buf *bytes.Buffer, m []byte) (err error) {
+	var pae bytes.Buffer
+	var sig bytes.Buffer
+
+	writePAE(&pae, []byte(pasetoPublic), m, sr.footer, sr.implicit)
+	if err = sr.sg.writeSign(pae.Bytes(), &sig); nil != err {
+		return
+	}
+
+	buf.WriteString(pasetoPublic)
+	writeBase64(buf, append(m, sig.Bytes()...))
+	return
+}
+
+// verify checks the signature of the decoded body b of a v4.public token and
+// returns the message (see PASETO v4.Verify). ErrBadSign is returned if the
+// signature does not match.
+func (sr *pasetoSerializer) verify(b []byte) (m []byte, err error) {
+	const S = 64
+
+	var pae bytes.Buffer
+
+	if len(b) < S {
+		return nil, decodeError(StagePayload, ErrBadFormat)
+	}
+
+	m = b[:len(b)-S]
+	writePAE(&pae, []byte(pasetoPublic), m, sr.footer, sr.implicit)
+	if err = sr.sg.compareSign(pae.Bytes(), b[len(b)-S:]); nil != err {
+		return nil, err
+	}
+
+	return
+}
+
+// writePAE writes the Pre-Authentication Encoding of pieces to w (see PASETO
+// common.PAE). Writes to hash.Hash and bytes.Buffer never fail.
+func writePAE(w interface{ Write([]byte) (int, error) }, pieces ...[]byte) {
+	var le64 [8]byte
+
+	binary.LittleEndian.PutUint64(le64[:], uint64(len(pieces)))
+	w.Write(le64[:])
+
+	for _, p := range pieces {
+		binary.LittleEndian.PutUint64(le64[:], uint64(len(p)))
+		w.Write(le64[:])
+		w.Write(p)
+	}
+}
@@ -0,0 +1,296 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWritePAE(t *testing.T) {
+	// test vectors from the PASETO specification (common.PAE)
+	for _, c := range []struct {
+		pieces [][]byte
+		exp    string
+	}{
+		{nil, "\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{[][]byte{{}}, "\x01\x00\x00\x00\x00\x00\x00\x00" +
+			"\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{[][]byte{[]byte("test")}, "\x01\x00\x00\x00\x00\x00\x00\x00" +
+			"\x04\x00\x00\x00\x00\x00\x00\x00test"},
+	} {
+		var buf bytes.Buffer
+		if writePAE(&buf, c.pieces...); buf.String() != c.exp {
+			t.Errorf("writePAE does not match expectation"+
+				"\nexp: %q"+
+				"\nret: %q", c.exp, buf.String())
+		}
+	}
+}
+
+func TestNewPASETO(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+
+	for _, n := range []int{0, 16, 31, 33} {
+		if ser, err := NewPASETOLocal(tRandBuf[:n], nil, nil); nil == err ||
+			nil != ser {
+			t.Errorf("expect error for key length %d", n)
+		}
+	}
+
+	if ser, err := NewPASETOPublic(hmacSer, nil, nil); nil == err ||
+		nil != ser {
+		t.Error("expect error for SignHMAC Serializer")
+	}
+}
+
+func TestPASETO(t *testing.T) {
+	var edSer, _ = New(SignEdDSA, tEdDSAKey, 0)
+	var edVer, _ = NewVerifier(SignEdDSA, tEdDSAKey.Public(), 0)
+
+	var base = func(h string, mk func(f, i []byte) (Serializer,
+		error)) func(*testing.T) {
+		return func(t *testing.T) {
+			var ser, other Serializer
+			var p tPayloadT
+			var s string
+			var err error
+
+			var f, i = []byte(`{"kid":"k0"}`), []byte("tenant-0")
+
+			if ser, err = mk(f, i); nil != err {
+				t.Fatal(err)
+			} else if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			}
+
+			if !strings.HasPrefix(s, h) {
+				t.Errorf("expect header %q, got %q", h, s)
+			} else if exp := "." + base64.RawURLEncoding.EncodeToString(
+				f); !strings.HasSuffix(s, exp) {
+				t.Errorf("expect footer %q, got %q", exp, s)
+			}
+
+			if err = ser.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			// the footer and implicit assertion are copied by mk
+			copy(f, "xxxx")
+			copy(i, "xxxx")
+
+			if err = ser.Deserialize(s, &p); nil != err {
+				t.Errorf("footer or assertion not copied (%v)", err)
+			}
+
+			f, i = []byte(`{"kid":"k0"}`), []byte("tenant-0")
+
+			// the footer must match; the implicit assertion is authenticated
+			if other, _ = mk([]byte(`{"kid":"k1"}`), i); nil == other {
+				t.Fatal("cannot construct Serializer")
+			} else if err = other.Deserialize(s,
+				&p); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat for other footer, got (%v)", err)
+			}
+
+			if other, _ = mk(f, []byte("tenant-1")); nil == other {
+				t.Fatal("cannot construct Serializer")
+			} else if err = other.Deserialize(s,
+				&p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for other assertion, got (%v)", err)
+			}
+
+			var body = s[:strings.LastIndexByte(s, '.')]
+			if err = ser.Deserialize(body, &p); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat without footer, got (%v)", err)
+			} else if err = ser.Deserialize(tamperSign(body)+
+				s[len(body):], &p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for tampered token, got (%v)", err)
+			} else if err = ser.Deserialize(header+s[len(h):],
+				&p); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat for wrong header, got (%v)", err)
+			}
+		}
+	}
+
+	t.Run("Local", base(pasetoLocal, func(f, i []byte) (Serializer, error) {
+		return NewPASETOLocal(tRandBuf[:32], f, i)
+	}))
+
+	t.Run("Public", base(pasetoPublic, func(f, i []byte) (Serializer, error) {
+		return NewPASETOPublic(edSer, f, i)
+	}))
+
+	// the Signature of a v4.public token is the Ed25519 signature of the PAE
+	// of its pieces, so it can be checked independently
+	t.Run("Interop", func(t *testing.T) {
+		var ser, _ = NewPASETOPublic(edSer, nil, nil)
+		var s, err = ser.Serialize(tPayload)
+		var b []byte
+		var pae bytes.Buffer
+
+		if nil != err {
+			t.Fatal(err)
+		} else if strings.Count(s, ".") != 2 {
+			t.Errorf("token without footer has a footer: %q", s)
+		}
+
+		if b, err = base64.RawURLEncoding.DecodeString(
+			s[len(pasetoPublic):]); nil != err {
+			t.Fatal(err)
+		}
+
+		var m, sig = b[:len(b)-ed25519.SignatureSize],
+			b[len(b)-ed25519.SignatureSize:]
+
+		writePAE(&pae, []byte(pasetoPublic), m, nil, nil)
+		if !ed25519.Verify(tEdDSAKey.Public().(ed25519.PublicKey),
+			pae.Bytes(), sig) {
+			t.Error("v4.public signature does not match PAE of pieces")
+		}
+	})
+
+	t.Run("VerifyOnly", func(t *testing.T) {
+		var ser, _ = NewPASETOPublic(edSer, nil, nil)
+		var ver, err = NewPASETOPublic(edVer, nil, nil)
+		var p tPayloadT
+		var s string
+
+		if nil != err {
+			t.Fatal(err)
+		} else if _, err = ver.Serialize(tPayload); ErrVerifyOnly != err {
+			t.Errorf("expect ErrVerifyOnly, got (%v)", err)
+		} else if s, err = ser.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		} else if err = ver.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		}
+	})
+	// the official test vectors of the PASETO specification (4-E-3 to 4-E-9,
+	// 4-S-1 to 4-S-3); v4.local tokens use a fixed nonce, so they can only be
+	// parsed, but v4.public tokens are deterministic and generated as well
+	t.Run("Vectors", func(t *testing.T) {
+		var key, _ = hex.DecodeString("707172737475767778797a7b7c7d7e7f" +
+			"808182838485868788898a8b8c8d8e8f")
+		var seed, _ = hex.DecodeString("b4cbfb43df4ce210727d953e4a713307" +
+			"fa19bb7d9f85041438d9e11b942a3774")
+		var edSer, _ = New(SignEdDSA, ed25519.NewKeyFromSeed(seed), 0)
+
+		const kid = `{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`
+		const expires = "2022-01-01T00:00:00+00:00"
+
+		// the claims of the test vectors, in the order they are encoded
+		type claims struct {
+			Data string `json:"data"`
+			Exp  string `json:"exp"`
+		}
+
+		for _, c := range []struct {
+			name, footer, implicit, data, token string
+		}{
+			{"4-E-3", "", "", "this is a secret message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t6-tyebyWG6Ov7kKvBdkrrAJ837lKP" +
+					"3iDag2hzUPHuMKA"},
+			{"4-E-4", "", "", "this is a hidden message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t4gt6TiLm55vIH8c_lGxxZpE3AWlH4" +
+					"WTR0v45nsWoU3gQ"},
+			{"4-E-5", kid, "", "this is a secret message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t4x-RMNXtQNbz7FvFZ_G-lFpk5RG3E" +
+					"OrwDL6CgDqcerSQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZU" +
+					"aW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+			{"4-E-6", kid, "", "this is a hidden message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t6pWSA5HX2wjb3P-xLQg5K5feUCX4P" +
+					"2fpVK3ZLWFbMSxQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZU" +
+					"aW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+			{"4-E-7", kid, `{"test-vector":"4-E-7"}`,
+				"this is a secret message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WkwMsYXw6FSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t40KCCWLA7GYL9KFHzKlwY9_RnIfRr" +
+					"MQpueydLEAZGGcA.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZU" +
+					"aW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+			{"4-E-8", kid, `{"test-vector":"4-E-8"}`,
+				"this is a hidden message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t5uvqQbMGlLLNYBc7A6_x7oqnpUK5W" +
+					"Lvj24eE4DVPDZjw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZU" +
+					"aW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+			{"4-E-9", "arbitrary-string-that-isn't-json",
+				`{"test-vector":"4-E-9"}`, "this is a hidden message",
+				"v4.local.32VIErrEkmY4JVILovbmfPXKW9wT1OdQepjMTC_MOtjA4kiqw7_" +
+					"tcaOM5GNEcnTxl60WiA8rd3wgFSNb_UdJPXjpzm0KW9ojM5f4O2mRvE2" +
+					"IcweP-PRdoHjd5-RHCiExR1IK6t6tybdlmnMwcDMw0YxA_gFSE_IUWl7" +
+					"8aMtOepFYSWYfQA.YXJiaXRyYXJ5LXN0cmluZy10aGF0LWlzbid0LWpz" +
+					"b24"},
+			{"4-S-1", "", "", "this is a signed message",
+				"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwi" +
+					"ZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lT" +
+					"ZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1" +
+					"QlXEFtkqxT1ciiQEDA"},
+			{"4-S-2", kid, "", "this is a signed message",
+				"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwi" +
+					"ZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9v3Jt8mx_TdM2" +
+					"ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx" +
+					"1HcO-SPo8FPp214HDw.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z" +
+					"0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+			{"4-S-3", kid, `{"test-vector":"4-S-3"}`,
+				"this is a signed message",
+				"v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwi" +
+					"ZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9NPWciuD3d0o5" +
+					"eXJXG5pJy-DiVEoyPYWs1YSTwWHNJq6DZD3je5gf-0M4JR9ipdUSJbIo" +
+					"vzmBECeaWmaqcaP0DQ.eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z" +
+					"0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9"},
+		} {
+			var f, i = []byte(c.footer), []byte(c.implicit)
+			var exp = claims{Data: c.data, Exp: expires}
+			var ser Serializer
+			var p claims
+			var s string
+			var err error
+
+			if strings.HasPrefix(c.token, pasetoPublic) {
+				ser, err = NewPASETOPublic(edSer, f, i)
+			} else {
+				ser, err = NewPASETOLocal(key, f, i)
+			}
+
+			if nil != err {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+
+			if err = ser.Deserialize(c.token, &p); nil != err {
+				t.Errorf("%s: %v", c.name, err)
+			} else if exp != p {
+				t.Errorf("%s: expect %+v, got %+v", c.name, exp, p)
+			}
+
+			if !strings.HasPrefix(c.token, pasetoPublic) {
+				continue
+			}
+
+			if s, err = ser.Serialize(&exp); nil != err {
+				t.Errorf("%s: %v", c.name, err)
+			} else if s != c.token {
+				t.Errorf("%s: token does not match test vector"+
+					"\nexp: %s"+
+					"\nret: %s", c.name, c.token, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package serializer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// names of the claims of an SD-JWT that hold the digests of the disclosures
+// and the hash that computed them
+const (
+	claimSD    = "_sd"
+	claimSDAlg = "_sd_alg"
+)
+
+// sdFixed are the registered claims of a JWT that must never be selectively
+// disclosable, as a service must always see them to validate the token (see
+// RFC 7519, sec. 4.1).
+var sdFixed = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true,
+	"iat": true, "jti": true,
+}
+
+// sdSaltLen is the length of the random salt of each disclosure, before it is
+// Base64 encoded.
+const sdSaltLen = 16
+
+// sdJWTSerializer is an internal implementation of Serializer returned by the
+// NewSDJWT function. It generates and parses JWTs with Selective Disclosure
+// (SD-JWT), i.e.
+//
+//     [JWT]~[Disclosure]~...~[Disclosure]~
+//
+// where the JWT is signed by a jwtSerializer, and each Disclosure is the
+// Base64 encoded JSON array [salt, name, value] of a claim that was removed
+// from the JWT, and whose SHA-256 digest is listed in the "_sd" claim instead.
+type sdJWTSerializer struct {
+	jwt    *jwtSerializer
+	claims map[string]bool
+}
+
+// sdJWT is the JSON object of the claims of an SD-JWT.
+type sdJWT map[string]json.RawMessage
+
+// NewSDJWT returns a Serializer that generates and parses JSON Web Tokens
+// whose claims named in claims are selectively disclosable (SD-JWT, see
+// draft-ietf-oauth-selective-disclosure-jwt): instead of the claims, the JWT
+// holds salted SHA-256 digests of them, and the claims themselves are
+// appended to the JWT as separate disclosures. The holder of a token can
+// then drop the disclosures of the claims that a service should not see (see
+// Disclose), without invalidating the Signature.
+//
+// ser and allow are as for NewJWT, and the token passed to Serialize must be
+// JSON encoded to an object; only its top-level claims can be selectively
+// disclosable. Deserialize checks the digest of each disclosure against the
+// digests signed in the JWT, and unpacks the claims of the JWT along with the
+// disclosed claims only. Key Binding JWTs are not supported. An error is
+// returned if claims is empty, or names a claim used by SD-JWT itself or one
+// of the registered claims of RFC 7519 ("iss", "sub", "aud", "exp", "nbf",
+// "iat" and "jti"), which must always be visible.
+func NewSDJWT(ser Serializer,
+	claims []string, allow ...string) (Serializer, error) {
+
+	var sr = &sdJWTSerializer{claims: make(map[string]bool, len(claims))}
+	var jwt, err = NewJWT(ser, allow...)
+
+	if nil != err {
+		return nil, err
+	} else if 0 == len(claims) {
+		return nil, errorf(efSDClaim, "", "")
+	}
+
+	for _, c := range claims {
+		if 0 == len(c) || claimSD == c || claimSDAlg == c || "..." == c ||
+			sdFixed[c] {
+			return nil, errorf(efSDClaim, "", c)
+		}
+
+		sr.claims[c] = true
+	}
+
+	sr.jwt = jwt.(*jwtSerializer)
+	return sr, nil
+}
+
+// Disclose returns the SD-JWT s with only the disclosures of the claims named
+// in claims, so that the other selectively disclosable claims are withheld
+// from the service that s is presented to. Neither the Signature nor the
+// digests of the disclosures are checked. An error is returned if s is
+// malformed.
+func Disclose(s string, claims ...string) (string, error) {
+	var parts = strings.Split(s, "~")
+	var b strings.Builder
+
+	if 2 > len(parts) || 0 != len(parts[len(parts)-1]) {
+		return "", decodeError(StageHeader, ErrBadFormat)
+	}
+
+	b.WriteString(parts[0])
+	b.WriteByte('~')
+
+	for _, d := range parts[1 : len(parts)-1] {
+		var name, _, err = parseDisclosure(d)
+		if nil != err {
+			return "", err
+		}
+
+		for _, c := range claims {
+			if c == name {
+				b.WriteString(d)
+				b.WriteByte('~')
+				break
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Serialize makes sdJWTSerializer implement the Serializer interface. The
+// disclosures are appended in no particular order, and their digests are
+// sorted, so that neither gives anything away about the claims.
+func (sr *sdJWTSerializer) Serialize(token interface{}) (s string, err error) {
+	var claims sdJWT
+	var digests []string
+	var b []byte
+
+	if b, err = json.Marshal(token); nil != err {
+		return
+	} else if err = json.Unmarshal(b, &claims); nil != err {
+		return
+	}
+
+	var ds strings.Builder
+
+	for name, value := range claims {
+		if !sr.claims[name] {
+			continue
+		}
+
+		var d string
+		if d, err = newDisclosure(name, value); nil != err {
+			return
+		}
+
+		delete(claims, name)
+		digests = append(digests, sdDigest(d))
+		ds.WriteString(d)
+		ds.WriteByte('~')
+	}
+
+	sort.Strings(digests)
+
+	if claims[claimSD], err = json.Marshal(digests); nil != err {
+		return
+	}
+
+	claims[claimSDAlg] = json.RawMessage(`"sha-256"`)
+
+	if s, err = sr.jwt.Serialize(claims); nil != err {
+		return
+	}
+
+	return s + "~" + ds.String(), nil
+}
+
+// Deserialize makes sdJWTSerializer implement the Serializer interface.
+// ErrBadSign is returned if a disclosure does not match any of the digests
+// in the JWT, or matches one that was disclosed already. The length of the
+// whole SD-JWT, and its claims once disclosed, are limited like those of a
+// JWT.
+func (sr *sdJWTSerializer) Deserialize(
+	s string, token interface{}) (err error) {
+
+	var i = strings.IndexByte(s, '~')
+	var claims sdJWT
+	var digests []string
+	var l limits
+	var b []byte
+
+	// the SD-JWT ends with a '~', unless it carries a Key Binding JWT; no
+	// key accepts one longer than the longest limit (see jwtSerializer)
+	if len(s) > sr.jwt.keys.maxLength() {
+		return decodeError(StageHeader, ErrTooLarge)
+	} else if i < 0 || '~' != s[len(s)-1] {
+		return decodeError(StageHeader, ErrBadFormat)
+	}
+
+	// the disclosures are only split once the JWT is verified, and the
+	// length of the SD-JWT checked
+	if l, err = sr.jwt.deserialize(s[:i], len(s), &claims); nil != err {
+		return
+	}
+
+	if err = json.Unmarshal(claims[claimSD], &digests); nil != err {
+		return formatError(StagePayload, err)
+	} else if string(claims[claimSDAlg]) != `"sha-256"` {
+		return decodeError(StageHeader, ErrBadAlg)
+	}
+
+	delete(claims, claimSD)
+	delete(claims, claimSDAlg)
+
+	var signed = make(map[string]bool, len(digests))
+	for _, d := range digests {
+		signed[d] = true
+	}
+
+	// each disclosure is followed by a '~'
+	var parts = strings.Split(s[i+1:], "~")
+
+	for _, d := range parts[:len(parts)-1] {
+		var name string
+		var value json.RawMessage
+
+		if name, value, err = parseDisclosure(d); nil != err {
+			return
+		}
+
+		// each disclosure must match a digest that was signed, once
+		var digest = sdDigest(d)
+		if !signed[digest] {
+			return decodeError(StageSignature, ErrBadSign)
+		}
+
+		delete(signed, digest)
+
+		if _, ok := claims[name]; ok || claimSD == name ||
+			claimSDAlg == name {
+			return decodeError(StagePayload, ErrBadFormat)
+		}
+
+		claims[name] = value
+	}
+
+	if b, err = json.Marshal(claims); nil != err {
+		return decodeError(StageCodec, err)
+	}
+
+	return decodeError(StageCodec, l.unmarshal(JSON, b, token))
+}
+
+// newDisclosure returns the Base64 encoded disclosure of the claim name with
+// the JSON encoded value, salted with random bytes.
+func newDisclosure(name string, value json.RawMessage) (string, error) {
+	var salt [sdSaltLen]byte
+	var b []byte
+	var err error
+
+	if _, err = rand.Read(salt[:]); nil != err {
+		return "", err
+	}
+
+	if b, err = json.Marshal([]interface{}{
+		jwtEncoding.EncodeToString(salt[:]), name, value,
+	}); nil != err {
+		return "", err
+	}
+
+	return jwtEncoding.EncodeToString(b), nil
+}
+
+// parseDisclosure returns the name and JSON encoded value of the claim in
+// the Base64 encoded disclosure d, or ErrBadFormat as a *DecodeError at
+// StagePayload if d is malformed.
+func parseDisclosure(d string) (name string, value json.RawMessage, err error) {
+	var b []byte
+	var arr []json.RawMessage
+	var salt string
+
+	if b, err = jwtEncoding.DecodeString(d); nil != err {
+		return "", nil, formatError(StagePayload, err)
+	} else if err = json.Unmarshal(b, &arr); nil != err {
+		return "", nil, formatError(StagePayload, err)
+	}
+
+	// disclosures of array elements have no name, and are not supported
+	if 3 != len(arr) {
+		return "", nil, decodeError(StagePayload, ErrBadFormat)
+	} else if err = json.Unmarshal(arr[0], &salt); nil != err {
+		return "", nil, formatError(StagePayload, err)
+	} else if err = json.Unmarshal(arr[1], &name); nil != err {
+		return "", nil, formatError(StagePayload, err)
+	}
+
+	return name, arr[2], nil
+}
+
+// sdDigest returns the Base64 encoded SHA-256 digest of the ASCII bytes of
+// the Base64 encoded disclosure d.
+func sdDigest(d string) string {
+	var h = sha256.Sum256([]byte(d))
+	return jwtEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,146 @@
+package serializer
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tClaimsT is a test token with claims to be selectively disclosed.
+type tClaimsT struct {
+	Sub   string   `json:"sub"`
+	Email string   `json:"email,omitempty"`
+	Dept  string   `json:"dept,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+func TestSDJWT(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var claims = tClaimsT{Sub: "alice", Email: "alice@example.com",
+		Dept: "sales", Roles: []string{"admin"}}
+	var ser, err = NewSDJWT(hmacSer, []string{"email", "dept", "roles"})
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	t.Run("New", func(t *testing.T) {
+		var noneSer, _ = New(SignNone, nil, 0)
+
+		for _, c := range []struct {
+			ser    Serializer
+			claims []string
+		}{
+			{noneSer, []string{"email"}},
+			{hmacSer, nil},
+			{hmacSer, []string{""}},
+			{hmacSer, []string{"email", "_sd"}},
+			{hmacSer, []string{"_sd_alg"}},
+			{hmacSer, []string{"email", "exp"}},
+			{hmacSer, []string{"nbf"}}, {hmacSer, []string{"iat"}},
+			{hmacSer, []string{"iss"}}, {hmacSer, []string{"aud"}},
+			{hmacSer, []string{"sub"}}, {hmacSer, []string{"jti"}},
+		} {
+			if sr, err := NewSDJWT(c.ser, c.claims); nil == err || nil != sr {
+				t.Errorf("%q: expect error", c.claims)
+			}
+		}
+	})
+
+	t.Run("Disclose", func(t *testing.T) {
+		var s, err = ser.Serialize(claims)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		// none of the selectively disclosable claims are in the JWT itself
+		var jwt = s[:strings.IndexByte(s, '~')]
+		if 4 != strings.Count(s, "~") {
+			t.Errorf("expect 3 disclosures, got %s", s)
+		} else if p, _ := jwtEncoding.DecodeString(strings.Split(jwt,
+			".")[1]); strings.Contains(string(p), "alice@") ||
+			!strings.Contains(string(p), `"_sd"`) {
+			t.Errorf("unexpected JWT payload %s", p)
+		}
+
+		for _, c := range []struct {
+			claims []string
+			expect tClaimsT
+		}{
+			{nil, tClaimsT{Sub: "alice"}},
+			{[]string{"email"}, tClaimsT{Sub: "alice",
+				Email: "alice@example.com"}},
+			{[]string{"dept", "roles", "sub"}, tClaimsT{Sub: "alice",
+				Dept: "sales", Roles: []string{"admin"}}},
+			{[]string{"email", "dept", "roles"}, claims},
+		} {
+			var ds, err = Disclose(s, c.claims...)
+			var p tClaimsT
+
+			if nil != err {
+				t.Errorf("%q: %v", c.claims, err)
+			} else if err = ser.Deserialize(ds, &p); nil != err {
+				t.Errorf("%q: %v", c.claims, err)
+			} else if !reflect.DeepEqual(p, c.expect) {
+				t.Errorf("%q: expect %+v, got %+v", c.claims, c.expect, p)
+			}
+		}
+
+		// the JWT alone is not an SD-JWT, and a plain JWT Serializer cannot
+		// read an SD-JWT
+		var jwtSer, _ = NewJWT(hmacSer)
+		var p tClaimsT
+
+		if err = ser.Deserialize(jwt, &p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat, got %v", err)
+		} else if err = jwtSer.Deserialize(s, &p); nil == err {
+			t.Error("expect error for SD-JWT read as JWT")
+		} else if err = ser.Deserialize(jwt+"~", &p); nil != err {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var s, _ = ser.Serialize(claims)
+		var other, _ = ser.Serialize(tClaimsT{Sub: "bob", Email: "bob@"})
+		var parts = strings.Split(s, "~")
+		var jwt, d = parts[0], parts[1]
+		var forged, _ = newDisclosure("email", []byte(`"mallory@"`))
+		var unsigned, _ = newDisclosure("admin", []byte(`true`))
+		var dup, _ = newDisclosure("sub", []byte(`"mallory"`))
+
+		for _, c := range []struct {
+			s     string
+			err   error
+			stage Stage
+		}{
+			{jwt + "~" + forged + "~", ErrBadSign, StageSignature},
+			{jwt + "~" + unsigned + "~", ErrBadSign, StageSignature},
+			{jwt + "~" + d + "~" + d + "~", ErrBadSign, StageSignature},
+			{jwt + "~" + strings.Split(other, "~")[1] + "~", ErrBadSign,
+				StageSignature},
+			{jwt + "~" + dup + "~", ErrBadSign, StageSignature},
+			{jwt + "~" + d, ErrBadFormat, StageHeader},
+			{jwt + "~" + d + "~kb.jwt.sig", ErrBadFormat, StageHeader},
+			{jwt + "~!~", ErrBadFormat, StagePayload},
+			{jwt + "~" + jwtEncoding.EncodeToString([]byte(`["s","x"]`)) +
+				"~", ErrBadFormat, StagePayload},
+			{"x" + s, ErrBadFormat, StageHeader},
+		} {
+			var p tClaimsT
+			var de *DecodeError
+
+			if err := ser.Deserialize(c.s, &p); !errors.Is(err, c.err) {
+				t.Errorf("%.32s: expect %v, got %v", c.s, c.err, err)
+			} else if errors.As(err, &de); c.stage != de.Stage {
+				t.Errorf("%.32s: unexpected stage %v", c.s, de.Stage)
+			}
+		}
+
+		if _, err := Disclose(jwt); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat, got %v", err)
+		}
+	})
+}
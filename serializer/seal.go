@@ -0,0 +1,247 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Different sealing methods available for the Serializer returned by
+// NewSealer. Both take a 256-bit key.
+const (
+	SealAESGCM    SealMethod = iota // AES-256-GCM (see crypto/cipher)
+	SealXChaCha20                   // XChaCha20-Poly1305 (see x/crypto)
+	maxSealMethod
+
+	// paramSeal is the name of the Header parameter that carries the name of
+	// the SealMethod that encrypted a StringToken.
+	paramSeal = "enc"
+
+	// sealKeyLen is the length of the key expected by all SealMethods.
+	sealKeyLen = 32
+)
+
+// sealNames are the values of the paramSeal Header parameter for each
+// SealMethod, borrowed from the JOSE "enc" algorithm names where applicable.
+var sealNames = [maxSealMethod]string{"A256GCM", "XC20P"}
+
+// SealMethod is an enum type used for the AEAD ciphers that the Serializer
+// returned by NewSealer uses to encrypt the Payload part of StringToken.
+type SealMethod uint
+
+// sealSerializer is an internal implementation of the Serializer interface
+// and may be returned by the NewSealer function. It is a Serializer that
+// encrypts the binary (msgpack encoded) Payload with an AEAD cipher instead of
+// computing a Signature from it. A sealed StringToken looks like this:
+//
+//     auth;enc=[SealMethod].[Nonce+Ciphertext]
+//
+// The Header, including all of its parameters (such as the Codec), is
+// authenticated as associated data, and the authentication tag of the cipher
+// takes the place of the Signature, which is omitted.
+type sealSerializer struct {
+	SealMethod
+
+	aead cipher.AEAD
+	opts Options
+}
+
+// NewSealer returns a Serializer that generates and parses sealed
+// StringTokens, whose Payload cannot be read without the key. It should be
+// used where StringTokens are handed to parties that must not learn the
+// contents of the Payload, e.g. the Subject of a token.Token.
+//
+// method is the SealMethod used to encrypt the Payload, and key must be a
+// byte-slice of exactly 32 bytes. A random nonce is generated for every
+// StringToken. AES-256-GCM uses 96-bit nonces; where a single key seals a very
+// large number of StringTokens, SealXChaCha20 should be preferred. Keys can be
+// rotated by adding several sealing Serializers to a KeyRing.
+//
+// A StringToken that was tampered with, or was sealed with a different key,
+// fails to deserialize with ErrBadSign.
+func NewSealer(method SealMethod, key []byte) (Serializer, error) {
+	var sr *sealSerializer
+	var err error
+
+	if sr, err = newSealSerializer(method, key); nil != err {
+		return nil, err
+	}
+
+	return sr, err
+}
+
+// newSealSerializer returns a new sealSerializer struct with the given method
+// and key.
+func newSealSerializer(sm SealMethod,
+	key []byte) (sr *sealSerializer, err error) {
+
+	if sm >= maxSealMethod {
+		return nil, errorf(efUndefSeal, "", sm)
+	}
+
+	if len(key) != sealKeyLen {
+		return nil, errorf(efBadKeySize,
+			"", fmt.Sprintf("%d bytes", sealKeyLen))
+	}
+
+	sr = &sealSerializer{SealMethod: sm}
+
+	switch sm {
+	case SealAESGCM:
+		var b cipher.Block
+		if b, err = aes.NewCipher(key); nil != err {
+			return nil, err
+		}
+
+		sr.aead, err = cipher.NewGCM(b)
+
+	case SealXChaCha20:
+		sr.aead, err = chacha20poly1305.NewX(key)
+	}
+
+	if nil != err {
+		return nil, err
+	}
+
+	return
+}
+
+// Serialize makes sealSerializer implement the Serializer interface.
+func (sr *sealSerializer) Serialize(token interface{}) (s string, err error) {
+	return sr.seal(formatHeader(sr.params()...), nil, token)
+}
+
+// Deserialize makes sealSerializer implement the Serializer interface.
+func (sr *sealSerializer) Deserialize(s string, token interface{}) (err error) {
+	return sr.deserialize(s, nil, token)
+}
+
+// deserialize implements Deserialize and DeserializeAD.
+func (sr *sealSerializer) deserialize(
+	s string, ad []byte, token interface{}) (err error) {
+
+	var ps []param
+	var body string
+	var c Codec
+
+	if err = sr.opts.checkLength(len(s)); nil != err {
+	} else if ps, body, err = parseHeader(s); nil != err {
+		err = decodeError(StageHeader, err)
+	} else if 0 == len(ps) || ps[0] != sr.param() {
+		err = decodeError(StageHeader, ErrBadFormat)
+	} else if c, err = sr.opts.decoder("", ps[1:]); nil != err {
+		err = decodeError(StageHeader, err)
+	} else {
+		err = sr.open(s[:len(s)-len(body)-1], body, ad, c, token)
+	}
+
+	return withAlg(err, sealNames[sr.SealMethod])
+}
+
+// param returns the Header parameter that identifies the SealMethod of sr.
+func (sr *sealSerializer) param() param {
+	return param{paramSeal, sealNames[sr.SealMethod]}
+}
+
+// params returns the Header parameters written by sr, i.e. the one that
+// identifies the SealMethod followed by those of the Codec, if any.
+func (sr *sealSerializer) params() []param {
+	return append([]param{sr.param()}, sr.opts.params("")...)
+}
+
+// seal encodes the Payload part of a sealed StringToken and returns it,
+// prefixed with the Header hdr (see formatHeader). The binary Payload, encoded
+// with the Codec of sr, is encrypted with the Header (bound to ad, see
+// appendAD) as associated data, and the random nonce is prepended to the
+// ciphertext before it is Base64 (url-safe) encoded.
+func (sr *sealSerializer) seal(hdr string,
+	ad []byte, payload interface{}) (s string, err error) {
+
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var ns = sr.aead.NonceSize()
+	var b, ct []byte
+
+	if err = sr.opts.codec().Encode(buf, payload); nil != err {
+		return
+	}
+
+	// make room for the nonce and ciphertext after the binary Payload, so
+	// that Seal does not need to allocate
+	b = buf.Bytes()
+	if n := len(b) + ns + len(b) + sr.aead.Overhead(); cap(b) < n {
+		b = append(make([]byte, 0, n), b...)
+	}
+
+	ct = b[len(b) : len(b)+ns]
+	if _, err = rand.Read(ct); nil != err {
+		return
+	}
+
+	// the associated data is the Header without its separator
+	ct = sr.aead.Seal(ct, ct, b, appendAD([]byte(hdr[:len(hdr)-1]), ad))
+
+	return hdr + base64.RawURLEncoding.EncodeToString(ct), nil
+}
+
+// open decrypts the Payload part s of a sealed StringToken, authenticating
+// the Header hdr (without its separator) and ad as associated data, and
+// unpacks the binary Payload to payload using the Codec c. ErrBadSign is
+// returned if the ciphertext cannot be authenticated. Errors are returned as
+// *DecodeErrors without an algorithm (see withAlg).
+//
+// hdr and s are copied to a pooled byte-slice and decrypted by openBytes, as
+// bodyDeserialize does.
+func (sr *sealSerializer) open(hdr, s string,
+	ad []byte, c Codec, payload interface{}) (err error) {
+
+	var b = slicePool.Get().(*[]byte)
+	defer slicePool.Put(b)
+
+	*b = append(append((*b)[:0], hdr...), s...)
+	return sr.openBytes((*b)[:len(hdr)], (*b)[len(hdr):], ad, c, payload)
+}
+
+// openBytes is the same as open, except that the Header and the Payload part
+// are held in the byte-slices hdr and b, which are not modified. The Payload
+// part is decoded and decrypted in a pooled buffer.
+func (sr *sealSerializer) openBytes(hdr, b []byte,
+	ad []byte, c Codec, payload interface{}) (err error) {
+
+	var buf = bufferPool.Get().(*bytes.Buffer)
+	defer func() { buf.Reset(); bufferPool.Put(buf) }()
+
+	var p = slicePool.Get().(*[]byte)
+	defer slicePool.Put(p)
+
+	var ns = sr.aead.NonceSize()
+	var ct, pt []byte
+
+	if err = decodeBase64(buf, b); nil != err {
+		return formatError(StagePayload, err)
+	}
+
+	if ct = buf.Bytes(); len(ct) < ns+sr.aead.Overhead() {
+		return decodeError(StagePayload, ErrBadFormat)
+	}
+
+	// appendAD must not extend hdr in place, which may be followed by b
+	*p = appendAD(append((*p)[:0], hdr...), ad)
+
+	if pt, err = sr.aead.Open(ct[ns:ns], ct[:ns], ct[ns:],
+		*p); nil != err {
+		return decodeError(StageSignature, err)
+	}
+
+	// the binary Payload was decrypted in place, following the nonce
+	buf.Truncate(ns + len(pt))
+	buf.Next(ns)
+
+	return decodeError(StageCodec, c.Decode(buf, payload))
+}
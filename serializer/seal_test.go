@@ -0,0 +1,137 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewSealer(t *testing.T) {
+	if ser, err := NewSealer(maxSealMethod, tRandBuf[:32]); nil == err ||
+		nil != ser {
+		t.Error("expect error for invalid method")
+	}
+
+	for _, n := range []int{0, 16, 31, 33, 64} {
+		if ser, err := NewSealer(SealAESGCM, tRandBuf[:n]); nil == err ||
+			nil != ser {
+			t.Errorf("expect error for key length %d", n)
+		}
+	}
+}
+
+func TestSealer(t *testing.T) {
+	var base = func(m SealMethod, enc string) func(*testing.T) {
+		return func(t *testing.T) {
+			var ser, other Serializer
+			var p tPayloadT
+			var s string
+			var err error
+
+			if ser, err = NewSealer(m, tRandBuf[:32]); nil != err {
+				t.Fatal(err)
+			} else if other, err = NewSealer(m, tRandBuf[32:64]); nil != err {
+				t.Fatal(err)
+			} else if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			}
+
+			if h := "auth;enc=" + enc + "."; !strings.HasPrefix(s, h) {
+				t.Errorf("expect Header %q, got %q", h, s)
+			} else if strings.Count(s, ".") != 1 {
+				t.Errorf("sealed StringToken has a Signature part: %q", s)
+			}
+
+			// the Payload does not leak identifiers
+			if b, err := base64.RawURLEncoding.DecodeString(
+				s[strings.IndexByte(s, '.')+1:]); nil != err {
+				t.Fatal(err)
+			} else if bytes.Contains(b, tPayload.UUID1[:]) ||
+				bytes.Contains(b, []byte(tPayload.URI)) {
+				t.Error("sealed Payload contains plaintext")
+			}
+
+			if err = ser.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			if err = other.Deserialize(s, &p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for wrong key, got (%v)", err)
+			}
+
+			if err = ser.Deserialize(tamperSign(s),
+				&p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for tampered token, got (%v)", err)
+			}
+
+			if err = ser.Deserialize(header+s[len(s)-16:],
+				&p); !errors.Is(err, ErrBadFormat) {
+				t.Errorf("expect ErrBadFormat for plain Header, got (%v)", err)
+			}
+		}
+	}
+
+	t.Run("AESGCM", base(SealAESGCM, "A256GCM"))
+	t.Run("XChaCha20", base(SealXChaCha20, "XC20P"))
+}
+
+func TestSealerKeyRing(t *testing.T) {
+	var kr = NewKeyRing()
+	var aes, _ = NewSealer(SealAESGCM, tRandBuf[:32])
+	var xcc, _ = NewSealer(SealXChaCha20, tRandBuf[32:64])
+	var s0, s1 string
+	var p tPayloadT
+	var err error
+
+	kr.Add("k0", aes)
+	kr.Add("k1", xcc)
+	kr.Add("k2", xcc)
+
+	if err = kr.Activate("k0"); nil != err {
+		t.Fatal(err)
+	} else if s0, err = kr.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(s0, "auth;kid=k0;enc=A256GCM.") {
+		t.Errorf("unexpected Header: %q", s0)
+	}
+
+	if err = kr.Activate("k1"); nil != err {
+		t.Fatal(err)
+	} else if s1, err = kr.Serialize(tPayload); nil != err {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{s0, s1} {
+		if err = kr.Deserialize(s, &p); nil != err {
+			t.Error(err)
+		} else if !reflect.DeepEqual(tPayload, p) {
+			t.Error("deserialized payload does not match expectation")
+		}
+	}
+
+	// the Header is authenticated; moving a StringToken to another key ID fails
+	// even if the key is the same
+	var body = s1[strings.IndexByte(s1, '.'):]
+	if err = kr.Deserialize("auth;kid=k2;enc=XC20P"+body,
+		&p); !errors.Is(err, ErrBadSign) {
+		t.Errorf("expect ErrBadSign for swapped key id, got (%v)", err)
+	} else if err = kr.Deserialize("auth;kid=k1"+body,
+		&p); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("expect ErrBadFormat without SealMethod, got (%v)", err)
+	} else if err = kr.Deserialize("auth;kid=k1;enc=A256GCM"+body,
+		&p); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("expect ErrBadFormat for wrong SealMethod, got (%v)", err)
+	}
+
+	// sealing keys cannot be used for JWTs
+	if jwt, err := NewJWT(kr); nil != err {
+		t.Fatal(err)
+	} else if _, err = jwt.Serialize(tPayload); nil == err {
+		t.Error("expect error for JWT with sealing key")
+	}
+}
@@ -17,32 +17,83 @@
 // Like JWT, StringToken has three parts, separated by a single '.' character,
 // and like JWT, the Payload and Signature parts are Base64 (url-safe RFC 4648)
 // encoded binary chunks. However, unlike JWT, the Header part is a plaintext
-// constant string "auth". Other differences that make StringToken completely
-// different from and incompatible with JWT, are specified below.
+// constant string "auth", optionally followed by ';' separated name=value
+// parameters such as the key ID written by a KeyRing ("auth;kid=2025-10"), or
+// the tenant that the signing key was derived for (see NewDerived).
+// Other differences that make StringToken completely different from and
+// incompatible with JWT, are specified below.
 //
 // Given a data structure S that is to be used as the payload, in JWT, the
 // Payload part is the Base64 encoded form of the JSON encoded S. However, in
 // StringToken, the Payload is the Base64 encoded form of the Msgpack encoding
 // [1] of S. This is in part due to certain Go implementations of Msgpack being
 // magnitudes of order faster [2] and smaller in size as compared to JSON.
+// Where StringTokens must be read by services without good Msgpack support,
+// the Payload can be encoded with JSON or CBOR instead (see WithOptions); the
+// Codec is then named in the Header ("auth;codec=json"). Large Payloads can
+// also be compressed to keep StringTokens short (see Options.Compress).
 //
 // The Signature part of a JWT is a cryptographic signature computed from the
-// Base64 encoded form of the sequence [Header].[Signature] and then appended
-// to the same. However, the Signature part of a StringToken is computed
-// directly from the binary (Msgpack encoded) form of Payload. The Payload is
-// Base64 encoded, and so is the Signature, and then they are arranged in the
-// StringToken format above.
+// Base64 encoded form of the sequence [Header].[Signature] and then appended to
+// the same. However, the Signature part of a StringToken is computed directly
+// from the binary (Msgpack encoded) form of Payload. Where the Payload is
+// encoded by another Codec, compressed, or bound to associated data (see
+// below), it is preceded by the byte 0xc1, which Msgpack never uses, the name
+// of its Codec, a zero byte and its Compression ("\xc1msgpack\x00\x01"), and
+// followed by the associated data and its length as a 64-bit integer, so that
+// a Payload cannot be passed off as one of another Codec, as compressed, or as
+// bound to other associated data. The Payload is Base64 encoded, and so is the
+// Signature, and then they are arranged in the StringToken format above.
+// Unlike the "alg" Header parameter of JWT, the algorithm that computed the
+// Signature is only recorded in the Header on request ("auth;alg=rsa+sha-256",
+// see Options.Alg), and is never trusted on its own: it merely selects one of
+// the allowed verifiers of a Serializer returned by NewMultiVerifier. The
+// Signature may also cover associated data that is never written to the
+// StringToken, such as the name of the service that accepts it, so that it
+// cannot be replayed elsewhere (see ADSerializer). Where Signatures are
+// expensive to verify, as with RSA, the StringTokens that were verified once
+// can be cached (see Cache). The Signature may also be chained over caveats
+// that any holder of a StringToken can append to narrow it down, such as an
+// earlier expiry (see MacaroonSerializer).
+//
+// StringTokens signed by earlier versions of this package remain valid, except
+// those signed with P-521 ECDSA keys, which carried 130 byte Signatures instead
+// of the 132 bytes of RFC 7518 that are written now.
 //
 // The Signature part of a StringToken is strictly option, but it is highly
 // inadvisable to used StringTokens without signature. A StringToken without a
 // signature part is perfectly valid as long as the Payload part matches the
 // spec.
 //
+// As StringTokens are taken from untrusted requests, their length, and the
+// nesting and size of their Payloads, are limited before anything is decoded; a
+// strict mode also rejects unknown fields (see Options.MaxLength and
+// Options.Strict). The tokens of NewJWT, NewSDJWT, NewCWT and NewPASETOPublic
+// are limited alike, by the Options of the key that verifies them, and those of
+// NewPASETOLocal by the default limits. Note that the defaults,
+// DefaultMaxLength (16 KiB) and DefaultMaxItems (1024 elements per array or
+// map), reject tokens that earlier versions of this package accepted; where
+// such tokens are still in circulation, higher limits must be set (see
+// WithOptions).
+//
+// Note that the Payload part is merely encoded, and can be read by anyone
+// holding the StringToken (see Inspect). Where that is not acceptable, the
+// Serializer returned by NewSealer encrypts the Payload with an AEAD cipher,
+// whose authentication tag then takes the place of the Signature.
+//
 // StringToken is not a standardized, well thought out scheme in any way and in
 // fact, any client package can implement the Serializer interface whichever
 // way. The specification (if it can even be called that) laid out above is one
 // followed by the package-internal implementations of Serializer interface
-// that are returned by the NewSerializer function.
+// that are returned by the NewSerializer function. Where tokens must be read
+// by standard JWT libraries, the Serializer returned by NewJWT generates JSON
+// Web Tokens instead of StringTokens, NewSDJWT generates JWTs with
+// selectively disclosable claims, NewCWT generates binary CBOR Web Tokens,
+// and the Serializers returned by NewPASETOLocal and NewPASETOPublic generate
+// PASETO v4 tokens. The public keys that verify any of them can be published
+// as a JWK Set (see JWKS). Package serializertest checks that an
+// implementation of Serializer, built-in or not, rejects forged tokens like
+// the built-in ones do.
 //
 // Furthermore, the developers of this package have no plans for any promotion,
 // advocacy, guaranteed continued support or even standardization of
@@ -56,6 +107,9 @@ package serializer
 
 import (
 	"crypto"
+	"fmt"
+	"io"
+	"strings"
 )
 
 // Different signing methods available for the internal implementation of
@@ -66,6 +120,7 @@ const (
 	SignRSA              // RSASSA-PKCS-v1.5 Signature (see crypto/rsa)
 	SignPSS              // RSASSA-PSS Signature (see crypto/rsa)
 	SignECDSA            // ECDSA Signature (see crypto/ecdsa)
+	SignEdDSA            // Ed25519 Signature (see crypto/ed25519)
 	maxSignMethod
 
 	header = "auth."
@@ -74,15 +129,112 @@ const (
 // Serializer is a type that generates StringTokens from arbitrary Go data
 // structures as well as  verifies and unpacks StringTokens to arbitrary Go
 // data structures.
+//
+// The Serializers of this package return a *DecodeError if Deserialize
+// rejects a token; compare it with the Errors of this package using
+// errors.Is, e.g. errors.Is(err, ErrBadSign).
 type Serializer interface {
 	Serialize(token interface{}) (s string, err error)
 	Deserialize(s string, token interface{}) (err error)
 }
 
+// BytesSerializer is a Serializer that can also generate and parse
+// StringTokens held in byte-slices, without copying them to or from strings.
+// It is implemented by the Serializers returned by New and NewVerifier, and
+// by KeyRing; Encoder and Decoder use it where available.
+//
+// AppendSerialize appends the StringToken generated from token to dst and
+// returns the extended byte-slice, like the append builtin; dst is returned
+// unchanged on error. DeserializeBytes is the same as Deserialize; b is
+// neither modified nor retained, so it may be reused once it returns.
+type BytesSerializer interface {
+	Serializer
+	AppendSerialize(dst []byte, token interface{}) ([]byte, error)
+	DeserializeBytes(b []byte, token interface{}) (err error)
+}
+
+// signer is implemented by the internal Serializers that compute and verify
+// the Signature part of StringTokens, i.e. all but the SignNone Serializer.
+// It allows a signing key to be reused by Serializers that write the
+// StringToken themselves, such as KeyRing.
+type signer interface {
+	Serializer
+	algorithm() (SignMethod, crypto.Hash)
+	options() *Options
+	writeSign(b []byte, w io.Writer) error
+	compareSign(b, sig []byte) error
+}
+
+// keySource is implemented by Serializers that supply signers to Serializers
+// that write a token format of their own, such as the one returned by NewJWT.
+// kid is the key ID of a signer, or an empty string if keys are not
+// identified. maxLength returns the largest length limit of the keys (see
+// Options.MaxLength), which tokens are checked against before they are
+// decoded.
+type keySource interface {
+	signKey() (kid string, sg signer, err error)
+	verifyKey(kid string) (sg signer, err error)
+	maxLength() int
+}
+
+// singleKey is a keySource made of a single signer without a key ID.
+type singleKey struct {
+	signer
+}
+
+// signKey makes singleKey implement the keySource interface.
+func (k singleKey) signKey() (string, signer, error) {
+	return "", k.signer, nil
+}
+
+// verifyKey makes singleKey implement the keySource interface. kid is
+// ignored.
+func (k singleKey) verifyKey(kid string) (signer, error) {
+	return k.signer, nil
+}
+
+// maxLength makes singleKey implement the keySource interface.
+func (k singleKey) maxLength() int {
+	return k.options().maxLength()
+}
+
+// newKeySource returns the keySource for ser, which must either be a
+// keySource itself (like KeyRing) or a signer.
+func newKeySource(ser, dst interface{}) (keySource, error) {
+	switch ser := ser.(type) {
+	case keySource:
+		return ser, nil
+	case signer:
+		return singleKey{ser}, nil
+	}
+
+	return nil, errorf(efBadSerlr, "", ser, dst)
+}
+
 // SignMethod is an enum type used for various methods that the internal
 // implementations of Serializer use to computer Signature part of StringToken.
 type SignMethod uint
 
+// signMethodNames are the names of the SignMethods (see SignMethod.String).
+var signMethodNames = [maxSignMethod]string{
+	"none", "hmac", "rsa", "pss", "ecdsa", "eddsa",
+}
+
+// algNames are the names of the algorithms that compute Signatures (see
+// algName), indexed by SignMethod and hash.
+var algNames [maxSignMethod][crypto.BLAKE2b_512 + 1]string
+
+func init() {
+	for m := range algNames {
+		algNames[m][0] = signMethodNames[m]
+
+		for h := crypto.MD4; h <= crypto.BLAKE2b_512; h++ {
+			algNames[m][h] = signMethodNames[m] + "+" +
+				strings.ToLower(h.String())
+		}
+	}
+}
+
 // New returns a Serializer that can generate and parse StringTokens.
 //
 // method is a SignMethod used to compute Signature part of a StringToken that
@@ -91,12 +243,16 @@ type SignMethod uint
 // docs for the SignMethod constants.
 //
 // key should be a byte-slice of minimum length 256 bytes for SignHMAC, or one
-// of *rsa.PrivateKey and *ecdsa.PrivateKey for SignRSA/SignPSS and SignECDSA
-// respectvely. An error is returned if the key is not of the expected type.
+// of *rsa.PrivateKey, *ecdsa.PrivateKey and ed25519.PrivateKey for
+// SignRSA/SignPSS, SignECDSA and SignEdDSA respectvely. An error is returned
+// if the key is not of the expected type. Keys that are held by an HSM or a
+// key management service can be passed as any crypto.Signer whose Public key
+// has the expected type; Signatures are then computed by its Sign method.
 //
 // hash is used to compute a digest of the Payload prior to signing. The
 // package corresponding to the selected hash must be imported by the client
-// package. See docs for crypto.Hash constants for details.
+// package. See docs for crypto.Hash constants for details. Ed25519 signs the
+// Payload directly, so hash must be zero with SignEdDSA.
 func New(method SignMethod,
 	key interface{}, hash crypto.Hash) (Serializer, error) {
 
@@ -130,6 +286,55 @@ func New(method SignMethod,
 	}
 }
 
+// NewVerifier returns a Serializer that can only parse and verify StringTokens
+// generated by a Serializer returned by New with the same method and hash. It
+// is meant for services that need to accept StringTokens, but should not hold
+// the private key of the issuing service.
+//
+// key should be one of *rsa.PublicKey, *ecdsa.PublicKey and ed25519.PublicKey
+// for SignRSA/SignPSS, SignECDSA and SignEdDSA respectively. SignNone and
+// SignHMAC cannot be used with NewVerifier; the latter has no public key.
+//
+// The Serialize method of the returned Serializer always fails with
+// ErrVerifyOnly.
+func NewVerifier(method SignMethod,
+	key crypto.PublicKey, hash crypto.Hash) (Serializer, error) {
+
+	var ser *verifierSerializer
+	var err error
+
+	if !method.isValid() {
+		return nil, errorf(efUndefMethod, "", method)
+	}
+
+	if ser, err = newVerifierSerializer(method, key, hash); nil != err {
+		return nil, err
+	}
+
+	return ser, err
+}
+
+// String returns the name of sm, e.g. "ecdsa" for SignECDSA.
+func (sm SignMethod) String() string {
+	if sm < maxSignMethod {
+		return signMethodNames[sm]
+	}
+
+	return fmt.Sprintf("SignMethod(%d)", uint(sm))
+}
+
+// algName returns the name of the algorithm made up of the SignMethod m and
+// the hash h, as written to the Header of StringTokens (see Options.Alg). It is
+// the name of m, followed by '+' and the lowercase name of h, unless h is zero,
+// for example "rsa+sha-256", "ecdsa+sha-384" or "eddsa".
+func algName(m SignMethod, h crypto.Hash) string {
+	if m < maxSignMethod && h <= crypto.BLAKE2b_512 {
+		return algNames[m][h]
+	}
+
+	return fmt.Sprintf("%s+%d", m, uint(h))
+}
+
 // isValid returns true if sm corresponds to one of the valid, available
 // signing methods as declared in above contants.
 func (sm SignMethod) isValid() bool {
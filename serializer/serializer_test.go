@@ -3,6 +3,7 @@ package serializer
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -30,11 +31,12 @@ type tPayloadT struct {
 
 // global test variables
 var (
-	tPayload  tPayloadT         // _tP is a test payload
-	tStrToken string            // _tS is the Msgpack->Base64 encoded form of _tS
-	tRandBuf  [512]byte         // _tB is a buffer of random bytes
-	tRSAKey   *rsa.PrivateKey   // _kRSA is an RSA key for signing
-	tECDSAKey *ecdsa.PrivateKey // _kECDSA is an ECDSA key for signing
+	tPayload  tPayloadT          // _tP is a test payload
+	tStrToken string             // _tS is the Msgpack->Base64 encoded form of _tS
+	tRandBuf  [512]byte          // _tB is a buffer of random bytes
+	tRSAKey   *rsa.PrivateKey    // _kRSA is an RSA key for signing
+	tECDSAKey *ecdsa.PrivateKey  // _kECDSA is an ECDSA key for signing
+	tEdDSAKey ed25519.PrivateKey // _kEdDSA is an Ed25519 key for signing
 )
 
 func init() {
@@ -83,6 +85,13 @@ func init() {
 			panic(err)
 		}
 	}
+
+	{ // generate an Ed25519 key for _kEdDSA
+		var err error
+		if _, tEdDSAKey, err = ed25519.GenerateKey(rand.Reader); nil != err {
+			panic(err)
+		}
+	}
 }
 
 func TestNewSerializer(t *testing.T) {
@@ -150,8 +159,9 @@ func TestNewSerializer(t *testing.T) {
 				t.Error(err)
 			} else if cser, ok := ser.(*cryptoSerializer); !ok {
 				t.Error("expect ser = cryptoSerializer{...} for method = SignRSA")
-			} else if !reflect.DeepEqual(cser, &cryptoSerializer{
-				SignMethod: m, key: k, hash: crypto.SHA256}) {
+			} else if !reflect.DeepEqual(cser, (&cryptoSerializer{
+				SignMethod: m, key: k, hash: crypto.SHA256}).setPublic(
+				k.Public())) {
 				t.Error("contents of cryptoSerializer{...} do not match expectation")
 			}
 		}
@@ -160,4 +170,33 @@ func TestNewSerializer(t *testing.T) {
 	t.Run("SignRSA", cryptoBase(t, SignRSA, tRSAKey))
 	t.Run("SignPSS", cryptoBase(t, SignPSS, tRSAKey))
 	t.Run("SignECDSA", cryptoBase(t, SignECDSA, tECDSAKey))
+
+	t.Run("SignEdDSA", func(t *testing.T) {
+		var ser Serializer
+		var err error
+
+		if ser, err = New(SignEdDSA, "", 0); nil == err || nil != ser {
+			t.Error("expect error for bad key type")
+		}
+
+		if ser, err = New(SignEdDSA,
+			tEdDSAKey[:16], 0); nil == err || nil != ser {
+			t.Error("expect error for bad key length")
+		}
+
+		if ser, err = New(SignEdDSA,
+			tEdDSAKey, crypto.SHA256); nil == err || nil != ser {
+			t.Error("expect error for non-zero hash")
+		}
+
+		if ser, err = New(SignEdDSA, tEdDSAKey, 0); nil != err {
+			t.Error(err)
+		} else if cser, ok := ser.(*cryptoSerializer); !ok {
+			t.Error("expect ser = cryptoSerializer{...} for method = SignEdDSA")
+		} else if !reflect.DeepEqual(cser, (&cryptoSerializer{
+			SignMethod: SignEdDSA, key: tEdDSAKey}).setPublic(
+			tEdDSAKey.Public())) {
+			t.Error("contents of cryptoSerializer{...} do not match expectation")
+		}
+	})
 }
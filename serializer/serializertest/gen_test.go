@@ -0,0 +1,231 @@
+package serializertest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/rrm80/gautham/serializer"
+)
+
+// update makes TestGenerateVectors regenerate testdata/vectors.json.
+var update = flag.Bool("update", false, "regenerate testdata/vectors.json")
+
+// variants are the ways in which a Vector is derived from a valid token s,
+// given the token sq of another Payload, along with the name of the Error
+// that the derived token is rejected with.
+var variants = map[string]struct {
+	err string
+	mod func(format, s, sq string) string
+}{
+	"tampered": {"ErrBadSign", func(f, s, sq string) string {
+		var i = payloadStart(f, s) + 8
+		return s[:i] + string(tamper(s[i])) + s[i+1:]
+	}},
+	"truncated": {"ErrBadSign", func(f, s, sq string) string {
+		return s[:len(s)-4]
+	}},
+	"swapped": {"ErrBadSign", func(f, s, sq string) string {
+		return s[:strings.LastIndexByte(s, '.')] +
+			sq[strings.LastIndexByte(sq, '.'):]
+	}},
+	"prefix": {"ErrBadFormat", func(f, s, sq string) string {
+		return "x" + s
+	}},
+	"unsigned": {"ErrBadFormat", func(f, s, sq string) string {
+		return s[:strings.LastIndexByte(s, '.')]
+	}},
+	"alg-hs512": {"ErrBadAlg", func(f, s, sq string) string {
+		return jwtHeader("HS512") + s[strings.IndexByte(s, '.'):]
+	}},
+	"alg-none": {"ErrBadFormat", func(f, s, sq string) string {
+		return jwtHeader("none") +
+			s[strings.IndexByte(s, '.'):strings.LastIndexByte(s, '.')+1]
+	}},
+}
+
+// TestGenerateVectors regenerates testdata/vectors.json if the tests are run
+// with the -update flag:
+//
+//     go test -run Vectors -update
+//
+// The HMAC and EdDSA vectors are deterministic, as are all keys but the RSA
+// key. That key, the Signatures of SignPSS and SignECDSA, and the nonces of
+// sealed and v4.local tokens are random, so those vectors change every time
+// they are generated.
+func TestGenerateVectors(t *testing.T) {
+	if !*update {
+		t.Skip("run with -update to regenerate testdata/vectors.json")
+	}
+
+	var vs, err = generateVectors()
+	var b []byte
+
+	if nil != err {
+		t.Fatal(err)
+	} else if b, err = json.MarshalIndent(vs, "", "\t"); nil != err {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile("testdata/vectors.json", append(b, '\n'), 0644)
+	if nil != err {
+		t.Fatal(err)
+	}
+}
+
+// generateVectors returns the test vectors of testdata/vectors.json.
+func generateVectors() (vs []Vector, err error) {
+	var p = Payload{Subject: "alice", Audience: []string{"api"},
+		Expires: 1893456000, Admin: true}
+	var q = Payload{Subject: "bob", Expires: 1893456000}
+
+	var key, seed = make([]byte, 256), make([]byte, ed25519.SeedSize)
+	var edKey ed25519.PrivateKey
+	var ecdsaKey = &ecdsa.PrivateKey{D: new(big.Int)}
+	var rsaKey *rsa.PrivateKey
+	var ecdsaPub, rsaPub []byte
+
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	for i := range seed {
+		seed[i] = byte(0xa0 + i)
+	}
+
+	edKey = ed25519.NewKeyFromSeed(seed)
+
+	// the ECDSA key is fixed, but its Signatures are randomized
+	ecdsaKey.Curve = elliptic.P256()
+	ecdsaKey.D.SetBytes(key[1:33])
+	ecdsaKey.X, ecdsaKey.Y = ecdsaKey.Curve.ScalarBaseMult(key[1:33])
+
+	if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); nil != err {
+		return
+	} else if rsaPub, err = x509.MarshalPKIXPublicKey(
+		rsaKey.Public()); nil != err {
+		return
+	} else if ecdsaPub, err = x509.MarshalPKIXPublicKey(
+		ecdsaKey.Public()); nil != err {
+		return
+	}
+
+	var hmacSer, _ = serializer.New(serializer.SignHMAC, key, crypto.SHA256)
+	var rsaSer, _ = serializer.New(serializer.SignRSA, rsaKey, crypto.SHA256)
+	var pssSer, _ = serializer.New(serializer.SignPSS, rsaKey, crypto.SHA256)
+	var ecdsaSer, _ = serializer.New(serializer.SignECDSA, ecdsaKey,
+		crypto.SHA256)
+	var edSer, _ = serializer.New(serializer.SignEdDSA, edKey, 0)
+	var gcmSer, _ = serializer.NewSealer(serializer.SealAESGCM, key[:32])
+	var xcSer, _ = serializer.NewSealer(serializer.SealXChaCha20, key[:32])
+	var local, _ = serializer.NewPASETOLocal(key[:32], nil, nil)
+	var public, _ = serializer.NewPASETOPublic(edSer, nil, nil)
+
+	var jwt = func(ser serializer.Serializer) serializer.Serializer {
+		var jwt, _ = serializer.NewJWT(ser)
+		return jwt
+	}
+
+	var cwt = func(ser serializer.Serializer) serializer.Serializer {
+		var cwt, _ = serializer.NewCWT(ser)
+		return cwt
+	}
+
+	var edPub = edKey.Public().(ed25519.PublicKey)
+
+	for _, c := range []struct {
+		name, format, method, hash string
+		key                        []byte
+		ser                        serializer.Serializer
+		variants                   []string
+	}{
+		{"stringtoken-hmac-sha256", "stringtoken", "hmac", "SHA-256", key,
+			hmacSer, []string{"tampered", "truncated", "swapped", "prefix",
+				"unsigned"}},
+		{"stringtoken-rsa-sha256", "stringtoken", "rsa", "SHA-256", rsaPub,
+			rsaSer, []string{"tampered"}},
+		{"stringtoken-pss-sha256", "stringtoken", "pss", "SHA-256", rsaPub,
+			pssSer, []string{"tampered"}},
+		{"stringtoken-ecdsa-sha256", "stringtoken", "ecdsa", "SHA-256",
+			ecdsaPub, ecdsaSer, []string{"tampered"}},
+		{"stringtoken-eddsa", "stringtoken", "eddsa", "", edPub, edSer,
+			[]string{"tampered"}},
+		{"sealed-a256gcm", "sealed", "A256GCM", "", key[:32], gcmSer,
+			[]string{"tampered", "prefix"}},
+		{"sealed-xc20p", "sealed", "XC20P", "", key[:32], xcSer,
+			[]string{"tampered"}},
+		{"jwt-hs256", "jwt", "hmac", "SHA-256", key, jwt(hmacSer),
+			[]string{"tampered", "alg-hs512", "alg-none"}},
+		{"jwt-rs256", "jwt", "rsa", "SHA-256", rsaPub, jwt(rsaSer), nil},
+		{"jwt-ps256", "jwt", "pss", "SHA-256", rsaPub, jwt(pssSer), nil},
+		{"jwt-es256", "jwt", "ecdsa", "SHA-256", ecdsaPub, jwt(ecdsaSer),
+			nil},
+		{"jwt-eddsa", "jwt", "eddsa", "", edPub, jwt(edSer), nil},
+		{"cwt-hmac-sha256", "cwt", "hmac", "SHA-256", key, cwt(hmacSer),
+			[]string{"tampered"}},
+		{"cwt-es256", "cwt", "ecdsa", "SHA-256", ecdsaPub, cwt(ecdsaSer),
+			nil},
+		{"cwt-eddsa", "cwt", "eddsa", "", edPub, cwt(edSer), nil},
+		{"paseto-local", "paseto", "local", "", key[:32], local,
+			[]string{"tampered"}},
+		{"paseto-public", "paseto", "eddsa", "", edPub, public,
+			[]string{"tampered", "prefix"}},
+	} {
+		var v = Vector{Name: c.name, Format: c.format, Method: c.method,
+			Hash: c.hash, Key: hex.EncodeToString(c.key), Payload: &p}
+		var s, sq string
+
+		if s, err = c.ser.Serialize(&p); nil != err {
+			return
+		} else if sq, err = c.ser.Serialize(&q); nil != err {
+			return
+		}
+
+		v.Token = s
+		vs = append(vs, v)
+
+		for _, n := range c.variants {
+			var w = v
+			w.Name, w.Payload = c.name+"-"+n, nil
+			w.Token = variants[n].mod(c.format, s, sq)
+			w.Error = variants[n].err
+			vs = append(vs, w)
+		}
+	}
+
+	return vs, nil
+}
+
+// payloadStart returns the index of the first character of the Payload part
+// of the token s of the given format.
+func payloadStart(format, s string) int {
+	switch format {
+	case "paseto":
+		return strings.IndexByte(s[3:], '.') + 4
+	case "cwt":
+		// the tag, protected and unprotected Header of a COSE_Mac0 or
+		// COSE_Sign1 without a key ID take 9 bytes
+		return 12
+	}
+
+	return strings.IndexByte(s, '.') + 1
+}
+
+// jwtHeader returns the Base64 encoded JOSE Header of a JWT with the given
+// "alg" parameter.
+func jwtHeader(alg string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(
+		`{"alg":"` + alg + `","typ":"JWT"}`))
+}
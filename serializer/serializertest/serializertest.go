@@ -0,0 +1,246 @@
+// Package serializertest implements a conformance test suite for
+// implementations of the serializer.Serializer interface, and runs published
+// test vectors against them.
+//
+// Run checks that a Serializer behaves like the Serializers of package
+// serializer: that its tokens deserialize to the payload they were generated
+// from, and that tampered tokens, truncated tokens, tokens whose Signature was
+// swapped for that of another token, and tokens with a wrong prefix are all
+// rejected with one of the documented Errors of package serializer. A
+// Serializer under test, for example
+//
+//     func TestMySerializer(t *testing.T) {
+//         serializertest.Run(t, NewMySerializer(key))
+//     }
+//
+// must sign (or seal) its tokens; the suite is skipped for verify-only
+// Serializers (ErrVerifyOnly), and fails for unsigned ones. Serializers that
+// implement serializer.BytesSerializer or serializer.BinarySerializer are
+// checked through those interfaces as well.
+//
+// The test vectors in testdata/vectors.json are StringTokens, sealed
+// StringTokens, JWTs, CWTs and PASETO tokens generated by package serializer,
+// along with the Payload they carry or the Error they are rejected with (see
+// Vector); running the tests of this package with -update regenerates them.
+// RunVectors runs them against Go implementations. The vectors pin down the
+// StringToken format, which package serializer alone defines; the JWT and CWT
+// Serializers themselves are checked against the examples of RFC 7515 and RFC
+// 8392 in package serializer.
+package serializertest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rrm80/gautham/serializer"
+)
+
+// Payload is the token that the test suite and the test vectors serialize. It
+// is encoded under the same names with every Codec (CBOR falls back to the
+// json tags).
+type Payload struct {
+	Subject  string   `msgpack:"sub" json:"sub"`
+	Audience []string `msgpack:"aud,omitempty" json:"aud,omitempty"`
+	Expires  int64    `msgpack:"exp,omitempty" json:"exp,omitempty"`
+	Admin    bool     `msgpack:"adm,omitempty" json:"adm,omitempty"`
+}
+
+// rejections are the Errors that a Serializer may reject a malformed or
+// forged token with; they are those that do not depend on the Options or
+// the state of the Serializer.
+var rejections = []error{
+	serializer.ErrBadFormat,
+	serializer.ErrBadSign,
+	serializer.ErrBadAlg,
+	serializer.ErrBadCodec,
+	serializer.ErrUnknownKey,
+}
+
+// base64URL is the url-safe Base64 alphabet (see RFC 4648, sec. 5).
+const base64URL = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz" +
+	"0123456789-_"
+
+// Run runs the conformance test suite against ser as subtests of t. See the
+// package documentation for what is tested.
+func Run(t *testing.T, ser serializer.Serializer) {
+	var p = Payload{Subject: "alice", Audience: []string{"api"},
+		Expires: 1893456000, Admin: true}
+	var q = Payload{Subject: "bob", Expires: 1893456000}
+	var s, sq string
+	var err error
+
+	if s, err = ser.Serialize(&p); errors.Is(err, serializer.ErrVerifyOnly) {
+		t.Skip("verify-only Serializer")
+	} else if nil != err {
+		t.Fatal(err)
+	} else if sq, err = ser.Serialize(&q); nil != err {
+		t.Fatal(err)
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, c := range []struct {
+			s string
+			p Payload
+		}{{s, p}, {sq, q}} {
+			var r Payload
+
+			if err := ser.Deserialize(c.s, &r); nil != err {
+				t.Errorf("%.32s: %v", c.s, err)
+			} else if !reflect.DeepEqual(c.p, r) {
+				t.Errorf("%.32s: expect %+v, got %+v", c.s, c.p, r)
+			}
+		}
+	})
+
+	// every single character that is modified must invalidate the token
+	t.Run("Tampered", func(t *testing.T) {
+		for i := range s {
+			var ts = s[:i] + string(tamper(s[i])) + s[i+1:]
+			expectReject(t, ser, ts, "character %d modified", i)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		for i := range s {
+			expectReject(t, ser, s[:i], "truncated to %d characters", i)
+		}
+	})
+
+	// the Signature (last part) of one token must not verify another one,
+	// unless there is no separate Signature to swap
+	t.Run("Swapped", func(t *testing.T) {
+		var i, j = strings.LastIndexByte(s, '.'), strings.LastIndexByte(sq, '.')
+		if i < 0 || j < 0 {
+			t.Skip("no separate Signature")
+		}
+
+		for _, ss := range []string{s[:i] + sq[j:], sq[:j] + s[i:]} {
+			if ss == s || ss == sq {
+				t.Skip("no separate Signature")
+			}
+
+			var r Payload
+			if err := ser.Deserialize(ss, &r); !errors.Is(err,
+				serializer.ErrBadSign) {
+				t.Errorf("%.32s: expect ErrBadSign, got %v", ss, err)
+			}
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		for _, ps := range []string{"x" + s, "." + s, " " + s, ""} {
+			var r Payload
+			if err := ser.Deserialize(ps, &r); !errors.Is(err,
+				serializer.ErrBadFormat) {
+				t.Errorf("%q: expect ErrBadFormat, got %v", ps, err)
+			}
+		}
+	})
+
+	if bs, ok := ser.(serializer.BytesSerializer); ok {
+		t.Run("Bytes", func(t *testing.T) { runBytes(t, bs, s, p) })
+	}
+
+	if bs, ok := ser.(serializer.BinarySerializer); ok {
+		t.Run("Binary", func(t *testing.T) { runBinary(t, bs, p) })
+	}
+}
+
+// runBytes checks that the BytesSerializer bs appends tokens to byte-slices,
+// and deserializes them as the token s of the Payload p.
+func runBytes(t *testing.T,
+	bs serializer.BytesSerializer, s string, p Payload) {
+
+	var b, err = bs.AppendSerialize([]byte("prefix"), &p)
+	var r Payload
+
+	if nil != err {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(string(b), "prefix") {
+		t.Errorf("AppendSerialize overwrote dst: %.32s", b)
+	} else if err = bs.DeserializeBytes(b[6:], &r); nil != err {
+		t.Error(err)
+	} else if !reflect.DeepEqual(p, r) {
+		t.Errorf("expect %+v, got %+v", p, r)
+	}
+
+	if err = bs.DeserializeBytes([]byte(s), &r); nil != err {
+		t.Error(err)
+	}
+
+	for i := range s {
+		var err = bs.DeserializeBytes([]byte(s[:i]), &r)
+
+		if !rejected(err) {
+			t.Errorf("truncated to %d bytes: unexpected error %v", i, err)
+		}
+	}
+}
+
+// runBinary checks that the raw tokens of the BinarySerializer bs deserialize
+// to the Payload p, and that any modification invalidates them.
+func runBinary(t *testing.T, bs serializer.BinarySerializer, p Payload) {
+	var b, err = bs.SerializeBinary(&p)
+	var r Payload
+
+	if nil != err {
+		t.Fatal(err)
+	} else if err = bs.DeserializeBinary(b, &r); nil != err {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(p, r) {
+		t.Errorf("expect %+v, got %+v", p, r)
+	}
+
+	for i := range b {
+		b[i] ^= 0x01
+
+		if err = bs.DeserializeBinary(b, &r); !rejected(err) {
+			t.Errorf("byte %d modified: unexpected error %v", i, err)
+		}
+
+		b[i] ^= 0x01
+	}
+
+	for i := range b {
+		if err = bs.DeserializeBinary(b[:i], &r); !rejected(err) {
+			t.Errorf("truncated to %d bytes: unexpected error %v", i, err)
+		}
+	}
+}
+
+// expectReject reports an error with the message format and args, unless
+// ser rejects the token s with one of the rejections.
+func expectReject(t *testing.T, ser serializer.Serializer,
+	s string, format string, args ...interface{}) {
+
+	var r Payload
+
+	t.Helper()
+	if err := ser.Deserialize(s, &r); !rejected(err) {
+		t.Errorf(format+": unexpected error %v", append(args, err)...)
+	}
+}
+
+// rejected returns whether err is one of the rejections.
+func rejected(err error) bool {
+	for _, e := range rejections {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tamper returns a character other than c. Characters of the url-safe Base64
+// alphabet are replaced by the one whose value differs in the most
+// significant bit, which no Base64 decoder may ignore.
+func tamper(c byte) byte {
+	if i := strings.IndexByte(base64URL, c); i >= 0 {
+		return base64URL[i^0x20]
+	}
+
+	return 'A'
+}
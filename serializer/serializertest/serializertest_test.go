@@ -0,0 +1,152 @@
+package serializertest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/rrm80/gautham/serializer"
+)
+
+// tMethods are the SignMethods of Vectors, by name.
+var tMethods = map[string]serializer.SignMethod{
+	"hmac": serializer.SignHMAC, "rsa": serializer.SignRSA,
+	"pss": serializer.SignPSS, "ecdsa": serializer.SignECDSA,
+	"eddsa": serializer.SignEdDSA,
+}
+
+// tSeals are the SealMethods of sealed Vectors, by name.
+var tSeals = map[string]serializer.SealMethod{
+	"A256GCM": serializer.SealAESGCM, "XC20P": serializer.SealXChaCha20,
+}
+
+// tHashes are the hashes of Vectors, by name.
+var tHashes = map[string]crypto.Hash{
+	"": 0, "SHA-256": crypto.SHA256, "SHA-384": crypto.SHA384,
+	"SHA-512": crypto.SHA512,
+}
+
+func TestRun(t *testing.T) {
+	var key = make([]byte, 256)
+	var rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	var ecdsaKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var _, edKey, _ = ed25519.GenerateKey(rand.Reader)
+	var kr = serializer.NewKeyRing()
+
+	rand.Read(key)
+
+	var hmacSer, _ = serializer.New(serializer.SignHMAC, key, crypto.SHA256)
+	var rsaSer, _ = serializer.New(serializer.SignRSA, rsaKey, crypto.SHA256)
+	var pssSer, _ = serializer.New(serializer.SignPSS, rsaKey, crypto.SHA256)
+	var ecdsaSer, _ = serializer.New(serializer.SignECDSA, ecdsaKey,
+		crypto.SHA256)
+	var edSer, _ = serializer.New(serializer.SignEdDSA, edKey, 0)
+	var sealer, _ = serializer.NewSealer(serializer.SealAESGCM, key[:32])
+	var derived, _ = serializer.NewDerived(key, crypto.SHA256, "tenant-1")
+	var macaroon, _ = serializer.NewMacaroon(key)
+	var jwt, _ = serializer.NewJWT(edSer)
+	var cwt, _ = serializer.NewCWT(hmacSer)
+	var local, _ = serializer.NewPASETOLocal(key[:32], nil, nil)
+	var public, _ = serializer.NewPASETOPublic(edSer, []byte("kid"), nil)
+	var ver, _ = serializer.NewVerifier(serializer.SignEdDSA,
+		edKey.Public(), 0)
+
+	kr.Add("k0", hmacSer)
+	kr.Activate("k0")
+
+	for _, c := range []struct {
+		name string
+		ser  serializer.Serializer
+	}{
+		{"HMAC", hmacSer}, {"RSA", rsaSer}, {"PSS", pssSer},
+		{"ECDSA", ecdsaSer}, {"EdDSA", edSer}, {"Verifier", ver},
+		{"KeyRing", kr}, {"Sealer", sealer}, {"Derived", derived},
+		{"Macaroon", macaroon}, {"JWT", jwt}, {"CWT", cwt},
+		{"PASETOLocal", local}, {"PASETOPublic", public},
+	} {
+		if nil == c.ser {
+			t.Fatalf("%s: cannot construct Serializer", c.name)
+		}
+
+		t.Run(c.name, func(t *testing.T) { Run(t, c.ser) })
+	}
+}
+
+func TestVectors(t *testing.T) {
+	var f, err = os.Open("testdata/vectors.json")
+	var vs []Vector
+
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if vs, err = ReadVectors(f); nil != err {
+		t.Fatal(err)
+	}
+
+	RunVectors(t, vs, tNewSerializer)
+}
+
+// tNewSerializer returns the Serializer of package serializer for the Vector
+// v.
+func tNewSerializer(v *Vector) (ser serializer.Serializer, err error) {
+	var key []byte
+	var pub interface{}
+	var h = tHashes[v.Hash]
+
+	if key, err = hex.DecodeString(v.Key); nil != err {
+		return
+	}
+
+	// sealed and v4.local tokens are encrypted, rather than signed
+	switch {
+	case "sealed" == v.Format:
+		if m, ok := tSeals[v.Method]; ok {
+			return serializer.NewSealer(m, key)
+		}
+
+		return nil, nil
+	case "paseto" == v.Format && "local" == v.Method:
+		return serializer.NewPASETOLocal(key, nil, nil)
+	}
+
+	switch m := tMethods[v.Method]; m {
+	case serializer.SignHMAC:
+		ser, err = serializer.New(m, key, h)
+	case serializer.SignEdDSA:
+		ser, err = serializer.NewVerifier(m, ed25519.PublicKey(key), h)
+	case serializer.SignRSA, serializer.SignPSS, serializer.SignECDSA:
+		if pub, err = x509.ParsePKIXPublicKey(key); nil != err {
+			return
+		}
+
+		ser, err = serializer.NewVerifier(m, pub, h)
+	default:
+		return nil, nil
+	}
+
+	if nil != err {
+		return
+	}
+
+	switch v.Format {
+	case "stringtoken":
+		return ser, nil
+	case "jwt":
+		return serializer.NewJWT(ser)
+	case "cwt":
+		return serializer.NewCWT(ser)
+	case "paseto":
+		return serializer.NewPASETOPublic(ser, nil, nil)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,105 @@
+package serializertest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/rrm80/gautham/serializer"
+)
+
+// Vector is a test vector of testdata/vectors.json: a token of the given
+// Format, signed (or encrypted) with Method, Hash and Key, along with either
+// the Payload it deserializes to, or the name of the Error it is rejected
+// with.
+type Vector struct {
+	Name string `json:"name"`
+
+	// Format is the token format, one of "stringtoken" (see serializer.New),
+	// "sealed" (see serializer.NewSealer), "jwt" (see serializer.NewJWT),
+	// "cwt" (see serializer.NewCWT) and "paseto" (see
+	// serializer.NewPASETOLocal and serializer.NewPASETOPublic).
+	Format string `json:"format"`
+
+	// Method is the name of the SignMethod (see SignMethod.String), that of
+	// the SealMethod of a sealed token ("A256GCM" or "XC20P"), or "local" for
+	// a PASETO v4.local token. Hash is the name of the hash ("SHA-256",
+	// "SHA-384" or "SHA-512"), if any.
+	Method string `json:"method"`
+	Hash   string `json:"hash,omitempty"`
+
+	// Key is the hex encoded key that verifies or decrypts Token: the key
+	// itself for SignHMAC, sealed and v4.local tokens, the 32 bytes of the
+	// public key for SignEdDSA, and the DER encoded public key (see
+	// x509.MarshalPKIXPublicKey) for SignRSA, SignPSS and SignECDSA.
+	Key string `json:"key"`
+
+	Token   string   `json:"token"`
+	Payload *Payload `json:"payload,omitempty"`
+
+	// Error is the name of the Error that Token is rejected with, such as
+	// "ErrBadSign", if it is rejected.
+	Error string `json:"error,omitempty"`
+}
+
+// errorNames are the Errors that Vectors may be rejected with, by name.
+var errorNames = map[string]error{
+	"ErrBadFormat":  serializer.ErrBadFormat,
+	"ErrBadSign":    serializer.ErrBadSign,
+	"ErrBadAlg":     serializer.ErrBadAlg,
+	"ErrBadCodec":   serializer.ErrBadCodec,
+	"ErrUnknownKey": serializer.ErrUnknownKey,
+}
+
+// ReadVectors reads the JSON array of Vectors from r, like the one in
+// testdata/vectors.json.
+func ReadVectors(r io.Reader) (vs []Vector, err error) {
+	var dec = json.NewDecoder(r)
+
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&vs); nil != err {
+		return nil, err
+	}
+
+	return vs, nil
+}
+
+// RunVectors runs the test vectors vs as subtests of t. newSer returns the
+// Serializer that a Vector is to be deserialized with, given its Format,
+// Method, Hash and Key. If newSer returns a nil Serializer and no error, the
+// Vector is skipped, e.g. as its Format is not supported.
+func RunVectors(t *testing.T, vs []Vector,
+	newSer func(v *Vector) (serializer.Serializer, error)) {
+
+	for i := range vs {
+		var v = &vs[i]
+
+		t.Run(v.Name, func(t *testing.T) {
+			var ser, err = newSer(v)
+			var r Payload
+
+			if nil != err {
+				t.Fatal(err)
+			} else if nil == ser {
+				t.Skipf("%s/%s not supported", v.Format, v.Method)
+			}
+
+			err = ser.Deserialize(v.Token, &r)
+
+			if 0 == len(v.Error) {
+				if nil != err {
+					t.Error(err)
+				} else if nil == v.Payload ||
+					!reflect.DeepEqual(*v.Payload, r) {
+					t.Errorf("expect %+v, got %+v", v.Payload, r)
+				}
+			} else if e, ok := errorNames[v.Error]; !ok {
+				t.Fatalf("unknown error %q", v.Error)
+			} else if !errors.Is(err, e) {
+				t.Errorf("expect %s, got %v", v.Error, err)
+			}
+		})
+	}
+}
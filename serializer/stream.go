@@ -0,0 +1,158 @@
+package serializer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Encoder writes StringTokens to an output stream, one per line.
+type Encoder struct {
+	w   io.Writer
+	ser Serializer
+	buf []byte
+}
+
+// Decoder reads StringTokens from an input stream, one per line.
+type Decoder struct {
+	r   *bufio.Reader
+	ser Serializer
+	buf []byte
+	max int
+}
+
+// NewEncoder returns an Encoder that writes the StringTokens generated by ser
+// to w. The StringTokens are assembled in a buffer that is reused across calls
+// to Encode if ser is a BytesSerializer.
+func NewEncoder(w io.Writer, ser Serializer) *Encoder {
+	return &Encoder{w: w, ser: ser}
+}
+
+// Encode writes the StringToken generated from token to the stream, followed
+// by a newline character.
+func (e *Encoder) Encode(token interface{}) (err error) {
+	if bs, ok := e.ser.(BytesSerializer); ok {
+		if e.buf, err = bs.AppendSerialize(e.buf[:0], token); nil != err {
+			return
+		}
+	} else {
+		var s string
+
+		if s, err = e.ser.Serialize(token); nil != err {
+			return
+		}
+
+		e.buf = append(e.buf[:0], s...)
+	}
+
+	e.buf = append(e.buf, '\n')
+	_, err = e.w.Write(e.buf)
+	return
+}
+
+// NewDecoder returns a Decoder that reads StringTokens from r and verifies
+// them with ser. The Decoder buffers its input, and may read data from r
+// beyond the StringTokens requested. Lines longer than the length limit of
+// ser (see Options.MaxLength) are not buffered in full, but skipped with
+// ErrBadFormat.
+func NewDecoder(r io.Reader, ser Serializer) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), ser: ser, max: lineLimit(ser)}
+}
+
+// Decode reads the next StringToken from the stream and unpacks it to token
+// (see Serializer.Deserialize). A StringToken ends at a newline character,
+// optionally preceded by a carriage return, or at the end of the stream.
+// io.EOF is returned once there are no more StringTokens.
+func (d *Decoder) Decode(token interface{}) (err error) {
+	var b []byte
+
+	if b, err = d.readLine(); nil != err {
+		return
+	}
+
+	if bs, ok := d.ser.(BytesSerializer); ok {
+		return bs.DeserializeBytes(b, token)
+	}
+
+	return d.ser.Deserialize(string(b), token)
+}
+
+// readLine returns the next line of the stream, without the line ending. The
+// returned byte-slice is only valid until the next call to readLine.
+func (d *Decoder) readLine() (b []byte, err error) {
+	// lines that do not fit the buffer of the bufio.Reader are collected in
+	// d.buf, which is reused for that purpose, up to the length limit
+	if b, err = d.r.ReadSlice('\n'); bufio.ErrBufferFull == err {
+		d.buf = append(d.buf[:0], b...)
+
+		for bufio.ErrBufferFull == err && len(d.buf) <= d.max {
+			b, err = d.r.ReadSlice('\n')
+			d.buf = append(d.buf, b...)
+		}
+
+		// the rest of a longer line is skipped, so that the next call
+		// starts with the next line
+		if bufio.ErrBufferFull == err {
+			for bufio.ErrBufferFull == err {
+				_, err = d.r.ReadSlice('\n')
+			}
+
+			if nil == err || io.EOF == err {
+				err = decodeError(StageHeader, ErrBadFormat)
+			}
+
+			return nil, err
+		}
+
+		b = d.buf
+	}
+
+	if io.EOF == err && 0 != len(b) {
+		err = nil
+	}
+
+	if nil != err {
+		return nil, err
+	}
+
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r")), nil
+}
+
+// lineLimit returns the length limit of the tokens verified by ser, or
+// DefaultMaxLength if ser is not one of the Serializers of this package.
+func lineLimit(ser Serializer) (n int) {
+	switch sr := ser.(type) {
+	case keySource:
+		return sr.maxLength()
+	case signer:
+		return sr.options().maxLength()
+	case *multiVerifier:
+		for _, sg := range sr.sgs {
+			if sg.options().maxLength() > n {
+				n = sg.options().maxLength()
+			}
+		}
+		return n
+	case *genericSerializer:
+		return sr.opts.maxLength()
+	case *sealSerializer:
+		return sr.opts.maxLength()
+	case *derivedSerializer:
+		return sr.opts.maxLength()
+	case *MacaroonSerializer:
+		return sr.opts.maxLength()
+	case *Cache:
+		return lineLimit(sr.ser)
+	case *jwtSerializer:
+		return sr.keys.maxLength()
+	case *sdJWTSerializer:
+		return sr.jwt.keys.maxLength()
+	case *cwtSerializer:
+		return jwtEncoding.EncodedLen(sr.keys.maxLength())
+	case *pasetoSerializer:
+		return sr.options().maxLength()
+	}
+
+	return DefaultMaxLength
+}
@@ -0,0 +1,263 @@
+package serializer
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBytesSerializer(t *testing.T) {
+	var noneSer, _ = New(SignNone, nil, 0)
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var ecSer, _ = New(SignECDSA, tECDSAKey, crypto.SHA256)
+	var ecVer, _ = NewVerifier(SignECDSA, &tECDSAKey.PublicKey, crypto.SHA256)
+	var jsonSer, _ = WithOptions(hmacSer, Options{Codec: JSON, Alg: true})
+	var sealer, _ = NewSealer(SealXChaCha20, tRandBuf[:32])
+	var kr, sealKR = NewKeyRing(), NewKeyRing()
+
+	kr.Add("k0", ecSer)
+	kr.Activate("k0")
+	sealKR.Add("k0", sealer)
+	sealKR.Activate("k0")
+
+	var base = func(ser, ver Serializer) func(*testing.T) {
+		return func(t *testing.T) {
+			var bs, bv = ser.(BytesSerializer), ver.(BytesSerializer)
+			var dst = []byte("foo")
+			var s string
+			var b []byte
+			var p tPayloadT
+			var err error
+
+			if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			} else if b, err = bs.AppendSerialize(dst, tPayload); nil != err {
+				t.Fatal(err)
+			} else if !bytes.HasPrefix(b, dst) {
+				t.Fatal("AppendSerialize does not append to dst")
+			}
+
+			// the bytes and string forms are interchangeable; ECDSA and
+			// sealed StringTokens are randomized, so they are not compared
+			if b = b[len(dst):]; ser != ecSer && ser != kr &&
+				ser != sealKR && string(b) != s {
+				t.Errorf("expect %s, got %s", s, b)
+			}
+
+			var c = append([]byte(nil), b...)
+
+			if err = bv.DeserializeBytes(b, &p); nil != err {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			} else if !bytes.Equal(b, c) {
+				t.Error("DeserializeBytes modifies its input")
+			}
+
+			if err = bv.DeserializeBytes([]byte(s), &p); nil != err {
+				t.Error(err)
+			} else if err = ver.Deserialize(string(b), &p); nil != err {
+				t.Error(err)
+			}
+
+			// tampered and truncated StringTokens are rejected
+			var i = bytes.LastIndexByte(c, '.')
+
+			for _, b := range [][]byte{
+				nil, c[:i], c[:i+1], []byte("auth.!"),
+				append(append([]byte(nil), c[:i+1]...), "AAAA"...),
+			} {
+				if err = bv.DeserializeBytes(b, &p); nil == err &&
+					ser != noneSer {
+					t.Errorf("expect error for %q", b)
+				}
+			}
+		}
+	}
+
+	t.Run("None", base(noneSer, noneSer))
+	t.Run("HMAC", base(hmacSer, hmacSer))
+	t.Run("ECDSA", base(ecSer, ecVer))
+	t.Run("Options", base(jsonSer, jsonSer))
+	t.Run("KeyRing", base(kr, kr))
+	t.Run("KeyRing/Seal", base(sealKR, sealKR))
+
+	// the Payload and Signature are decoded straight from the input, so
+	// DeserializeBytes allocates no more than the HMAC and the Codec
+	t.Run("Allocs", func(t *testing.T) {
+		var s, _ = hmacSer.Serialize(tPayload)
+		var b = []byte(s)
+		var i = strings.LastIndexByte(s, '.')
+		var pb, _ = tokenEncoding.DecodeString(s[len(header):i])
+		var sig, _ = tokenEncoding.DecodeString(s[i+1:])
+		var sg = hmacSer.(signer)
+		var c, _ = sg.options().decoder("", nil)
+		var r = bytes.NewReader(nil)
+		var p tPayloadT
+
+		var n = testing.AllocsPerRun(100, func() {
+			hmacSer.(BytesSerializer).DeserializeBytes(b, &p)
+		})
+		var m = testing.AllocsPerRun(100, func() {
+			r.Reset(pb)
+			sg.compareSign(pb, sig)
+			c.Decode(r, &p)
+		})
+
+		if n > m {
+			t.Errorf("expect at most %v allocations, got %v", m, n)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var dst = []byte("foo")
+
+		if b, err := ecVer.(BytesSerializer).AppendSerialize(dst,
+			tPayload); ErrVerifyOnly != err {
+			t.Errorf("expect ErrVerifyOnly, got %v", err)
+		} else if string(b) != "foo" {
+			t.Error("AppendSerialize modifies dst on error")
+		}
+
+		if b, err := NewKeyRing().AppendSerialize(dst,
+			tPayload); ErrNoActiveKey != err {
+			t.Errorf("expect ErrNoActiveKey, got %v", err)
+		} else if string(b) != "foo" {
+			t.Error("AppendSerialize modifies dst on error")
+		}
+	})
+}
+
+func TestEncoder(t *testing.T) {
+	var hmacSer, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var jwt, _ = NewJWT(hmacSer)
+
+	// the payload of the last StringToken exceeds the buffer of the Decoder
+	var long = tPayload
+	long.URI = strings.Repeat("x", 8192)
+
+	for _, ser := range []Serializer{hmacSer, jwt} {
+		var buf bytes.Buffer
+		var enc = NewEncoder(&buf, ser)
+		var dec = NewDecoder(&buf, ser)
+		var exp = []tPayloadT{tPayload, {URI: "foo"}, tPayload, long}
+
+		for _, p := range exp {
+			if err := enc.Encode(p); nil != err {
+				t.Fatal(err)
+			}
+		}
+
+		if 4 != strings.Count(buf.String(), "\n") {
+			t.Fatalf("expect 4 lines, got %q", buf.String())
+		}
+
+		// the last line may omit the line ending
+		buf.Truncate(buf.Len() - 1)
+
+		for _, e := range exp {
+			var p tPayloadT
+
+			if err := dec.Decode(&p); nil != err {
+				t.Fatalf("%T: %v", ser, err)
+			} else if !reflect.DeepEqual(e, p) {
+				t.Errorf("%T: decoded payload does not match expectation", ser)
+			}
+		}
+
+		if err := dec.Decode(&tPayloadT{}); io.EOF != err {
+			t.Errorf("%T: expect io.EOF, got %v", ser, err)
+		}
+	}
+
+	t.Run("Bad", func(t *testing.T) {
+		var s, _ = hmacSer.Serialize(tPayload)
+		var dec = NewDecoder(strings.NewReader(s+"\r\n\nfoo\n"), hmacSer)
+		var p tPayloadT
+
+		if err := dec.Decode(&p); nil != err {
+			t.Error(err)
+		}
+
+		for _, exp := range []error{ErrBadFormat, ErrBadFormat, io.EOF} {
+			if err := dec.Decode(&p); !errors.Is(err, exp) {
+				t.Errorf("expect %v, got %v", exp, err)
+			}
+		}
+	})
+
+	// lines longer than the length limit are skipped without being buffered
+	t.Run("Long", func(t *testing.T) {
+		var short, _ = WithOptions(hmacSer, Options{MaxLength: 8192})
+		var s, _ = short.Serialize(tPayload)
+		var dec = NewDecoder(strings.NewReader("auth."+
+			strings.Repeat("A", 1<<20)+"\n"+s), short)
+		var p tPayloadT
+
+		if err := dec.Decode(&p); !errors.Is(err, ErrBadFormat) {
+			t.Errorf("expect ErrBadFormat, got %v", err)
+		} else if n := cap(dec.buf); n > 2*8192 {
+			t.Errorf("expect at most %d bytes buffered, got %d", 2*8192, n)
+		}
+
+		if err := dec.Decode(&p); nil != err {
+			t.Error(err)
+		} else if err = dec.Decode(&p); io.EOF != err {
+			t.Errorf("expect io.EOF, got %v", err)
+		}
+	})
+}
+
+func BenchmarkBytesSerializer(b *testing.B) {
+	var ser, _ = New(SignHMAC, tRandBuf[:256], crypto.SHA256)
+	var bs = ser.(BytesSerializer)
+	var s, _ = ser.Serialize(tPayload)
+	var sb = []byte(s)
+
+	b.Run("Serialize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := ser.Serialize(tPayload); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AppendSerialize", func(b *testing.B) {
+		var dst []byte
+		var err error
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if dst, err = bs.AppendSerialize(dst[:0], tPayload); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Deserialize", func(b *testing.B) {
+		var p tPayloadT
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := ser.Deserialize(s, &p); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("DeserializeBytes", func(b *testing.B) {
+		var p tPayloadT
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := bs.DeserializeBytes(sb, &p); nil != err {
+				b.Fatal(err)
+			}
+		}
+	})
+}
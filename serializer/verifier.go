@@ -0,0 +1,93 @@
+package serializer
+
+import (
+	"crypto"
+	"io"
+)
+
+// verifierSerializer is an internal implementation of Serializer and may be
+// returned by the NewVerifier function. It is a cryptoSerializer that holds
+// only the public half of the signing key, and so can verify and unpack
+// StringTokens but never generate them.
+type verifierSerializer struct {
+	cryptoSerializer
+}
+
+// publicKey wraps a crypto.PublicKey so that it satisfies crypto.Signer and
+// can be stored as the key of a cryptoSerializer. The compare methods of
+// cryptoSerializer only ever call Public; Sign always fails with
+// ErrVerifyOnly.
+type publicKey struct {
+	pub crypto.PublicKey
+}
+
+// Public makes publicKey implement the crypto.Signer interface.
+func (k publicKey) Public() crypto.PublicKey { return k.pub }
+
+// Sign makes publicKey implement the crypto.Signer interface.
+func (k publicKey) Sign(io.Reader,
+	[]byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, ErrVerifyOnly
+}
+
+// Serialize makes verifierSerializer implement the Serializer interface. It
+// always returns ErrVerifyOnly.
+func (sr *verifierSerializer) Serialize(
+	token interface{}) (s string, err error) {
+	return "", ErrVerifyOnly
+}
+
+// Deserialize makes verifierSerializer implement the Serializer interface.
+func (sr *verifierSerializer) Deserialize(
+	s string, token interface{}) (err error) {
+	return genericDeserialize(s, &sr.opts, algName(sr.algorithm()), token,
+		sr.compareSign)
+}
+
+// AppendSerialize makes verifierSerializer implement the BytesSerializer
+// interface. It always returns ErrVerifyOnly.
+func (sr *verifierSerializer) AppendSerialize(
+	dst []byte, token interface{}) ([]byte, error) {
+	return dst, ErrVerifyOnly
+}
+
+// DeserializeBytes makes verifierSerializer implement the BytesSerializer
+// interface.
+func (sr *verifierSerializer) DeserializeBytes(
+	b []byte, token interface{}) (err error) {
+	return genericDeserializeBytes(b, &sr.opts, algName(sr.algorithm()),
+		token, sr.compareSign)
+}
+
+// writeSign shadows cryptoSerializer.writeSign so that a verifierSerializer
+// fails with ErrVerifyOnly instead of panicking on the key type assertions.
+func (sr *verifierSerializer) writeSign(b []byte, w io.Writer) (err error) {
+	return ErrVerifyOnly
+}
+
+// newVerifierSerializer returns a new verifierSerializer struct with the
+// given public key and hash. An error is returned if key is not one of
+// *rsa.PublicKey, *ecdsa.PublicKey and ed25519.PublicKey, as expected by the
+// sign-method sm.
+func newVerifierSerializer(sm SignMethod,
+	key crypto.PublicKey, hash crypto.Hash) (*verifierSerializer, error) {
+
+	if SignNone == sm || SignHMAC == sm {
+		return nil, errorf(efBadMethod, "", sm, &verifierSerializer{})
+	}
+
+	if err := checkHash(sm, hash); nil != err {
+		return nil, err
+	}
+
+	if err := checkPublicKey(sm, key); nil != err {
+		return nil, err
+	}
+
+	var sr = &verifierSerializer{
+		cryptoSerializer{SignMethod: sm, key: publicKey{key}, hash: hash},
+	}
+
+	sr.setPublic(key)
+	return sr, nil
+}
@@ -0,0 +1,124 @@
+package serializer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewVerifier(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		if ver, err := NewVerifier(maxSignMethod+1,
+			tRSAKey.Public(), crypto.SHA256); nil == err || nil != ver {
+			t.Error("expect error for invalid method")
+		}
+
+		if ver, err := NewVerifier(SignHMAC,
+			tRandBuf[:256], crypto.SHA256); nil == err || nil != ver {
+			t.Error("expect error for method = SignHMAC")
+		}
+
+		if ver, err := NewVerifier(SignNone, nil, 0); nil == err || nil != ver {
+			t.Error("expect error for method = SignNone")
+		}
+	})
+
+	var base = func(m SignMethod, k crypto.Signer,
+		h crypto.Hash) func(t *testing.T) {
+		return func(t *testing.T) {
+			var ser, ver Serializer
+			var p tPayloadT
+			var s string
+			var err error
+
+			if ver, err = NewVerifier(m, k, h); nil == err || nil != ver {
+				t.Error("expect error for private key")
+			}
+
+			if ser, err = New(m, k, h); nil != err {
+				t.Fatal(err)
+			} else if ver, err = NewVerifier(m, k.Public(), h); nil != err {
+				t.Fatal(err)
+			}
+
+			if s, err = ver.Serialize(tPayload); ErrVerifyOnly != err {
+				t.Errorf("expect ErrVerifyOnly from Serialize, got (%v)", err)
+			} else if 0 != len(s) {
+				t.Error("verify-only Serialize returned a StringToken")
+			}
+
+			if s, err = ser.Serialize(tPayload); nil != err {
+				t.Fatal(err)
+			}
+
+			if err = ver.Deserialize(s, &p); nil != err {
+				t.Error(err)
+			} else if !reflect.DeepEqual(tPayload, p) {
+				t.Error("deserialized payload does not match expectation")
+			}
+
+			if err = ver.Deserialize(tamperSign(s),
+				&p); !errors.Is(err, ErrBadSign) {
+				t.Errorf("expect ErrBadSign for tampered token, got (%v)", err)
+			}
+		}
+	}
+
+	t.Run("SignRSA", base(SignRSA, tRSAKey, crypto.SHA256))
+	t.Run("SignPSS", base(SignPSS, tRSAKey, crypto.SHA256))
+	t.Run("SignECDSA", base(SignECDSA, tECDSAKey, crypto.SHA256))
+	t.Run("SignEdDSA", base(SignEdDSA, tEdDSAKey, 0))
+
+	t.Run("WrongKey", func(t *testing.T) {
+		var ser, ver Serializer
+		var k *ecdsa.PrivateKey
+		var pub ed25519.PublicKey
+		var p tPayloadT
+		var s string
+		var err error
+
+		if k, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); nil != err {
+			t.Fatal(err)
+		} else if pub, _, err = ed25519.GenerateKey(rand.Reader); nil != err {
+			t.Fatal(err)
+		}
+
+		if ver, err = NewVerifier(SignEdDSA,
+			pub[:16], 0); nil == err || nil != ver {
+			t.Error("expect error for bad key length")
+		}
+
+		if ser, err = New(SignECDSA, tECDSAKey, crypto.SHA256); nil != err {
+			t.Fatal(err)
+		} else if ver, err = NewVerifier(SignECDSA,
+			k.Public(), crypto.SHA256); nil != err {
+			t.Fatal(err)
+		} else if s, err = ser.Serialize(tPayload); nil != err {
+			t.Fatal(err)
+		}
+
+		if err = ver.Deserialize(s, &p); !errors.Is(err, ErrBadSign) {
+			t.Errorf("expect ErrBadSign for mismatched key, got (%v)", err)
+		}
+	})
+}
+
+// tamperSign flips a bit in the first byte of the Signature part of the
+// StringToken s and returns the result.
+func tamperSign(s string) string {
+	var i = strings.LastIndexByte(s, '.')
+	var b, err = base64.RawURLEncoding.DecodeString(s[i+1:])
+	if nil != err {
+		panic(err)
+	}
+
+	b[0] ^= 0x01
+	return s[:i+1] + base64.RawURLEncoding.EncodeToString(b)
+}
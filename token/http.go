@@ -91,8 +91,8 @@ func (st *Store) processAuthHeader(r *http.Request) (t *Token, err error) {
 	// futher processing may be required by a wrapping handler (for example,
 	// parse the X-Forwarded-For, or X-Real-IP etc. header of the incoming
 	// request, and replace the r.RemoteAddr with the correct client address)
-	if t, err = st.Access(s, r.RemoteAddr, r.Referer(), r.Header.Get("Origin"),
-		r.UserAgent()); nil == err {
+	if t, err = st.AccessContext(r.Context(), s, r.RemoteAddr, r.Referer(),
+		r.Header.Get("Origin"), r.UserAgent()); nil == err {
 		return
 	}
 
@@ -2,6 +2,7 @@ package token
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"encoding/base32"
@@ -140,11 +141,42 @@ func (st *Store) UseSerializer(
 	return
 }
 
+// UseSerializerFromFile attempts to load a signing or verification key from
+// the file at path (see serializer.LoadKey) and if successful, attaches a
+// Serializer that uses the key to Store. The SignMethod and hash are inferred
+// from the key. If the file holds a public key, the Store can only Access
+// Tokens, not Issue them.
+func (st *Store) UseSerializerFromFile(path string) (err error) {
+	var k *serializer.Key
+	var ser serializer.Serializer
+
+	if k, err = serializer.LoadKey(path); nil != err {
+		return
+	}
+
+	if ser, err = k.Serializer(); nil != err {
+		return
+	}
+
+	st.serlr = ser
+	return
+}
+
 // Issue creates a new Token, serializes and registers it with the storage
 // backend and returns a string token if successful, that can be passed to
 // client applications of use as a "bearer" authorization token.
 func (st *Store) Issue(sub uuid.UUID, exp time.Duration,
 	remoteAddr, referer, origin, userAgent string) (s string, err error) {
+	return st.IssueContext(context.Background(), sub, exp, remoteAddr,
+		referer, origin, userAgent)
+}
+
+// IssueContext is as Issue, but passes ctx to the attached Serializer (see
+// serializer.ContextSerializer), so that a slow signer can be given a
+// deadline or cancelled along with the request that needs the Token.
+func (st *Store) IssueContext(ctx context.Context, sub uuid.UUID,
+	exp time.Duration, remoteAddr, referer, origin,
+	userAgent string) (s string, err error) {
 
 	var t *Token
 	var setFpI bool
@@ -174,7 +206,8 @@ func (st *Store) Issue(sub uuid.UUID, exp time.Duration,
 		t.fpi = makeFootprint(0, ss[0], ss[1], ss[2], ss[3])
 	}
 
-	if s, err = st.serlr.Serialize(t); nil != err {
+	if s, err = serializer.WithContext(st.serlr).SerializeContext(ctx,
+		t); nil != err {
 		return "", err
 	}
 
@@ -197,6 +230,14 @@ func (st *Store) Issue(sub uuid.UUID, exp time.Duration,
 // presented s as the "bearer" authorization token.
 func (st *Store) Access(s,
 	remoteAddr, referer, origin, userAgent string) (t *Token, err error) {
+	return st.AccessContext(context.Background(), s, remoteAddr, referer,
+		origin, userAgent)
+}
+
+// AccessContext is as Access, but passes ctx to the attached Serializer (see
+// serializer.ContextSerializer).
+func (st *Store) AccessContext(ctx context.Context, s, remoteAddr, referer,
+	origin, userAgent string) (t *Token, err error) {
 
 	var ss = [...]string{remoteAddr, referer, origin, userAgent}
 	var setFpC bool
@@ -205,7 +246,8 @@ func (st *Store) Access(s,
 		return nil, ErrNoSerializer
 	}
 
-	if err = st.serlr.Deserialize(s, &t); nil != err || nil == t {
+	if err = serializer.WithContext(st.serlr).DeserializeContext(ctx, s,
+		&t); nil != err || nil == t {
 		return nil, err
 	}
 
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"encoding/base64"
@@ -359,6 +360,60 @@ func TestIssue(t *testing.T) {
 	}
 }
 
+func TestIssuePASETO(t *testing.T) {
+	skipIfNoRedisClient(t)
+	defer redisServer.FlushAll()
+
+	var key [32]byte
+	var ser serializer.Serializer
+	var tk *Token
+	var s string
+	var err error
+
+	if _, err = rand.Read(key[:]); nil != err {
+		t.Fatal(err)
+	} else if ser, err = serializer.NewPASETOLocal(key[:],
+		nil, nil); nil != err {
+		t.Fatal(err)
+	}
+
+	// a Store works the same with PASETO tokens as with StringTokens
+	var st = NewStore(redisClient, ser)
+	var sub = uuid.New()
+
+	if s, err = st.Issue(sub, 0, "10.10.10.10", "", "", ""); nil != err {
+		t.Fatal(err)
+	} else if tk, err = st.Access(s, "", "", "", ""); nil != err {
+		t.Fatal(err)
+	} else if tk.Subject != sub {
+		t.Errorf("accessed Token does not match issued Token"+
+			"\nexp: %v"+
+			"\nret: %v", sub, tk.Subject)
+	} else if nil == tk.fpi {
+		t.Error("st.Access does not set initial Footprint")
+	}
+}
+
+func TestIssueContext(t *testing.T) {
+	var st = Store{serlr: tSerlr}
+	var ctx, cancel = context.WithCancel(context.Background())
+	var s, _ = tSerlr.Serialize(New(uuid.New(), "", nil, 0))
+
+	// the Serializer is called before the storage backend, so an abandoned
+	// request never registers or accesses a Token
+	cancel()
+
+	if _, err := st.IssueContext(ctx, uuid.New(), 0, "", "", "",
+		""); context.Canceled != err {
+		t.Errorf("expect context.Canceled, got %v", err)
+	}
+
+	if _, err := st.AccessContext(ctx, s, "", "", "",
+		""); context.Canceled != err {
+		t.Errorf("expect context.Canceled, got %v", err)
+	}
+}
+
 func TestRevoke(t *testing.T) {
 	return
 }
@@ -534,6 +589,22 @@ func ExampleStore_UseSerializer() {
 	// arguments to serialize/deserialize Tokens.
 }
 
+func ExampleStore_UseSerializerFromFile() {
+	var store = NewStore(nil, nil)
+
+	// The key file may hold a PEM or DER encoded private key (PKCS #1, PKCS #8
+	// or SEC 1) or a JSON Web Key; the SignMethod and hash are inferred from
+	// the type of the key, e.g. SignECDSA with crypto.SHA384 for a P-384 key.
+	if err := store.UseSerializerFromFile(
+		"/etc/auth/signing-key.pem"); nil != err {
+		panic(err)
+	}
+
+	// Services that only verify Tokens can be given the public key instead,
+	// e.g. a PEM encoded "PUBLIC KEY"; store can then Access Tokens, but not
+	// Issue them.
+}
+
 func ExampleStore_ConnectStorage() {
 	var store *Store
 	var err error
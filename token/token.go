@@ -8,10 +8,12 @@ package token
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/google/uuid"
 	"github.com/vmihailenco/msgpack"
 )
@@ -51,6 +53,37 @@ type token struct {
 	Expires   int64  `msgpack:"exp,omitempty"`
 }
 
+// claims is the JSON representation of a Token. It maps the fields of Token to
+// the registered claims of a JSON Web Token (see RFC 7519, sec. 4.1), so that
+// Tokens can be read by standard JWT libraries.
+type claims struct {
+	Id        string   `json:"jti,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	Issued    int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Expires   int64    `json:"exp,omitempty"`
+}
+
+// cwtClaims is the CBOR representation of a Token. It maps the fields of Token
+// to the claims of a CBOR Web Token (see RFC 8392, sec. 3.1), which are keyed
+// by integers instead of names, so that Tokens can be carried by the CWTs of
+// serializer.NewCWT.
+type cwtClaims struct {
+	Issuer    string   `cbor:"1,keyasint,omitempty"`
+	Subject   string   `cbor:"2,keyasint,omitempty"`
+	Audience  audience `cbor:"3,keyasint,omitempty"`
+	Expires   int64    `cbor:"4,keyasint,omitempty"`
+	NotBefore int64    `cbor:"5,keyasint,omitempty"`
+	Issued    int64    `cbor:"6,keyasint,omitempty"`
+	Id        []byte   `cbor:"7,keyasint,omitempty"`
+}
+
+// audience is the "aud" claim of a JSON Web Token, which may either be a
+// single string or an array of strings (see RFC 7519, sec. 4.1.3).
+type audience []string
+
 // Footprint returns the two Footprint structs associated with the Token.
 // The first Footprint (fpi) is the Footprint at the time Token was issued and
 // the second Footprint (fpc) is the Footprint at the time Token was last
@@ -59,6 +92,17 @@ func (t *Token) Footprint() (fpi, fpc *Footprint) {
 	return t.fpi, t.fpc
 }
 
+// ExpiresAt returns the time at which the Token expires, or the zero time.Time
+// if it never does. It makes Token implement the serializer.Expirer
+// interface, so that a serializer.Cache keeps it no longer than that.
+func (t *Token) ExpiresAt() time.Time {
+	if 0 == t.Expires {
+		return time.Time{}
+	}
+
+	return t.Expires.Time()
+}
+
 // EncodeMsgpack implements the msgpack.CustomEncoder interface.
 func (t *Token) EncodeMsgpack(e *msgpack.Encoder) (err error) {
 	return e.Encode(t.toInternal())
@@ -75,6 +119,104 @@ func (t *Token) DecodeMsgpack(d *msgpack.Decoder) (err error) {
 	return
 }
 
+// MarshalJSON implements the json.Marshaler interface. Token.Id and
+// Token.Subject are encoded as UUID strings, and the Timestamps as JSON
+// numbers, under the names of the registered JWT claims ("jti", "sub", "iss",
+// "aud", "iat", "nbf" and "exp").
+func (t *Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.toClaims())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *Token) UnmarshalJSON(b []byte) (err error) {
+	var c claims
+	if err = json.Unmarshal(b, &c); nil != err {
+		return
+	}
+
+	return t.fromClaims(&c)
+}
+
+// MarshalCBOR implements the cbor.Marshaler interface. Token.Id is encoded as
+// the 16 bytes of the UUID ("cti"), Token.Subject as a UUID string ("sub"),
+// and the Timestamps as integers, under the integer keys of the CWT claims.
+func (t *Token) MarshalCBOR() ([]byte, error) {
+	var c = t.toClaims()
+	var z uuid.UUID
+
+	var cc = cwtClaims{
+		Issuer:    c.Issuer,
+		Subject:   c.Subject,
+		Audience:  c.Audience,
+		Expires:   c.Expires,
+		NotBefore: c.NotBefore,
+		Issued:    c.Issued,
+	}
+
+	if t.Id != z {
+		cc.Id = t.Id[:]
+	}
+
+	return cbor.Marshal(&cc)
+}
+
+// UnmarshalCBOR implements the cbor.Unmarshaler interface.
+func (t *Token) UnmarshalCBOR(b []byte) (err error) {
+	var cc cwtClaims
+	var c claims
+
+	if err = cbor.Unmarshal(b, &cc); nil != err {
+		return
+	}
+
+	c = claims{
+		Subject:   cc.Subject,
+		Issuer:    cc.Issuer,
+		Audience:  cc.Audience,
+		Issued:    cc.Issued,
+		NotBefore: cc.NotBefore,
+		Expires:   cc.Expires,
+	}
+
+	if err = t.fromClaims(&c); nil != err {
+		return
+	}
+
+	if 0 != len(cc.Id) {
+		if t.Id, err = uuid.FromBytes(cc.Id); nil != err {
+			return
+		}
+	}
+
+	return
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting either a
+// string or an array of strings.
+func (a *audience) UnmarshalJSON(b []byte) (err error) {
+	var s string
+
+	if err = json.Unmarshal(b, &s); nil == err {
+		*a = audience{s}
+		return
+	}
+
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// UnmarshalCBOR implements the cbor.Unmarshaler interface, accepting either a
+// text string or an array of text strings.
+func (a *audience) UnmarshalCBOR(b []byte) (err error) {
+	var s string
+
+	if err = cbor.Unmarshal(b, &s); nil == err {
+		*a = audience{s}
+		return
+	}
+
+	return cbor.Unmarshal(b, (*[]string)(a))
+}
+
 // New constructs a new Token from the given values. t.Id is set to a newly
 // generated UUIDv4 (see uuid.NewRandom) and t.Issued is set to the current
 // Unix time.
@@ -146,6 +288,55 @@ func (t *Token) fromInternal(_t *token) {
 	return
 }
 
+// toClaims converts Token to its JSON representation (claims).
+func (t *Token) toClaims() (c *claims) {
+	var z uuid.UUID
+
+	c = &claims{
+		Issuer:    t.Issuer,
+		Audience:  t.Audience,
+		Issued:    int64(t.Issued),
+		NotBefore: int64(t.NotBefore),
+		Expires:   int64(t.Expires),
+	}
+
+	if t.Id != z {
+		c.Id = t.Id.String()
+	}
+
+	if t.Subject != z {
+		c.Subject = t.Subject.String()
+	}
+
+	return
+}
+
+// fromClaims fills up a Token from its JSON representation (claims). An error
+// is returned if the "jti" or "sub" claims are not UUID strings.
+func (t *Token) fromClaims(c *claims) (err error) {
+	*t = Token{
+		Issuer:    c.Issuer,
+		Audience:  c.Audience,
+		Issued:    Timestamp(c.Issued),
+		NotBefore: Timestamp(c.NotBefore),
+		Expires:   Timestamp(c.Expires),
+	}
+
+	if 0 != len(c.Id) {
+		if t.Id, err = uuid.Parse(c.Id); nil != err {
+			return
+		}
+	}
+
+	if 0 != len(c.Subject) {
+		if t.Subject, err = uuid.Parse(c.Subject); nil != err {
+			return
+		}
+	}
+
+	return
+}
+
 // validate validates the fields of Token.
 func (t *Token) validate(nbfCheck bool) (err error) {
 	var zu = uuid.UUID{}
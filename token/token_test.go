@@ -1,11 +1,15 @@
 package token
 
 import (
+	"crypto"
+	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/go-redis/redis"
 	"github.com/google/uuid"
 	"github.com/rrm80/gautham/serializer"
@@ -106,6 +110,170 @@ func TestMain(m *testing.M) {
 	os.Exit(n)
 }
 
+func TestTokenJSON(t *testing.T) {
+	var tk Token
+	var m map[string]interface{}
+	var b []byte
+	var err error
+
+	if b, err = json.Marshal(tToken); nil != err {
+		t.Fatal(err)
+	}
+
+	// Token fields are mapped to the registered JWT claims
+	if err = json.Unmarshal(b, &m); nil != err {
+		t.Fatal(err)
+	}
+
+	for k, v := range map[string]interface{}{
+		"jti": tToken.Id.String(),
+		"sub": tToken.Subject.String(),
+		"iss": tToken.Issuer,
+		"iat": float64(tToken.Issued),
+		"exp": float64(tToken.Expires),
+	} {
+		if m[k] != v {
+			t.Errorf("claim %q does not match expectation"+
+				"\nexp: %v"+
+				"\nret: %v", k, v, m[k])
+		}
+	}
+
+	if _, ok := m["nbf"]; ok {
+		t.Error("zero Token.NotBefore encoded as \"nbf\" claim")
+	}
+
+	if err = json.Unmarshal(b, &tk); nil != err {
+		t.Fatal(err)
+	}
+
+	// Footprints are not part of the JSON representation
+	var exp = *tToken
+	exp.fpi, exp.fpc = nil, nil
+
+	if !reflect.DeepEqual(&exp, &tk) {
+		t.Errorf("JSON decoded Token does not match expectation"+
+			"\nexp: %+v"+
+			"\nret: %+v", &exp, &tk)
+	}
+
+	// "aud" may be a single string
+	if err = json.Unmarshal([]byte(`{"aud":"https://a.example.org"}`),
+		&tk); nil != err {
+		t.Error(err)
+	} else if !reflect.DeepEqual(tk.Audience, []string{"https://a.example.org"}) {
+		t.Errorf("single string \"aud\" claim decoded as %q", tk.Audience)
+	}
+
+	if err = json.Unmarshal([]byte(`{"sub":"foo"}`), &tk); nil == err {
+		t.Error("expect error for non-UUID \"sub\" claim")
+	}
+}
+
+func TestTokenCBOR(t *testing.T) {
+	var tk Token
+	var m map[int]interface{}
+	var b []byte
+	var err error
+
+	if b, err = cbor.Marshal(tToken); nil != err {
+		t.Fatal(err)
+	}
+
+	// Token fields are mapped to the integer keys of the CWT claims
+	if err = cbor.Unmarshal(b, &m); nil != err {
+		t.Fatal(err)
+	}
+
+	for k, v := range map[int]interface{}{
+		1: tToken.Issuer,
+		2: tToken.Subject.String(),
+		4: uint64(tToken.Expires),
+		6: uint64(tToken.Issued),
+	} {
+		if m[k] != v {
+			t.Errorf("claim %d does not match expectation"+
+				"\nexp: %v"+
+				"\nret: %v", k, v, m[k])
+		}
+	}
+
+	if cti, _ := m[7].([]byte); !reflect.DeepEqual(cti, tToken.Id[:]) {
+		t.Errorf("\"cti\" claim %x does not match Token.Id", m[7])
+	}
+
+	if err = cbor.Unmarshal(b, &tk); nil != err {
+		t.Fatal(err)
+	}
+
+	var exp = *tToken
+	exp.fpi, exp.fpc = nil, nil
+
+	if !reflect.DeepEqual(&exp, &tk) {
+		t.Errorf("CBOR decoded Token does not match expectation"+
+			"\nexp: %+v"+
+			"\nret: %+v", &exp, &tk)
+	}
+
+	// "aud" may be a single string
+	if b, err = cbor.Marshal(
+		map[int]string{3: "https://a.example.org"}); nil != err {
+		t.Fatal(err)
+	} else if err = cbor.Unmarshal(b, &tk); nil != err {
+		t.Error(err)
+	} else if !reflect.DeepEqual(tk.Audience, []string{"https://a.example.org"}) {
+		t.Errorf("single string \"aud\" claim decoded as %q", tk.Audience)
+	}
+
+	// Tokens are the claims of CBOR Web Tokens
+	var key = make([]byte, 256)
+	var ser, _ = serializer.New(serializer.SignHMAC, key, crypto.SHA256)
+	var cwt, _ = serializer.NewCWT(ser)
+	var s string
+
+	if s, err = cwt.Serialize(tToken); nil != err {
+		t.Fatal(err)
+	} else if tk = (Token{}); nil != cwt.Deserialize(s, &tk) {
+		t.Error(cwt.Deserialize(s, &tk))
+	} else if !reflect.DeepEqual(&exp, &tk) {
+		t.Errorf("CWT decoded Token does not match expectation"+
+			"\nexp: %+v"+
+			"\nret: %+v", &exp, &tk)
+	}
+}
+
+func TestTokenCache(t *testing.T) {
+	var key = make([]byte, 256)
+	var ser, _ = serializer.New(serializer.SignHMAC, key, crypto.SHA256)
+	var c, _ = serializer.NewCache(ser, 8, 0)
+	var exp = New(uuid.New(), "", nil, time.Hour)
+	var s, _ = c.Serialize(exp)
+
+	// Tokens are cached until they expire; tk is a *Token, as in Store.Access
+	for i := 0; i < 2; i++ {
+		var tk *Token
+
+		if err := c.Deserialize(s, &tk); nil != err {
+			t.Fatal(err)
+		} else if tk.Id != exp.Id || tk.Expires != exp.Expires {
+			t.Errorf("cached Token does not match expectation"+
+				"\nexp: %+v"+
+				"\nret: %+v", exp, tk)
+		}
+	}
+
+	if st := c.Stats(); 1 != st.Hits || 1 != st.Len {
+		t.Errorf("unexpected cache stats %+v", st)
+	}
+
+	exp.Expires = Timestamp(time.Now().Add(-time.Minute).Unix())
+	if s, _ = c.Serialize(exp); nil != c.Deserialize(s, &Token{}) {
+		t.Fatal("expect expired Token to deserialize")
+	} else if st := c.Stats(); 1 != st.Len {
+		t.Error("expect expired Token not to be cached")
+	}
+}
+
 func BenchmarkTokenBin(b *testing.B) {
 	b.Run("Enc", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {